@@ -0,0 +1,290 @@
+package Golly
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Capability names a set of builtins that can be granted to a
+// SysEnvironment. The values true, false and nil and the type names are
+// always bound; everything else belongs to a capability.
+type Capability string
+
+const (
+	// CoreCapability covers arithmetic, comparison, collections, strings,
+	// chars, symbols, regexes, channels, goroutines, the parallel builtins,
+	// atoms and refs.
+	CoreCapability Capability = "core"
+	// MathCapability covers the further numeric functions.
+	MathCapability Capability = "math"
+	// IOCapability covers reading and writing the SysEnvironment's Stdin
+	// and Stdout.
+	IOCapability Capability = "io"
+	// OSCapability covers files and environment variables.
+	OSCapability Capability = "os"
+	// NetCapability covers network requests.
+	NetCapability Capability = "net"
+)
+
+// AllCapabilities lists every capability, as granted by CreateSystemFuncs.
+var AllCapabilities = []Capability{CoreCapability, MathCapability, IOCapability, OSCapability, NetCapability}
+
+var goFuncCapabilities = map[goFuncType]Capability{
+	GoAddT:            CoreCapability,
+	GoSubtractT:       CoreCapability,
+	GoMultiplyT:       CoreCapability,
+	GoDivideT:         CoreCapability,
+	GoEqualT:          CoreCapability,
+	GoLessT:           CoreCapability,
+	GoGreaterT:        CoreCapability,
+	GoLessEqT:         CoreCapability,
+	GoGreaterEqT:      CoreCapability,
+	GoModT:            MathCapability,
+	GoAbsT:            MathCapability,
+	GoSqrtT:           MathCapability,
+	GoPowT:            MathCapability,
+	GoFloorT:          MathCapability,
+	GoCeilT:           MathCapability,
+	GoPrintT:          IOCapability,
+	GoPrintlnT:        IOCapability,
+	GoReadLineT:       IOCapability,
+	GoReadFileT:       OSCapability,
+	GoWriteFileT:      OSCapability,
+	GoGetenvT:         OSCapability,
+	GoHttpGetT:        NetCapability,
+	GoChanT:           CoreCapability,
+	GoSendT:           CoreCapability,
+	GoRecvT:           CoreCapability,
+	GoCloseT:          CoreCapability,
+	GoSpawnT:          CoreCapability,
+	GoPmapT:           CoreCapability,
+	GoParT:            CoreCapability,
+	GoFutureT:         CoreCapability,
+	GoAwaitT:          CoreCapability,
+	GoAtomT:           CoreCapability,
+	GoSwapT:           CoreCapability,
+	GoResetT:          CoreCapability,
+	GoRefT:            CoreCapability,
+	GoDerefT:          CoreCapability,
+	GoRefSetT:         CoreCapability,
+	GoAlterT:          CoreCapability,
+	GoGetT:            CoreCapability,
+	GoAssocT:          CoreCapability,
+	GoDissocT:         CoreCapability,
+	GoConjT:           CoreCapability,
+	GoCountT:          CoreCapability,
+	GoSubvecT:         CoreCapability,
+	GoCatvecT:         CoreCapability,
+	GoListT:           CoreCapability,
+	GoConsT:           CoreCapability,
+	GoCarT:            CoreCapability,
+	GoFirstT:          CoreCapability,
+	GoCdrT:            CoreCapability,
+	GoRestT:           CoreCapability,
+	GoNthT:            CoreCapability,
+	GoLengthT:         CoreCapability,
+	GoAppendT:         CoreCapability,
+	GoReverseT:        CoreCapability,
+	GoMapT:            CoreCapability,
+	GoFilterT:         CoreCapability,
+	GoReduceT:         CoreCapability,
+	GoFoldT:           CoreCapability,
+	GoRangeT:          CoreCapability,
+	GoSortT:           CoreCapability,
+	GoStrT:            CoreCapability,
+	GoConcatT:         CoreCapability,
+	GoSubstringT:      CoreCapability,
+	GoSplitT:          CoreCapability,
+	GoJoinT:           CoreCapability,
+	GoUpperT:          CoreCapability,
+	GoLowerT:          CoreCapability,
+	GoTrimT:           CoreCapability,
+	GoIndexOfT:        CoreCapability,
+	GoReplaceT:        CoreCapability,
+	GoFormatT:         CoreCapability,
+	GoStringToNumberT: CoreCapability,
+	GoNumberToStringT: CoreCapability,
+	GoReCompileT:      CoreCapability,
+	GoReMatchT:        CoreCapability,
+	GoReFindAllT:      CoreCapability,
+	GoReReplaceT:      CoreCapability,
+	GoReSplitT:        CoreCapability,
+	GoCharAlphabeticT: CoreCapability,
+	GoCharDigitT:      CoreCapability,
+	GoCharWhitespaceT: CoreCapability,
+	GoCharToIntT:      CoreCapability,
+	GoIntToCharT:      CoreCapability,
+	GoStringToListT:   CoreCapability,
+	GoListToStringT:   CoreCapability,
+	GoSymbolT:         CoreCapability,
+	GoKeywordT:        CoreCapability,
+	GoGensymT:         CoreCapability,
+	GoSymbolToStringT: CoreCapability,
+	GoDisplayT:        IOCapability,
+	GoWriteT:          IOCapability,
+}
+
+// impureGoFuncs are the builtins with side effects or results that depend
+// on the outside world, which must never be folded or memoised.
+var impureGoFuncs = map[goFuncType]bool{
+	GoPrintT:     true,
+	GoPrintlnT:   true,
+	GoReadLineT:  true,
+	GoReadFileT:  true,
+	GoWriteFileT: true,
+	GoGetenvT:    true,
+	GoHttpGetT:   true,
+	GoChanT:      true,
+	GoSendT:      true,
+	GoRecvT:      true,
+	GoCloseT:     true,
+	GoSpawnT:     true,
+	GoFutureT:    true,
+	GoAwaitT:     true,
+	GoAtomT:      true,
+	GoSwapT:      true,
+	GoResetT:     true,
+	GoRefT:       true,
+	GoDerefT:     true,
+	GoRefSetT:    true,
+	GoAlterT:     true,
+	GoGensymT:    true,
+	GoDisplayT:   true,
+	GoWriteT:     true,
+}
+
+// PermissionError reports a reference to a builtin whose capability the
+// SysEnvironment does not grant.
+type PermissionError struct {
+	Name       string
+	Capability Capability
+	LineNum    int
+}
+
+func (err *PermissionError) Error() string {
+	if err.LineNum == 0 {
+		return fmt.Sprintf("Error: %v needs the %v capability, which this environment does not grant.\n", err.Name, err.Capability)
+	}
+	return fmt.Sprintf("Error: %v at line %v needs the %v capability, which this environment does not grant.\n", err.Name, err.LineNum, err.Capability)
+}
+
+// NewSysEnvironment creates a SysEnvironment holding the builtins of the
+// given capabilities. Builtins left out are recorded in Denied, so that
+// referring to one is reported as a PermissionError rather than as an
+// unbound var.
+func NewSysEnvironment(caps ...Capability) (*SysEnvironment, error) {
+	granted := make(map[Capability]bool)
+	for _, capability := range caps {
+		if !knownCapability(capability) {
+			err := fmt.Sprintf("Error: unknown capability %v.\n", capability)
+			return nil, errors.New(err)
+		}
+		granted[capability] = true
+	}
+	sysBindings := make(map[string]EnvBinding)
+	denied := make(map[string]Capability)
+	for funcType, capability := range goFuncCapabilities {
+		name := goFuncNames[funcType]
+		if !granted[capability] {
+			denied[name] = capability
+			continue
+		}
+		sysBindings[name] = EnvBinding{Binding: makeSysFunc(funcType)}
+	}
+	sysBindings["true"] = EnvBinding{Binding: makeBoolCell(true)}
+	sysBindings["false"] = EnvBinding{Binding: makeBoolCell(false)}
+	sysBindings["nil"] = EnvBinding{Binding: makeNilCell()}
+	for _, typeName := range []string{INT_TYPE_NAME, FLOAT_TYPE_NAME, BOOL_TYPE_NAME, STRING_TYPE_NAME, CHAR_TYPE_NAME, SYMBOL_TYPE_NAME, KEYWORD_TYPE_NAME, FUNCTION_TYPE_NAME, LIST_TYPE_NAME, CHANNEL_TYPE_NAME, FUTURE_TYPE_NAME, ATOM_TYPE_NAME, REF_TYPE_NAME, VECTOR_TYPE_NAME, MAP_TYPE_NAME, REGEX_TYPE_NAME} {
+		sysBindings[typeName] = EnvBinding{Binding: makeSysType()}
+	}
+	for name, binding := range sysBindings {
+		binding.Name = name
+		sysBindings[name] = binding
+	}
+	env := SysEnvironment{Bindings: sysBindings, Denied: denied, Stdout: os.Stdout, Stdin: os.Stdin, io: &sysIO{}}
+	return &env, nil
+}
+
+// Describe returns a line describing a binding of the SysEnvironment, for
+// tools such as the language server to show: for a builtin, how many
+// arguments it takes, the capability it needs and whether it is pure, and
+// for anything else its type.
+func (sys *SysEnvironment) Describe(name string) (string, bool) {
+	binding, ok := sys.Bindings[name]
+	if !ok {
+		return "", false
+	}
+	funct, ok := binding.Binding.Value.(FunctionObj)
+	if !ok || !funct.GoFunc {
+		if binding.Binding.TypeName == TYPE_TYPE_NAME {
+			return fmt.Sprintf("%v is a type.", name), true
+		}
+		return fmt.Sprintf("%v is a %v.", name, binding.Binding.TypeName), true
+	}
+	arguments := "any number of arguments"
+	if arity, ok := goFuncArities[funct.FuncType]; ok && arity == 1 {
+		arguments = "1 argument"
+	} else if ok {
+		arguments = fmt.Sprintf("%v arguments", arity)
+	}
+	purity := "a pure"
+	if impureGoFuncs[funct.FuncType] {
+		purity = "an impure"
+	}
+	return fmt.Sprintf("%v is %v builtin taking %v, from the %v capability.", name, purity, arguments, goFuncCapabilities[funct.FuncType]), true
+}
+
+func knownCapability(capability Capability) bool {
+	for _, known := range AllCapabilities {
+		if capability == known {
+			return true
+		}
+	}
+	return false
+}
+
+// sysIO serialises the io builtins' use of a SysEnvironment's Stdin and
+// Stdout, and buffers Stdin between calls of read-line.
+type sysIO struct {
+	inMu   sync.Mutex
+	outMu  sync.Mutex
+	source io.Reader
+	reader *bufio.Reader
+}
+
+func (sys *SysEnvironment) readLine() (string, bool, error) {
+	sysio := sys.io
+	if sysio == nil {
+		return "", false, errors.New("Error: read-line needs a SysEnvironment created by NewSysEnvironment.\n")
+	}
+	sysio.inMu.Lock()
+	defer sysio.inMu.Unlock()
+	if sys.Stdin == nil {
+		return "", false, nil
+	}
+	if sysio.reader == nil || sysio.source != sys.Stdin {
+		sysio.source = sys.Stdin
+		sysio.reader = bufio.NewReader(sys.Stdin)
+	}
+	line, err := sysio.reader.ReadString('\n')
+	if err == io.EOF {
+		return line, line != "", nil
+	}
+	return line, true, err
+}
+
+func (sys *SysEnvironment) write(text string) error {
+	if sys.Stdout == nil {
+		return nil
+	}
+	if sys.io != nil {
+		sys.io.outMu.Lock()
+		defer sys.io.outMu.Unlock()
+	}
+	_, err := io.WriteString(sys.Stdout, text)
+	return err
+}
@@ -0,0 +1,89 @@
+package Golly
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNewSysEnvironmentGrantsOnlyItsCapabilities(t *testing.T) {
+	sys, err := NewSysEnvironment(CoreCapability, MathCapability)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"+", "=", "sqrt", "mod", "true", "nil", INT_TYPE_NAME} {
+		if _, ok := sys.Bindings[name]; !ok {
+			t.Errorf("%v is not bound with core and math", name)
+		}
+	}
+	denied := map[string]Capability{"println": IOCapability, "read-file": OSCapability, "getenv": OSCapability, "http-get": NetCapability}
+	for name, capability := range denied {
+		if _, ok := sys.Bindings[name]; ok {
+			t.Errorf("%v is bound without the %v capability", name, capability)
+		}
+		if sys.Denied[name] != capability {
+			t.Errorf("%v is denied for %q, want %v", name, sys.Denied[name], capability)
+		}
+	}
+	if _, err := NewSysEnvironment(CoreCapability, "disk"); err == nil {
+		t.Error("NewSysEnvironment accepted an unknown capability")
+	}
+}
+
+func TestDeniedBuiltinIsPermissionError(t *testing.T) {
+	sys, err := NewSysEnvironment(CoreCapability)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range [][]Option{nil, {WithBytecode()}} {
+		interp := New(append(opts, WithSystem(sys))...)
+		_, err := interp.EvalString(context.Background(), "(+ 1 2)\n(println (sqrt 4))")
+		var permErr *PermissionError
+		if !errors.As(err, &permErr) || permErr.Name != "println" || permErr.Capability != IOCapability || permErr.LineNum != 2 {
+			t.Errorf("calling println without io returned %v, want a PermissionError at line 2", err)
+		}
+		_, err = interp.Call(context.Background(), "sqrt", IntValue(4))
+		if !errors.As(err, &permErr) || permErr.Name != "sqrt" || permErr.Capability != MathCapability {
+			t.Errorf("Call of sqrt without math returned %v, want a PermissionError", err)
+		}
+	}
+}
+
+func TestGrantedIOUsesTheSysEnvironment(t *testing.T) {
+	sys, err := NewSysEnvironment(CoreCapability, IOCapability)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	sys.Stdout = &out
+	if _, err := New(WithSystem(sys)).EvalString(context.Background(), "(print 1) (println 2)"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "12\n" {
+		t.Errorf("print and println wrote %q, want %q", out.String(), "12\n")
+	}
+}
+
+func TestDescribeFollowsCapabilities(t *testing.T) {
+	sys, err := NewSysEnvironment(CoreCapability, IOCapability)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"+":           "+ is a pure builtin taking 2 arguments, from the core capability.",
+		"println":     "println is an impure builtin taking any number of arguments, from the io capability.",
+		INT_TYPE_NAME: "int is a type.",
+		"true":        "true is a bool.",
+	}
+	for name, want := range tests {
+		if got, ok := sys.Describe(name); !ok || got != want {
+			t.Errorf("Describe(%q) = %q, %v, want %q", name, got, ok, want)
+		}
+	}
+	for _, name := range []string{"sqrt", "read-file", "undefined-thing"} {
+		if got, ok := sys.Describe(name); ok {
+			t.Errorf("Describe(%q) = %q for a builtin the environment does not hold", name, got)
+		}
+	}
+}
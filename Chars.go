@@ -0,0 +1,106 @@
+package Golly
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+func makeCharCell(char rune) ListCell {
+	return ListCell{TypeName: CHAR_TYPE_NAME, Value: char}
+}
+
+func charArg(name string, cell *ListCell) (rune, error) {
+	char, ok := cell.Value.(rune)
+	if !ok || cell.TypeName != CHAR_TYPE_NAME {
+		err := fmt.Sprintf("Error: %v expects a char but got %v.\n", name, cell.TypeName)
+		return 0, errors.New(err)
+	}
+	return char, nil
+}
+
+// GoCharPredicate implements char-alphabetic?, char-digit? and
+// char-whitespace?, which use the Unicode character classes.
+func GoCharPredicate(funcType goFuncType, Cell1 *ListCell) ([]*ListCell, error) {
+	char, err := charArg(goFuncNames[funcType], Cell1)
+	if err != nil {
+		return nil, err
+	}
+	var result bool
+	switch funcType {
+	case GoCharAlphabeticT:
+		result = unicode.IsLetter(char)
+	case GoCharDigitT:
+		result = unicode.IsDigit(char)
+	case GoCharWhitespaceT:
+		result = unicode.IsSpace(char)
+	default:
+		err := fmt.Sprintf("Error: attempting to test a char with unhandled builtin of type number %v.\n", funcType)
+		return nil, errors.New(err)
+	}
+	returnVal := makeBoolCell(result)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoCharToInt returns the code point of a char.
+func GoCharToInt(Cell1 *ListCell) ([]*ListCell, error) {
+	char, err := charArg("char->int", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := ListCell{TypeName: INT_TYPE_NAME, Value: int(char)}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoIntToChar returns the char with a code point.
+func GoIntToChar(Cell1 *ListCell) ([]*ListCell, error) {
+	code, ok := Cell1.Value.(int)
+	if !ok || Cell1.TypeName != INT_TYPE_NAME {
+		err := fmt.Sprintf("Error: int->char expects an int but got %v.\n", Cell1.TypeName)
+		return nil, errors.New(err)
+	}
+	if code < 0 || code > unicode.MaxRune || !utf8.ValidRune(rune(code)) {
+		err := fmt.Sprintf("Error: int->char got %v, which is not a Unicode code point.\n", code)
+		return nil, errors.New(err)
+	}
+	returnVal := makeCharCell(rune(code))
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoStringToList returns the list of the chars in a string.
+func GoStringToList(Cell1 *ListCell, env *Environment) ([]*ListCell, error) {
+	str, err := stringArg("string->list", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]ListCell, 0, utf8.RuneCountInString(str))
+	state := env.evalState()
+	for _, char := range str {
+		state.poll(len(vals))
+		vals = append(vals, makeCharCell(char))
+	}
+	returnVal := newList(vals, env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoListToString returns the string of the chars in a list.
+func GoListToString(Cell1 *ListCell, env *Environment) ([]*ListCell, error) {
+	vals, err := listArg("list->string", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	var str strings.Builder
+	state := env.evalState()
+	for i := range vals {
+		state.poll(i)
+		char, err := charArg("list->string", &vals[i])
+		if err != nil {
+			return nil, err
+		}
+		str.WriteRune(char)
+	}
+	returnVal := makeStringCell(str.String(), env)
+	return []*ListCell{&returnVal}, nil
+}
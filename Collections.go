@@ -0,0 +1,1005 @@
+package Golly
+
+import (
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"reflect"
+	"unicode/utf8"
+)
+
+const (
+	trieBits  = 5
+	trieWidth = 1 << trieBits
+	trieMask  = trieWidth - 1
+)
+
+// vectorExtras is how many more nodes than the fewest that could hold
+// their elements concatenation leaves on a level where two tries meet.
+// Allowing a few spare keeps concatenation from copying whole levels,
+// and keeps lookups scanning only a few entries of a size table.
+const vectorExtras = 2
+
+// Vector is a persistent vector: an RRB tree of up to 32-way nodes holding
+// all but the last few elements, which are kept in a separate tail.
+// Updates copy only the path to the element changed and share the rest
+// with the original.
+//
+// Vectors built by adding elements at the end have a full node at every
+// position but the last on each level, so an index picks a node's child
+// by its bits alone. Concat and Subvec leave nodes that are not full where
+// the tries were cut or joined. Those relaxed nodes keep a table of the
+// sizes of their children, which lookups search instead, and which lets
+// both operations take O(log n) time rather than copying elements.
+type Vector struct {
+	count int
+	shift uint
+	root  *vectorNode
+	tail  []ListCell
+}
+
+// vectorNode is a branch, holding children, or a leaf, holding values. A
+// relaxed branch has sizes, the running totals of its children's
+// elements; a branch without them has a full child at every position but
+// the last.
+type vectorNode struct {
+	children []*vectorNode
+	vals     []ListCell
+	sizes    []int
+}
+
+var emptyVector = &Vector{shift: trieBits, root: &vectorNode{}}
+
+// NewVector returns a Vector holding vals.
+func NewVector(vals ...ListCell) *Vector {
+	vec := emptyVector
+	for _, val := range vals {
+		vec = vec.Conj(val)
+	}
+	return vec
+}
+
+// Len returns the number of elements in vec.
+func (vec *Vector) Len() int {
+	return vec.count
+}
+
+func (vec *Vector) tailOffset() int {
+	return vec.count - len(vec.tail)
+}
+
+// child returns which of node's children, at level, holds the element at
+// index i of node, and the index of the element within that child.
+func (node *vectorNode) child(i int, level uint) (int, int) {
+	subidx := i >> level
+	if node.sizes == nil {
+		return subidx, i - subidx<<level
+	}
+	//No child holds more than 1<<level elements, so the child sought is
+	//never before the one the bits pick.
+	for node.sizes[subidx] <= i {
+		subidx++
+	}
+	if subidx > 0 {
+		i -= node.sizes[subidx-1]
+	}
+	return subidx, i
+}
+
+func (vec *Vector) leafFor(i int) ([]ListCell, int) {
+	if offset := vec.tailOffset(); i >= offset {
+		return vec.tail, i - offset
+	}
+	node := vec.root
+	for level := vec.shift; level > 0; level -= trieBits {
+		var subidx int
+		subidx, i = node.child(i, level)
+		node = node.children[subidx]
+	}
+	return node.vals, i
+}
+
+// Nth returns the element at index i, which must be in range.
+func (vec *Vector) Nth(i int) ListCell {
+	vals, i := vec.leafFor(i)
+	return vals[i]
+}
+
+// Slice returns the elements of vec in order.
+func (vec *Vector) Slice() []ListCell {
+	vals := appendVectorLeaves(make([]ListCell, 0, vec.count), vec.shift, vec.root)
+	return append(vals, vec.tail...)
+}
+
+func appendVectorLeaves(vals []ListCell, level uint, node *vectorNode) []ListCell {
+	if level == 0 {
+		return append(vals, node.vals...)
+	}
+	for _, child := range node.children {
+		vals = appendVectorLeaves(vals, level-trieBits, child)
+	}
+	return vals
+}
+
+// vectorNodeSize returns the number of elements under node.
+func vectorNodeSize(level uint, node *vectorNode) int {
+	if level == 0 {
+		return len(node.vals)
+	} else if node.sizes != nil {
+		return node.sizes[len(node.sizes)-1]
+	}
+	last := len(node.children) - 1
+	if last < 0 {
+		return 0
+	}
+	return last<<level + vectorNodeSize(level-trieBits, node.children[last])
+}
+
+// newVectorBranch makes a branch at level holding children, relaxed unless
+// every child but the last is full.
+func newVectorBranch(level uint, children []*vectorNode) *vectorNode {
+	sizes := make([]int, len(children))
+	total := 0
+	regular := true
+	for i, child := range children {
+		size := vectorNodeSize(level-trieBits, child)
+		regular = regular && (i == len(children)-1 || size == 1<<level)
+		total += size
+		sizes[i] = total
+	}
+	if regular {
+		sizes = nil
+	}
+	return &vectorNode{children: children, sizes: sizes}
+}
+
+// Conj returns a Vector with val added to the end of vec.
+func (vec *Vector) Conj(val ListCell) *Vector {
+	if len(vec.tail) < trieWidth {
+		tail := make([]ListCell, len(vec.tail)+1)
+		copy(tail, vec.tail)
+		tail[len(vec.tail)] = val
+		return &Vector{count: vec.count + 1, shift: vec.shift, root: vec.root, tail: tail}
+	}
+	root, shift := vec.pushLeaf(&vectorNode{vals: vec.tail})
+	return &Vector{count: vec.count + 1, shift: shift, root: root, tail: []ListCell{val}}
+}
+
+// pushLeaf returns the root and shift of vec's trie with leaf added after
+// its last element, adding a level if the trie is full.
+func (vec *Vector) pushLeaf(leaf *vectorNode) (*vectorNode, uint) {
+	if root := pushVectorLeaf(vec.shift, vec.root, leaf); root != nil {
+		return root, vec.shift
+	}
+	shift := vec.shift + trieBits
+	return newVectorBranch(shift, []*vectorNode{vec.root, newVectorPath(vec.shift, leaf)}), shift
+}
+
+// pushVectorLeaf returns node with leaf added after its last element, or
+// nil if node has no room for it.
+func pushVectorLeaf(level uint, node, leaf *vectorNode) *vectorNode {
+	last := len(node.children) - 1
+	children := make([]*vectorNode, len(node.children), len(node.children)+1)
+	copy(children, node.children)
+	if level > trieBits && last >= 0 {
+		if child := pushVectorLeaf(level-trieBits, node.children[last], leaf); child != nil {
+			children[last] = child
+			sizes := append([]int(nil), node.sizes...)
+			if sizes != nil {
+				sizes[last] += len(leaf.vals)
+			}
+			return &vectorNode{children: children, sizes: sizes}
+		}
+	}
+	if len(children) == trieWidth {
+		return nil
+	}
+	children = append(children, newVectorPath(level-trieBits, leaf))
+	if node.sizes == nil && (last < 0 || vectorNodeSize(level-trieBits, node.children[last]) == 1<<level) {
+		return &vectorNode{children: children}
+	}
+	return newVectorBranch(level, children)
+}
+
+func newVectorPath(level uint, node *vectorNode) *vectorNode {
+	if level == 0 {
+		return node
+	}
+	return &vectorNode{children: []*vectorNode{newVectorPath(level-trieBits, node)}}
+}
+
+// Assoc returns a Vector with the element at index i, which must be in
+// range, replaced by val.
+func (vec *Vector) Assoc(i int, val ListCell) *Vector {
+	if offset := vec.tailOffset(); i >= offset {
+		tail := make([]ListCell, len(vec.tail))
+		copy(tail, vec.tail)
+		tail[i-offset] = val
+		return &Vector{count: vec.count, shift: vec.shift, root: vec.root, tail: tail}
+	}
+	return &Vector{count: vec.count, shift: vec.shift, root: assocVectorNode(vec.shift, vec.root, i, val), tail: vec.tail}
+}
+
+func assocVectorNode(level uint, node *vectorNode, i int, val ListCell) *vectorNode {
+	if level == 0 {
+		vals := make([]ListCell, len(node.vals))
+		copy(vals, node.vals)
+		vals[i] = val
+		return &vectorNode{vals: vals}
+	}
+	subidx, i := node.child(i, level)
+	children := make([]*vectorNode, len(node.children))
+	copy(children, node.children)
+	children[subidx] = assocVectorNode(level-trieBits, node.children[subidx], i, val)
+	return &vectorNode{children: children, sizes: node.sizes}
+}
+
+// Subvec returns a Vector of the elements of vec from start up to but not
+// including end, which must satisfy 0 <= start <= end <= vec.Len(). Only
+// the nodes along the two edges of the range are copied.
+func (vec *Vector) Subvec(start, end int) *Vector {
+	if start == end {
+		return emptyVector
+	}
+	offset := vec.tailOffset()
+	var tail []ListCell
+	if end > offset {
+		tail = vec.tail[max(start-offset, 0) : end-offset : end-offset]
+		end = offset
+	}
+	if start >= end {
+		return &Vector{count: len(tail), shift: trieBits, root: &vectorNode{}, tail: tail}
+	}
+	root, shift := trimVectorRoot(vec.shift, sliceVectorNode(vec.shift, vec.root, start, end))
+	return &Vector{count: end - start + len(tail), shift: shift, root: root, tail: tail}
+}
+
+// sliceVectorNode returns a node holding the elements of node from start
+// up to but not including end, which must be after start.
+func sliceVectorNode(level uint, node *vectorNode, start, end int) *vectorNode {
+	if level == 0 {
+		return &vectorNode{vals: node.vals[start:end:end]}
+	}
+	first, start := node.child(start, level)
+	last, end := node.child(end-1, level)
+	end++
+	children := make([]*vectorNode, last-first+1)
+	copy(children, node.children[first:last+1])
+	if first == last {
+		children[0] = sliceVectorNode(level-trieBits, children[0], start, end)
+	} else {
+		children[0] = sliceVectorNode(level-trieBits, children[0], start, vectorNodeSize(level-trieBits, children[0]))
+		children[len(children)-1] = sliceVectorNode(level-trieBits, children[len(children)-1], 0, end)
+	}
+	return newVectorBranch(level, children)
+}
+
+// trimVectorRoot drops the levels above root that have only one child.
+func trimVectorRoot(shift uint, root *vectorNode) (*vectorNode, uint) {
+	for shift > trieBits && len(root.children) == 1 {
+		root = root.children[0]
+		shift -= trieBits
+	}
+	return root, shift
+}
+
+// Concat returns a Vector of the elements of vec followed by those of
+// other. The tries are joined along the right edge of vec's and the left
+// edge of other's, rebalancing only the nodes there, in O(log n) time.
+func (vec *Vector) Concat(other *Vector) *Vector {
+	if vec.count == 0 {
+		return other
+	} else if other.tailOffset() == 0 {
+		for _, val := range other.tail {
+			vec = vec.Conj(val)
+		}
+		return vec
+	}
+	left, leftShift := vec.root, vec.shift
+	if len(vec.tail) > 0 {
+		left, leftShift = vec.pushLeaf(&vectorNode{vals: vec.tail})
+	}
+	right, rightShift := other.root, other.shift
+	for ; leftShift < rightShift; leftShift += trieBits {
+		left = &vectorNode{children: []*vectorNode{left}}
+	}
+	for ; rightShift < leftShift; rightShift += trieBits {
+		right = &vectorNode{children: []*vectorNode{right}}
+	}
+	nodes := mergeVectorNodes(leftShift, left, right)
+	root, shift := nodes[0], leftShift
+	if len(nodes) > 1 {
+		shift += trieBits
+		root = newVectorBranch(shift, nodes)
+	}
+	root, shift = trimVectorRoot(shift, root)
+	return &Vector{count: vec.count + other.count, shift: shift, root: root, tail: other.tail}
+}
+
+// mergeVectorNodes joins two branches at the same level, returning one or
+// two branches holding the elements of left followed by those of right.
+func mergeVectorNodes(level uint, left, right *vectorNode) []*vectorNode {
+	last := len(left.children) - 1
+	middle := []*vectorNode{left.children[last], right.children[0]}
+	if level > trieBits {
+		middle = mergeVectorNodes(level-trieBits, left.children[last], right.children[0])
+	}
+	children := make([]*vectorNode, 0, len(left.children)+len(right.children))
+	children = append(children, left.children[:last]...)
+	children = append(children, middle...)
+	children = append(children, right.children[1:]...)
+	children = rebalanceVectorNodes(level-trieBits, children)
+	if len(children) <= trieWidth {
+		return []*vectorNode{newVectorBranch(level, children)}
+	}
+	return []*vectorNode{newVectorBranch(level, children[:trieWidth:trieWidth]), newVectorBranch(level, children[trieWidth:])}
+}
+
+// rebalanceVectorNodes moves the contents of the nodes where two tries
+// meet into fewer nodes, when there are more than vectorExtras more of them
+// than their contents need. Nodes the plan leaves as they are are reused.
+func rebalanceVectorNodes(level uint, nodes []*vectorNode) []*vectorNode {
+	sizes := make([]int, len(nodes))
+	for i, node := range nodes {
+		sizes[i] = len(node.children) + len(node.vals)
+	}
+	plan := rebalancePlan(sizes)
+	if len(plan) == len(nodes) {
+		return nodes
+	}
+	rebalanced := make([]*vectorNode, 0, len(plan))
+	next, offset := 0, 0
+	for _, size := range plan {
+		if offset == 0 && sizes[next] == size {
+			rebalanced = append(rebalanced, nodes[next])
+			next++
+			continue
+		}
+		var vals []ListCell
+		var children []*vectorNode
+		for filled := 0; filled < size; {
+			take := min(size-filled, sizes[next]-offset)
+			if level == 0 {
+				vals = append(vals, nodes[next].vals[offset:offset+take]...)
+			} else {
+				children = append(children, nodes[next].children[offset:offset+take]...)
+			}
+			filled += take
+			offset += take
+			if offset == sizes[next] {
+				next, offset = next+1, 0
+			}
+		}
+		if level == 0 {
+			rebalanced = append(rebalanced, &vectorNode{vals: vals})
+		} else {
+			rebalanced = append(rebalanced, newVectorBranch(level, children))
+		}
+	}
+	return rebalanced
+}
+
+// rebalancePlan returns how many entries each node should hold, given how
+// many each holds now. Working from the left, the first node that is not
+// full has its entries spread over the nodes that follow it, until there
+// are at most vectorExtras more nodes than the fewest that could hold
+// every entry.
+func rebalancePlan(sizes []int) []int {
+	total := 0
+	for _, size := range sizes {
+		total += size
+	}
+	fewest := (total + trieWidth - 1) / trieWidth
+	plan := append([]int(nil), sizes...)
+	for i := 0; len(plan) > fewest+vectorExtras; i-- {
+		for plan[i] == trieWidth {
+			i++
+		}
+		for remaining := plan[i]; remaining > 0; i++ {
+			size := min(remaining+plan[i+1], trieWidth)
+			remaining += plan[i+1] - size
+			plan[i] = size
+		}
+		plan = append(plan[:i], plan[i+1:]...)
+	}
+	return plan
+}
+
+// HashMap is a persistent hash map: a hash array mapped trie in which each
+// node holds a bitmap of which of its 32 slots are in use and an entry for
+// each of them. Updates copy only the path to the entry changed.
+type HashMap struct {
+	count int
+	root  *hamtNode
+}
+
+// hamtNode is a bitmap node, or a collision node holding entries whose
+// keys all have the same hash.
+type hamtNode struct {
+	bitmap    uint32
+	collision bool
+	entries   []hamtEntry
+}
+
+// hamtEntry is a key and value, or a child node. A collision child keeps
+// the hash its keys share.
+type hamtEntry struct {
+	hash  uint32
+	key   ListCell
+	value ListCell
+	child *hamtNode
+}
+
+var emptyHashMap = &HashMap{root: &hamtNode{}}
+
+// Len returns the number of entries in hashMap.
+func (hashMap *HashMap) Len() int {
+	return hashMap.count
+}
+
+// Get returns the value key maps to.
+func (hashMap *HashMap) Get(key ListCell) (ListCell, bool) {
+	hash, err := hashValue(&key)
+	if err != nil {
+		return ListCell{}, false
+	}
+	return hashMap.root.find(hash, 0, &key)
+}
+
+// Assoc returns a HashMap with key mapped to value. The key must be a value
+// that can be hashed, which functions cannot.
+func (hashMap *HashMap) Assoc(key, value ListCell) (*HashMap, error) {
+	hash, err := hashValue(&key)
+	if err != nil {
+		return nil, err
+	}
+	root, added := hashMap.root.assoc(hash, 0, key, value)
+	count := hashMap.count
+	if added {
+		count++
+	}
+	return &HashMap{count: count, root: root}, nil
+}
+
+// Dissoc returns a HashMap without key.
+func (hashMap *HashMap) Dissoc(key ListCell) *HashMap {
+	hash, err := hashValue(&key)
+	if err != nil {
+		return hashMap
+	}
+	root, removed := hashMap.root.dissoc(hash, 0, &key)
+	if !removed {
+		return hashMap
+	}
+	if root == nil {
+		root = &hamtNode{}
+	}
+	return &HashMap{count: hashMap.count - 1, root: root}
+}
+
+// Each calls visit on every entry of hashMap, in an order that depends
+// only on the keys.
+func (hashMap *HashMap) Each(visit func(key, value ListCell)) {
+	hashMap.root.each(visit)
+}
+
+func (node *hamtNode) slot(hash uint32, shift uint) (bit uint32, index int) {
+	bit = 1 << ((hash >> shift) & trieMask)
+	return bit, bits.OnesCount32(node.bitmap & (bit - 1))
+}
+
+func (node *hamtNode) find(hash uint32, shift uint, key *ListCell) (ListCell, bool) {
+	if node.collision {
+		for i := range node.entries {
+			if node.entries[i].hash == hash && cellsEqual(&node.entries[i].key, key) {
+				return node.entries[i].value, true
+			}
+		}
+		return ListCell{}, false
+	}
+	bit, index := node.slot(hash, shift)
+	if node.bitmap&bit == 0 {
+		return ListCell{}, false
+	}
+	entry := &node.entries[index]
+	if entry.child != nil {
+		return entry.child.find(hash, shift+trieBits, key)
+	}
+	if entry.hash == hash && cellsEqual(&entry.key, key) {
+		return entry.value, true
+	}
+	return ListCell{}, false
+}
+
+func (node *hamtNode) assoc(hash uint32, shift uint, key, value ListCell) (*hamtNode, bool) {
+	newEntry := hamtEntry{hash: hash, key: key, value: value}
+	if node.collision {
+		if node.entries[0].hash != hash {
+			return mergeHamtEntries(shift, hamtEntry{hash: node.entries[0].hash, child: node}, newEntry), true
+		}
+		entries := make([]hamtEntry, len(node.entries), len(node.entries)+1)
+		copy(entries, node.entries)
+		for i := range entries {
+			if cellsEqual(&entries[i].key, &key) {
+				entries[i] = newEntry
+				return &hamtNode{collision: true, entries: entries}, false
+			}
+		}
+		return &hamtNode{collision: true, entries: append(entries, newEntry)}, true
+	}
+	bit, index := node.slot(hash, shift)
+	if node.bitmap&bit == 0 {
+		entries := make([]hamtEntry, len(node.entries)+1)
+		copy(entries, node.entries[:index])
+		entries[index] = newEntry
+		copy(entries[index+1:], node.entries[index:])
+		return &hamtNode{bitmap: node.bitmap | bit, entries: entries}, true
+	}
+	entry := node.entries[index]
+	added := true
+	switch {
+	case entry.child != nil:
+		var child *hamtNode
+		child, added = entry.child.assoc(hash, shift+trieBits, key, value)
+		newEntry = hamtEntry{hash: entry.hash, child: child}
+	case entry.hash == hash && cellsEqual(&entry.key, &key):
+		added = false
+	default:
+		newEntry = hamtEntry{child: mergeHamtEntries(shift+trieBits, entry, newEntry)}
+	}
+	entries := make([]hamtEntry, len(node.entries))
+	copy(entries, node.entries)
+	entries[index] = newEntry
+	return &hamtNode{bitmap: node.bitmap, entries: entries}, added
+}
+
+// mergeHamtEntries makes a node at shift holding two entries that shared a
+// slot in the level above.
+func mergeHamtEntries(shift uint, a, b hamtEntry) *hamtNode {
+	if a.hash == b.hash && a.child == nil {
+		return &hamtNode{collision: true, entries: []hamtEntry{a, b}}
+	}
+	slotA, slotB := (a.hash>>shift)&trieMask, (b.hash>>shift)&trieMask
+	if slotA == slotB {
+		return &hamtNode{bitmap: 1 << slotA, entries: []hamtEntry{{child: mergeHamtEntries(shift+trieBits, a, b)}}}
+	} else if slotA > slotB {
+		a, b = b, a
+	}
+	return &hamtNode{bitmap: 1<<slotA | 1<<slotB, entries: []hamtEntry{a, b}}
+}
+
+// dissoc returns node without key, or nil if that leaves it empty.
+func (node *hamtNode) dissoc(hash uint32, shift uint, key *ListCell) (*hamtNode, bool) {
+	if node.collision {
+		for i := range node.entries {
+			if node.entries[i].hash == hash && cellsEqual(&node.entries[i].key, key) {
+				if len(node.entries) == 1 {
+					return nil, true
+				}
+				entries := append(append([]hamtEntry{}, node.entries[:i]...), node.entries[i+1:]...)
+				return &hamtNode{collision: true, entries: entries}, true
+			}
+		}
+		return node, false
+	}
+	bit, index := node.slot(hash, shift)
+	if node.bitmap&bit == 0 {
+		return node, false
+	}
+	entry := node.entries[index]
+	if entry.child != nil {
+		child, removed := entry.child.dissoc(hash, shift+trieBits, key)
+		if !removed {
+			return node, false
+		}
+		if child != nil {
+			newEntry := hamtEntry{hash: entry.hash, child: child}
+			if len(child.entries) == 1 && child.entries[0].child == nil {
+				newEntry = child.entries[0]
+			}
+			entries := make([]hamtEntry, len(node.entries))
+			copy(entries, node.entries)
+			entries[index] = newEntry
+			return &hamtNode{bitmap: node.bitmap, entries: entries}, true
+		}
+	} else if entry.hash != hash || !cellsEqual(&entry.key, key) {
+		return node, false
+	}
+	if len(node.entries) == 1 {
+		return nil, true
+	}
+	entries := append(append([]hamtEntry{}, node.entries[:index]...), node.entries[index+1:]...)
+	return &hamtNode{bitmap: node.bitmap &^ bit, entries: entries}, true
+}
+
+func (node *hamtNode) each(visit func(key, value ListCell)) {
+	for i := range node.entries {
+		if node.entries[i].child != nil {
+			node.entries[i].child.each(visit)
+		} else {
+			visit(node.entries[i].key, node.entries[i].value)
+		}
+	}
+}
+
+const (
+	fnvOffset uint32 = 2166136261
+	fnvPrime  uint32 = 16777619
+)
+
+func fnvUint64(hash uint32, val uint64) uint32 {
+	for i := 0; i < 8; i++ {
+		hash ^= uint32(val & 0xFF)
+		hash *= fnvPrime
+		val >>= 8
+	}
+	return hash
+}
+
+func fnvString(hash uint32, val string) uint32 {
+	for i := 0; i < len(val); i++ {
+		hash ^= uint32(val[i])
+		hash *= fnvPrime
+	}
+	return hash
+}
+
+var referenceHashSeed = maphash.MakeSeed()
+
+// hashValue hashes a value for use as a HashMap key, so that values
+// cellsEqual counts as equal hash alike. Values are hashed by content, and
+// so keep the same order in a map from one run to the next, except for
+// references such as atoms and channels, which are hashed by identity.
+func hashValue(cell *ListCell) (uint32, error) {
+	hash := fnvString(fnvOffset, cell.TypeName)
+	switch val := cell.Value.(type) {
+	case nil:
+		return hash, nil
+	case int:
+		return fnvUint64(hash, uint64(val)), nil
+	case float64:
+		if val == 0 {
+			val = 0
+		}
+		return fnvUint64(hash, math.Float64bits(val)), nil
+	case bool:
+		if val {
+			return fnvUint64(hash, 1), nil
+		}
+		return fnvUint64(hash, 0), nil
+	case rune:
+		return fnvUint64(hash, uint64(val)), nil
+	case string:
+		return fnvString(hash, val), nil
+	case *SymbolObj:
+		return fnvString(hash, val.Name()), nil
+	case *KeywordObj:
+		return fnvString(hash, val.Name()), nil
+	case []ListCell:
+		return hashSequence(hash, val)
+	case *Vector:
+		return hashSequence(hash, val.Slice())
+	case *HashMap:
+		//Entries are combined by addition so that the order they are
+		//visited in does not matter.
+		var sum uint32
+		var err error
+		val.Each(func(key, value ListCell) {
+			keyHash, keyErr := hashValue(&key)
+			valueHash, valueErr := hashValue(&value)
+			if keyErr != nil || valueErr != nil {
+				err = errors.Join(keyErr, valueErr)
+			}
+			sum += keyHash*31 + valueHash
+		})
+		return fnvUint64(hash, uint64(sum)), err
+	case FunctionObj:
+		err := fmt.Sprintf("Error: a %v cannot be used as a map key.\n", cell.TypeName)
+		return 0, errors.New(err)
+	default:
+		if !reflect.TypeOf(val).Comparable() {
+			err := fmt.Sprintf("Error: a %v cannot be used as a map key.\n", cell.TypeName)
+			return 0, errors.New(err)
+		}
+		return fnvUint64(hash, maphash.Comparable[any](referenceHashSeed, val)), nil
+	}
+}
+
+func hashSequence(hash uint32, vals []ListCell) (uint32, error) {
+	for i := range vals {
+		elemHash, err := hashValue(&vals[i])
+		if err != nil {
+			return 0, err
+		}
+		hash = fnvUint64(hash, uint64(elemHash))
+	}
+	return hash, nil
+}
+
+// collectionsEqual compares vectors and maps by content for cellsEqual.
+func collectionsEqual(Cell1, Cell2 *ListCell) (equal, ok bool) {
+	switch val1 := Cell1.Value.(type) {
+	case *Vector:
+		val2, isVector := Cell2.Value.(*Vector)
+		if !isVector || val1.Len() != val2.Len() {
+			return false, true
+		}
+		for i := 0; i < val1.Len(); i++ {
+			elem1, elem2 := val1.Nth(i), val2.Nth(i)
+			if !cellsEqual(&elem1, &elem2) {
+				return false, true
+			}
+		}
+		return true, true
+	case *HashMap:
+		val2, isMap := Cell2.Value.(*HashMap)
+		if !isMap || val1.Len() != val2.Len() {
+			return false, true
+		}
+		equal = true
+		val1.Each(func(key, value1 ListCell) {
+			value2, found := val2.Get(key)
+			equal = equal && found && cellsEqual(&value1, &value2)
+		})
+		return equal, true
+	default:
+		return false, false
+	}
+}
+
+func makeVectorCell(vals []ListCell, env *Environment) ListCell {
+	env.evalState().allocList(len(vals))
+	return ListCell{TypeName: VECTOR_TYPE_NAME, Value: NewVector(vals...)}
+}
+
+// makeMapCell builds a map from alternating keys and values. A key given
+// more than once takes the last value given for it.
+func makeMapCell(keysAndValues []ListCell, env *Environment) (ListCell, error) {
+	env.evalState().allocList(len(keysAndValues) / 2)
+	hashMap := emptyHashMap
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		var err error
+		keysAndValues[i].Mutable = false
+		keysAndValues[i+1].Mutable = false
+		hashMap, err = hashMap.Assoc(keysAndValues[i], keysAndValues[i+1])
+		if err != nil {
+			return ListCell{}, err
+		}
+	}
+	return ListCell{TypeName: MAP_TYPE_NAME, Value: hashMap}, nil
+}
+
+// collectionLen returns the number of elements in a list, vector, map or
+// string.
+func collectionLen(name string, cell *ListCell) (int, error) {
+	switch val := cell.Value.(type) {
+	case []ListCell:
+		return len(val), nil
+	case *Vector:
+		return val.Len(), nil
+	case *HashMap:
+		return val.Len(), nil
+	case string:
+		return utf8.RuneCountInString(val), nil
+	}
+	err := fmt.Sprintf("Error: %v expects a List, Vector, Map or string but got %v.\n", name, cell.TypeName)
+	return 0, errors.New(err)
+}
+
+func indexArg(name string, cell *ListCell) (int, error) {
+	index, ok := cell.Value.(int)
+	if !ok {
+		err := fmt.Sprintf("Error: %v expects an int index but got %v.\n", name, cell.TypeName)
+		return 0, errors.New(err)
+	}
+	return index, nil
+}
+
+// GoGet looks up a key in a map, or an index in a vector or list,
+// returning the default given, or nil, if it is missing.
+func GoGet(parameters []ListCell) ([]*ListCell, error) {
+	if len(parameters) < 2 || len(parameters) > 3 {
+		err := fmt.Sprintf("Error: builtin get expects a collection, a key and an optional default but was called with %v arguments.\n", len(parameters))
+		return nil, errors.New(err)
+	}
+	returnVal := makeNilCell()
+	if len(parameters) == 3 {
+		returnVal = parameters[2]
+	}
+	key := &parameters[1]
+	switch coll := parameters[0].Value.(type) {
+	case *HashMap:
+		if val, ok := coll.Get(*key); ok {
+			returnVal = val
+		}
+	case *Vector:
+		if index, ok := key.Value.(int); ok && index >= 0 && index < coll.Len() {
+			returnVal = coll.Nth(index)
+		}
+	case []ListCell:
+		if index, ok := key.Value.(int); ok && index >= 0 && index < len(coll) {
+			returnVal = coll[index]
+		}
+	default:
+		err := fmt.Sprintf("Error: get expects a Map, Vector or List but got %v.\n", parameters[0].TypeName)
+		return nil, errors.New(err)
+	}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoAssoc maps keys to values in a map, or sets elements of a vector,
+// returning the new collection. A vector can be extended by one element by
+// setting the index one past its end.
+func GoAssoc(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if len(parameters) < 3 || len(parameters)%2 != 1 {
+		err := fmt.Sprintf("Error: builtin assoc expects a collection followed by keys and values but was called with %v arguments.\n", len(parameters))
+		return nil, errors.New(err)
+	}
+	returnVal := parameters[0]
+	returnVal.Mutable = false
+	for i := 1; i < len(parameters); i += 2 {
+		value := parameters[i+1]
+		value.Mutable = false
+		switch coll := returnVal.Value.(type) {
+		case *HashMap:
+			key := parameters[i]
+			key.Mutable = false
+			newMap, err := coll.Assoc(key, value)
+			if err != nil {
+				return nil, err
+			}
+			returnVal.Value = newMap
+		case *Vector:
+			index, err := indexArg("assoc", &parameters[i])
+			if err != nil {
+				return nil, err
+			}
+			if index < 0 || index > coll.Len() {
+				err := fmt.Sprintf("Error: assoc index %v is out of range for a Vector of %v elements.\n", index, coll.Len())
+				return nil, errors.New(err)
+			} else if index == coll.Len() {
+				returnVal.Value = coll.Conj(value)
+			} else {
+				returnVal.Value = coll.Assoc(index, value)
+			}
+		default:
+			err := fmt.Sprintf("Error: assoc expects a Map or Vector but got %v.\n", returnVal.TypeName)
+			return nil, errors.New(err)
+		}
+	}
+	env.evalState().alloc(len(parameters) / 2)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoDissoc removes keys from a map.
+func GoDissoc(parameters []ListCell) ([]*ListCell, error) {
+	if len(parameters) == 0 {
+		return nil, errors.New("Error: builtin dissoc expects a Map and keys to remove.\n")
+	}
+	hashMap, ok := parameters[0].Value.(*HashMap)
+	if !ok {
+		err := fmt.Sprintf("Error: dissoc expects a Map but got %v.\n", parameters[0].TypeName)
+		return nil, errors.New(err)
+	}
+	for i := 1; i < len(parameters); i++ {
+		hashMap = hashMap.Dissoc(parameters[i])
+	}
+	returnVal := ListCell{TypeName: MAP_TYPE_NAME, Value: hashMap}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoConj adds elements to a collection where it is cheapest: the end of a
+// vector or the front of a list. Elements added to a map must be vectors
+// of a key and a value.
+func GoConj(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if len(parameters) == 0 {
+		return nil, errors.New("Error: builtin conj expects a collection and elements to add.\n")
+	}
+	returnVal := parameters[0]
+	returnVal.Mutable = false
+	elems := parameters[1:]
+	switch coll := returnVal.Value.(type) {
+	case *Vector:
+		for _, elem := range elems {
+			elem.Mutable = false
+			coll = coll.Conj(elem)
+		}
+		returnVal.Value = coll
+	case []ListCell:
+		env.evalState().allocList(len(coll) + len(elems))
+		list := make([]ListCell, 0, len(coll)+len(elems))
+		for i := len(elems) - 1; i >= 0; i-- {
+			list = append(list, elems[i])
+			list[len(list)-1].Mutable = false
+		}
+		returnVal.Value = append(list, coll...)
+		return []*ListCell{&returnVal}, nil
+	case *HashMap:
+		for _, elem := range elems {
+			pair, ok := elem.Value.(*Vector)
+			if !ok || pair.Len() != 2 {
+				err := fmt.Sprintf("Error: conj expects a Vector of a key and a value to add to a Map but got %v.\n", elem.TypeName)
+				return nil, errors.New(err)
+			}
+			var err error
+			coll, err = coll.Assoc(pair.Nth(0), pair.Nth(1))
+			if err != nil {
+				return nil, err
+			}
+		}
+		returnVal.Value = coll
+	default:
+		err := fmt.Sprintf("Error: conj expects a Vector, List or Map but got %v.\n", returnVal.TypeName)
+		return nil, errors.New(err)
+	}
+	env.evalState().alloc(len(elems))
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoCount returns the number of elements in a collection or characters in
+// a string.
+func GoCount(Cell1 *ListCell) ([]*ListCell, error) {
+	count, err := collectionLen("count", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := ListCell{TypeName: INT_TYPE_NAME, Value: count}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoSubvec returns the elements of a vector from start up to but not
+// including end, which defaults to the end of the vector. The result shares
+// all but the nodes along its edges with the vector.
+func GoSubvec(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if len(parameters) != 2 && len(parameters) != 3 {
+		err := fmt.Sprintf("Error: builtin subvec expects a Vector, a start and an optional end but was called with %v arguments.\n", len(parameters))
+		return nil, errors.New(err)
+	}
+	vec, ok := parameters[0].Value.(*Vector)
+	if !ok {
+		err := fmt.Sprintf("Error: subvec expects a Vector but got %v.\n", parameters[0].TypeName)
+		return nil, errors.New(err)
+	}
+	start, err := indexArg("subvec", &parameters[1])
+	if err != nil {
+		return nil, err
+	}
+	end := vec.Len()
+	if len(parameters) == 3 {
+		end, err = indexArg("subvec", &parameters[2])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if start < 0 || end > vec.Len() || start > end {
+		err := fmt.Sprintf("Error: subvec range %v to %v is out of range for a Vector of %v elements.\n", start, end, vec.Len())
+		return nil, errors.New(err)
+	}
+	env.evalState().alloc(1)
+	returnVal := ListCell{TypeName: VECTOR_TYPE_NAME, Value: vec.Subvec(start, end)}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoCatvec joins vectors end to end. The result shares all but the nodes
+// along the edges where they meet with the vectors joined.
+func GoCatvec(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	vec := emptyVector
+	for i := range parameters {
+		next, ok := parameters[i].Value.(*Vector)
+		if !ok {
+			err := fmt.Sprintf("Error: catvec expects Vectors but got %v.\n", parameters[i].TypeName)
+			return nil, errors.New(err)
+		}
+		if vec.Len() > math.MaxInt32-next.Len() {
+			return nil, errors.New("Error: catvec would make a Vector with more elements than it can hold.\n")
+		}
+		vec = vec.Concat(next)
+	}
+	state := env.evalState()
+	state.checkLength(vec.Len())
+	state.alloc(len(parameters))
+	returnVal := ListCell{TypeName: VECTOR_TYPE_NAME, Value: vec}
+	return []*ListCell{&returnVal}, nil
+}
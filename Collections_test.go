@@ -0,0 +1,289 @@
+package Golly
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestVectorConjAndAssoc(t *testing.T) {
+	//Enough elements for the trie to grow past two levels.
+	const n = 40000
+	vec := emptyVector
+	versions := make([]*Vector, 0, n)
+	for i := 0; i < n; i++ {
+		vec = vec.Conj(IntValue(i))
+		versions = append(versions, vec)
+	}
+	if vec.Len() != n {
+		t.Fatalf("Len = %v, want %v", vec.Len(), n)
+	}
+	for i := 0; i < n; i++ {
+		if got := vec.Nth(i).Value; got != i {
+			t.Fatalf("Nth(%v) = %v", i, got)
+		}
+	}
+	if versions[99].Len() != 100 || versions[99].Nth(99).Value != 99 {
+		t.Errorf("an earlier version changed when the vector grew")
+	}
+	changed := vec.Assoc(1234, IntValue(-1)).Assoc(n-1, IntValue(-2))
+	if changed.Nth(1234).Value != -1 || changed.Nth(n-1).Value != -2 || changed.Nth(1235).Value != 1235 {
+		t.Errorf("Assoc did not set the elements asked for")
+	}
+	if vec.Nth(1234).Value != 1234 || vec.Nth(n-1).Value != n-1 {
+		t.Errorf("Assoc changed the vector it was called on")
+	}
+	slice := changed.Slice()
+	if len(slice) != n || slice[1234].Value != -1 || slice[0].Value != 0 {
+		t.Errorf("Slice does not match the elements")
+	}
+}
+
+// checkVectorNode checks that the size tables under node match the
+// elements under it, that nodes without one are full but for their last
+// child, and that no node is empty. It returns the number of elements.
+func checkVectorNode(t *testing.T, level uint, node *vectorNode) int {
+	t.Helper()
+	if level == 0 {
+		if len(node.vals) == 0 || len(node.vals) > trieWidth {
+			t.Fatalf("a leaf holds %v elements", len(node.vals))
+		}
+		return len(node.vals)
+	}
+	if len(node.children) == 0 || len(node.children) > trieWidth {
+		t.Fatalf("a branch holds %v children", len(node.children))
+	}
+	total := 0
+	for i, child := range node.children {
+		size := checkVectorNode(t, level-trieBits, child)
+		total += size
+		if node.sizes != nil && node.sizes[i] != total {
+			t.Fatalf("a size table says %v where there are %v elements", node.sizes[i], total)
+		} else if node.sizes == nil && i < len(node.children)-1 && size != 1<<level {
+			t.Fatalf("a branch without a size table has a child of %v elements", size)
+		}
+	}
+	return total
+}
+
+func checkVector(t *testing.T, vec *Vector, want []ListCell) {
+	t.Helper()
+	if vec.Len() != len(want) {
+		t.Fatalf("Len = %v, want %v", vec.Len(), len(want))
+	}
+	if vec.tailOffset() > 0 && checkVectorNode(t, vec.shift, vec.root) != vec.tailOffset() {
+		t.Fatalf("the trie does not hold the elements before the tail")
+	}
+	for i := range want {
+		if got := vec.Nth(i).Value; got != want[i].Value {
+			t.Fatalf("Nth(%v) = %v, want %v", i, got, want[i].Value)
+		}
+	}
+	slice := vec.Slice()
+	for i := range want {
+		if slice[i].Value != want[i].Value {
+			t.Fatalf("Slice()[%v] = %v, want %v", i, slice[i].Value, want[i].Value)
+		}
+	}
+}
+
+func TestVectorConcatAndSubvec(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	next := 0
+	makeVector := func() (*Vector, []ListCell) {
+		vals := make([]ListCell, random.Intn(3000))
+		for i := range vals {
+			vals[i] = IntValue(next)
+			next++
+		}
+		return NewVector(vals...), vals
+	}
+	vec, want := makeVector()
+	for step := 0; step < 300; step++ {
+		switch random.Intn(4) {
+		case 0, 1:
+			other, vals := makeVector()
+			vec, want = vec.Concat(other), append(append([]ListCell{}, want...), vals...)
+		case 2:
+			start := random.Intn(len(want) + 1)
+			end := start + random.Intn(len(want)-start+1)
+			vec, want = vec.Subvec(start, end), want[start:end:end]
+		case 3:
+			for i := random.Intn(100); i > 0; i-- {
+				vec, want = vec.Conj(IntValue(next)), append(want[:len(want):len(want)], IntValue(next))
+				next++
+			}
+			if len(want) > 0 {
+				i := random.Intn(len(want))
+				vec, want = vec.Assoc(i, IntValue(-i)), append([]ListCell{}, want...)
+				want[i] = IntValue(-i)
+			}
+		}
+		checkVector(t, vec, want)
+	}
+}
+
+// vectorNodes adds the nodes of vec to seen and returns how many were not
+// there already.
+func vectorNodes(vec *Vector, seen map[*vectorNode]bool) int {
+	added := 0
+	var visit func(level uint, node *vectorNode)
+	visit = func(level uint, node *vectorNode) {
+		if seen[node] {
+			return
+		}
+		seen[node] = true
+		added++
+		if level > 0 {
+			for _, child := range node.children {
+				visit(level-trieBits, child)
+			}
+		}
+	}
+	visit(vec.shift, vec.root)
+	return added
+}
+
+func TestVectorConcatAndSubvecShareNodes(t *testing.T) {
+	//Copying the elements would make over 3000 new nodes of each result.
+	vals := make([]ListCell, 100003)
+	for i := range vals {
+		vals[i] = IntValue(i)
+	}
+	left, right := NewVector(vals[:50001]...), NewVector(vals[50001:]...)
+	seen := make(map[*vectorNode]bool)
+	vectorNodes(left, seen)
+	vectorNodes(right, seen)
+	joined := left.Concat(right)
+	if added := vectorNodes(joined, seen); added > 100 {
+		t.Errorf("Concat made %v new nodes", added)
+	}
+	sliced := joined.Subvec(777, 99999)
+	if added := vectorNodes(sliced, seen); added > 100 {
+		t.Errorf("Subvec made %v new nodes", added)
+	}
+	checkVector(t, joined, vals)
+	checkVector(t, sliced, vals[777:99999])
+}
+
+func TestHashMapAssocAndDissoc(t *testing.T) {
+	const n = 5000
+	hashMap := emptyHashMap
+	for i := 0; i < n; i++ {
+		var err error
+		if hashMap, err = hashMap.Assoc(IntValue(i), IntValue(i*i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hashMap.Len() != n {
+		t.Fatalf("Len = %v, want %v", hashMap.Len(), n)
+	}
+	for i := 0; i < n; i++ {
+		if val, ok := hashMap.Get(IntValue(i)); !ok || val.Value != i*i {
+			t.Fatalf("Get(%v) = %v, %v", i, val.Value, ok)
+		}
+	}
+	smaller := hashMap
+	for i := 0; i < n; i += 2 {
+		smaller = smaller.Dissoc(IntValue(i))
+	}
+	if smaller.Len() != n/2 {
+		t.Errorf("Len after Dissoc = %v, want %v", smaller.Len(), n/2)
+	}
+	if _, ok := smaller.Get(IntValue(10)); ok {
+		t.Errorf("a dissociated key is still there")
+	}
+	if _, ok := hashMap.Get(IntValue(10)); !ok {
+		t.Errorf("Dissoc changed the map it was called on")
+	}
+	if same := smaller.Dissoc(IntValue(10)); same != smaller {
+		t.Errorf("dissociating a missing key made a new map")
+	}
+}
+
+func TestHashMapCollisions(t *testing.T) {
+	//Find two strings whose hashes collide. Among 2^18 scattered strings
+	//there are a few pairs.
+	seen := make(map[uint32]string)
+	var first, second string
+	for i := 0; i < 1<<18 && first == ""; i++ {
+		cell := StringValue(strconv.FormatUint(uint64(i)*0x9E3779B97F4A7C15, 36))
+		hash, _ := hashValue(&cell)
+		if other, ok := seen[hash]; ok {
+			first, second = other, cell.Value.(string)
+		}
+		seen[hash] = cell.Value.(string)
+	}
+	if first == "" {
+		t.Skip("no colliding hashes found")
+	}
+	hashMap, _ := emptyHashMap.Assoc(StringValue(first), StringValue("first"))
+	hashMap, _ = hashMap.Assoc(StringValue(second), StringValue("second"))
+	hashMap, _ = hashMap.Assoc(StringValue(first), StringValue("again"))
+	if hashMap.Len() != 2 {
+		t.Errorf("Len = %v, want 2", hashMap.Len())
+	}
+	if val, _ := hashMap.Get(StringValue(first)); val.Value != "again" {
+		t.Errorf("Get of the first colliding key = %v", val.Value)
+	}
+	if val, _ := hashMap.Get(StringValue(second)); val.Value != "second" {
+		t.Errorf("Get of the second colliding key = %v", val.Value)
+	}
+	hashMap = hashMap.Dissoc(StringValue(first))
+	if val, ok := hashMap.Get(StringValue(second)); !ok || val.Value != "second" || hashMap.Len() != 1 {
+		t.Errorf("removing one colliding key lost the other")
+	}
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{"(count (conj [1 2] 3))", 3},
+		{"(get (conj [1 2] 3) 2)", 3},
+		{"(get (assoc [1 2] 2 3) 2)", 3},
+		{"(get [1 2] 5 0)", 0},
+		{"(get (assoc {1 10} 2 20) 2)", 20},
+		{"(count (dissoc {1 10 2 20} 1))", 1},
+		{"(= {1 10 2 [2 3]} (assoc {2 [2 3]} 1 10))", true},
+		{"(= [1 2] (par (fn () 1) (fn () 2)))", false},
+		{"(get {[1 2] 5} [1 2])", 5},
+		{"(= (subvec [1 2 3 4] 1 3) [2 3])", true},
+		{"(= (subvec [1 2 3] 1) [2 3])", true},
+		{"(count (subvec [1 2 3] 3))", 0},
+		{"(= (catvec [1 2] [] [3 4]) [1 2 3 4])", true},
+		{"(get (catvec (conj [] 1) [2 3]) 2)", 3},
+		{"(count (catvec))", 0},
+	}
+	for _, backEnd := range backEnds {
+		for _, test := range tests {
+			env := NewEnvironment(CreateSystemFuncs())
+			result, err := EvalString(context.Background(), test.src, env, backEnd.opts)
+			if err != nil || result.Value != test.want {
+				t.Errorf("%v: %v returned %v, %v, want %v", backEnd.name, test.src, result.Value, err, test.want)
+			}
+		}
+	}
+}
+
+func TestCollectionBuiltinErrors(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"(subvec [1 2] 1 3)", "subvec range 1 to 3 is out of range"},
+		{"(subvec [1 2] 2 1)", "subvec range 2 to 1 is out of range"},
+		{"(subvec (list 1 2) 1)", "subvec expects a Vector but got List"},
+		{"(subvec [1 2])", "builtin subvec expects"},
+		{"(catvec [1] (list 2))", "catvec expects Vectors but got List"},
+	}
+	for _, backEnd := range backEnds {
+		for _, test := range tests {
+			env := NewEnvironment(CreateSystemFuncs())
+			_, err := EvalString(context.Background(), test.src, env, backEnd.opts)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("%v: %v returned %v, want an error mentioning %q", backEnd.name, test.src, err, test.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,477 @@
+package Golly
+
+import (
+	"Golly/parser"
+	"fmt"
+)
+
+// opCode is a single bytecode instruction. Any operands follow the opcode
+// in the code stream as big-endian uint16s.
+type opCode byte
+
+const (
+	opConst       opCode = iota // const index: push proto.consts[index]
+	opPop                       // discard the top of the stack
+	opLoadLocal                 // depth, index: push a slot from an enclosing scope
+	opStoreLocal                // depth, index, mutable: pop into a slot
+	opLoadGlobal                // name const: push a global binding
+	opDefGlobal                 // name const, mutable: bind the top of the stack globally
+	opCheckType                 // type name const, name const, def kind const: check the type name of the top of the stack
+	opJump                      // target
+	opJumpIfFalse               // target: pop a bool and jump if it is false
+	opPushScope                 // slot count: open a new let scope
+	opPopScope                  // close the innermost let scope
+	opClosure                   // proto index: push a closure over the current scope
+	opCall                      // argument count, callee name const
+	opReturn
+	opAdd
+	opSubtract
+	opMultiply
+	opDivide
+	opEqual
+	opLess
+	opGreater
+	opLessEq
+	opGreaterEq
+	opSelect // clause count n, n kinds, n targets: pop the clauses' channels and values, select and jump to the chosen clause
+	opDosync // pop a closure of no arguments and call it in a transaction
+	opVector // element count: pop the elements and push a vector of them
+	opMap    // key and value count: pop alternating keys and values and push a map of them
+)
+
+var builtinOps = map[goFuncType]opCode{
+	GoAddT:       opAdd,
+	GoSubtractT:  opSubtract,
+	GoMultiplyT:  opMultiply,
+	GoDivideT:    opDivide,
+	GoEqualT:     opEqual,
+	GoLessT:      opLess,
+	GoGreaterT:   opGreater,
+	GoLessEqT:    opLessEq,
+	GoGreaterEqT: opGreaterEq,
+}
+
+// funcProto is the compiled form of a function body or top-level form.
+type funcProto struct {
+	name   string
+	params []string
+	code   []byte
+	lines  []int
+	consts []ListCell
+	protos []*funcProto
+	pure   bool
+	memo   bool
+}
+
+type compiler struct {
+	proto *funcProto
+	env   *Environment
+	line  int
+}
+
+// Compile turns a parsed top-level form into bytecode for the stack VM.
+// Identifiers use the slots assigned by the resolver; builtins from the
+// environment's SysEnvironment are embedded as constants.
+func Compile(tok *Parser.Token, env *Environment) (*funcProto, error) {
+	if errs := resolveForm(tok, env); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return compileResolved(tok, env)
+}
+
+func compileResolved(tok *Parser.Token, env *Environment) (proto *funcProto, err error) {
+	defer recoverEvalError(&err)
+	comp := compiler{proto: &funcProto{name: "toplevel"}, env: env, line: tok.LineNum}
+	comp.compileToken(tok)
+	comp.emit(opReturn)
+	return comp.proto, nil
+}
+
+func (comp *compiler) emit(op opCode, operands ...int) int {
+	pos := len(comp.proto.code)
+	comp.proto.code = append(comp.proto.code, byte(op))
+	comp.proto.lines = append(comp.proto.lines, comp.line)
+	for _, operand := range operands {
+		if operand < 0 || operand > 0xFFFF {
+			errMsg := fmt.Sprintf("Error: bytecode operand %v out of range at line %v.\n", operand, comp.line)
+			panic(errMsg)
+		}
+		comp.proto.code = append(comp.proto.code, byte(operand>>8), byte(operand))
+		comp.proto.lines = append(comp.proto.lines, comp.line, comp.line)
+	}
+	return pos
+}
+
+func (comp *compiler) patchJump(pos int) {
+	comp.patchOperand(pos, 0)
+}
+
+// patchOperand points an operand of the instruction at pos at the end of
+// the code so far.
+func (comp *compiler) patchOperand(pos, operand int) {
+	target := len(comp.proto.code)
+	comp.proto.code[pos+1+2*operand] = byte(target >> 8)
+	comp.proto.code[pos+2+2*operand] = byte(target)
+}
+
+func (comp *compiler) addConst(val ListCell) int {
+	comp.proto.consts = append(comp.proto.consts, val)
+	return len(comp.proto.consts) - 1
+}
+
+func (comp *compiler) addName(name string) int {
+	for i, val := range comp.proto.consts {
+		if existing, ok := val.Value.(string); ok && val.TypeName == VAR_TYPE_NAME && existing == name {
+			return i
+		}
+	}
+	return comp.addConst(ListCell{TypeName: VAR_TYPE_NAME, Value: name})
+}
+
+func (comp *compiler) systemBinding(name string) *EnvBinding {
+	if comp.env == nil || comp.env.System == nil {
+		return nil
+	}
+	if binding, ok := comp.env.System.Bindings[name]; ok {
+		return &binding
+	}
+	return nil
+}
+
+func (comp *compiler) compileToken(tok *Parser.Token) {
+	if tok.LineNum > 0 {
+		comp.line = tok.LineNum
+	}
+	caller := "compile"
+	switch tok.Type {
+	case Parser.LiteralToken:
+		comp.emit(opConst, comp.addConst(evalLitToken(tok, comp.line, &caller)))
+	case Parser.IdToken:
+		comp.compileIdentifier(tok)
+	case Parser.ListToken:
+		comp.compileList(tok)
+	case Parser.VectorToken, Parser.MapToken:
+		for i := range tok.ListVals {
+			comp.compileToken(&tok.ListVals[i])
+		}
+		if tok.Type == Parser.VectorToken {
+			comp.emit(opVector, len(tok.ListVals))
+		} else {
+			comp.emit(opMap, len(tok.ListVals))
+		}
+	case Parser.DefToken, Parser.FormToken:
+		errMsg := fmt.Sprintf("Error: attempting to evaluate reserved name %v outside of a list at line %v.\n", tok.Value, comp.line)
+		panic(errMsg)
+	case Parser.TypeAnnToken:
+		errMsg := fmt.Sprintf("Error: misplaced type annotation marker at line %v.\n", comp.line)
+		panic(errMsg)
+	default:
+		errMsg := fmt.Sprintf("Error: unhandled token type for %v at line %v.\n", tok.Value, comp.line)
+		panic(errMsg)
+	}
+}
+
+func (comp *compiler) compileIdentifier(tok *Parser.Token) {
+	switch tok.Scope {
+	case Parser.SystemScope:
+		comp.emit(opConst, comp.addConst(comp.systemBinding(tok.Value).Binding))
+	case Parser.LocalScope:
+		comp.emit(opLoadLocal, tok.Depth, tok.Index)
+	default:
+		comp.emit(opLoadGlobal, comp.addName(tok.Value))
+	}
+}
+
+func (comp *compiler) compileBody(body []Parser.Token) {
+	if len(body) == 0 {
+		comp.emit(opConst, comp.addConst(makeNilCell()))
+		return
+	}
+	for i := range body {
+		if i > 0 {
+			comp.emit(opPop)
+		}
+		comp.compileToken(&body[i])
+	}
+}
+
+func (comp *compiler) compileList(list *Parser.Token) {
+	if len(list.ListVals) == 0 {
+		comp.emit(opConst, comp.addConst(ListCell{TypeName: LIST_TYPE_NAME, Value: []ListCell{}}))
+		return
+	}
+	firstVal := &list.ListVals[0]
+	switch firstVal.Type {
+	case Parser.LiteralToken:
+		errMsg := fmt.Sprintf("Error: attempting to evaluate a literal, %v, at line %v.\n", firstVal.Value, comp.line)
+		panic(errMsg)
+	case Parser.DefToken:
+		comp.compileDef(list)
+	case Parser.FormToken:
+		comp.compileForm(list)
+	case Parser.TypeAnnToken:
+		errMsg := fmt.Sprintf("Error: misplaced type annotation marker at line %v.\n", comp.line)
+		panic(errMsg)
+	default:
+		comp.compileApplication(list)
+	}
+}
+
+func isFnForm(tok *Parser.Token) bool {
+	return tok.Type == Parser.ListToken && len(tok.ListVals) > 0 &&
+		tok.ListVals[0].Type == Parser.FormToken && tok.ListVals[0].Value == "fn"
+}
+
+func (comp *compiler) compileDef(list *Parser.Token) {
+	defKind := list.ListVals[0].Value
+	global := defKind == "def" || defKind == "defm"
+	mut := defKind == "letm" || defKind == "defm"
+	if len(list.ListVals) < 2 || (!global && len(list.ListVals) < 3) {
+		errMsg := fmt.Sprintf("Error: too few arguments to %v at line %v.\n", defKind, comp.line)
+		panic(errMsg)
+	} else if list.ListVals[1].Type != Parser.ListToken {
+		errMsg := fmt.Sprintf("Error: first argument (%v) to %v at line %v is not a list.\n", list.ListVals[1].Value, defKind, comp.line)
+		panic(errMsg)
+	}
+	line := comp.line
+	var scopePos int
+	if !global {
+		scopePos = comp.emit(opPushScope, 0)
+	}
+	bindings := list.ListVals[1].ListVals
+	numBound := 0
+	numSlots := 0
+	boundNames := make(map[string]bool)
+	for i := 0; i < len(bindings); i++ {
+		nameTok := &bindings[i]
+		if nameTok.Type != Parser.IdToken {
+			errMsg := fmt.Sprintf("Error: attempting to assign to a non-identifier in %v at line %v.\n", defKind, comp.line)
+			panic(errMsg)
+		}
+		if comp.systemBinding(nameTok.Value) != nil {
+			errMsg := fmt.Sprintf("Error: attempting to assign to an immutable identifier %v in %v at line %v.\n", nameTok.Value, defKind, comp.line)
+			panic(errMsg)
+		}
+		if i+1 >= len(bindings) {
+			errMsg := fmt.Sprintf("Error: nothing to assign to %v in %v at line %v.\n", nameTok.Value, defKind, comp.line)
+			panic(errMsg)
+		}
+		typeName := ""
+		valueTok := &bindings[i+1]
+		if valueTok.Type == Parser.TypeAnnToken {
+			if i+3 >= len(bindings) {
+				errMsg := fmt.Sprintf("Error: no type and/or value provided in assignment to %v in %v at line %v.\n", nameTok.Value, defKind, comp.line)
+				panic(errMsg)
+			}
+			typeName = comp.checkTypeName(nameTok, &bindings[i+2], defKind)
+			valueTok = &bindings[i+3]
+			i += 2
+		}
+		i++
+		if numBound > 0 {
+			comp.emit(opPop)
+		}
+		numBound++
+		if !global {
+			if boundNames[nameTok.Value] && !mut {
+				errMsg := fmt.Sprintf("Error: attempting to assign to an immutable identifier %v in %v at line %v.\n", nameTok.Value, defKind, comp.line)
+				panic(errMsg)
+			}
+			boundNames[nameTok.Value] = true
+			if nameTok.Index >= numSlots {
+				numSlots = nameTok.Index + 1
+			}
+		}
+		comp.compileNamedValue(nameTok.Value, valueTok)
+		//Errors binding the value are reported at the line of the def, as the tree-walker does.
+		comp.line = line
+		if typeName != "" {
+			comp.emit(opCheckType, comp.addName(typeName), comp.addName(nameTok.Value), comp.addName(defKind))
+		}
+		mutFlag := 0
+		if mut {
+			mutFlag = 1
+		}
+		if global {
+			comp.emit(opDefGlobal, comp.addName(nameTok.Value), mutFlag)
+		} else {
+			comp.emit(opStoreLocal, 0, nameTok.Index, mutFlag)
+			comp.emit(opLoadLocal, 0, nameTok.Index)
+		}
+	}
+	if len(bindings) == 0 {
+		comp.emit(opConst, comp.addConst(makeNilCell()))
+	}
+	if len(list.ListVals) > 2 {
+		comp.emit(opPop)
+		comp.compileBody(list.ListVals[2:])
+	}
+	if !global {
+		comp.proto.code[scopePos+1] = byte(numSlots >> 8)
+		comp.proto.code[scopePos+2] = byte(numSlots)
+		comp.emit(opPopScope)
+	}
+}
+
+func (comp *compiler) checkTypeName(nameTok, typeTok *Parser.Token, defKind string) string {
+	if typeTok.Type != Parser.IdToken {
+		errMsg := fmt.Sprintf("Error: the bytecode compiler only supports named types, but %v is annotated with something else in %v at line %v.\n", nameTok.Value, defKind, comp.line)
+		panic(errMsg)
+	}
+	binding := comp.systemBinding(typeTok.Value)
+	if binding == nil && comp.env != nil {
+		binding = comp.env.findBinding(typeTok.Value, true, false)
+	}
+	if binding == nil {
+		errMsg := fmt.Sprintf("Error: attempting to assign identifier %v to %v in %v at line %v, but %v is unbound.\n", typeTok.Value, nameTok.Value, defKind, comp.line, typeTok.Value)
+		panic(errMsg)
+	} else if binding.Binding.TypeName != TYPE_TYPE_NAME {
+		errMsg := fmt.Sprintf("Error: attempting to assign something that is not a type, but a %v, to %v in %v at line %v.\n", binding.Binding.TypeName, nameTok.Value, defKind, comp.line)
+		panic(errMsg)
+	}
+	return typeTok.Value
+}
+
+func (comp *compiler) compileNamedValue(name string, valueTok *Parser.Token) {
+	if isFnForm(valueTok) {
+		comp.compileFn(name, valueTok, false)
+	} else {
+		comp.compileToken(valueTok)
+	}
+}
+
+func (comp *compiler) compileForm(list *Parser.Token) {
+	firstVal := &list.ListVals[0]
+	switch firstVal.Value {
+	case "if":
+		if len(list.ListVals) < 3 || len(list.ListVals) > 4 {
+			errMsg := fmt.Sprintf("Error: if at line %v expects a condition, a consequent and an optional alternative.\n", comp.line)
+			panic(errMsg)
+		}
+		line := comp.line
+		comp.compileToken(&list.ListVals[1])
+		comp.line = line
+		elseJump := comp.emit(opJumpIfFalse, 0)
+		comp.compileToken(&list.ListVals[2])
+		endJump := comp.emit(opJump, 0)
+		comp.patchJump(elseJump)
+		if len(list.ListVals) == 4 {
+			comp.compileToken(&list.ListVals[3])
+		} else {
+			comp.emit(opConst, comp.addConst(makeNilCell()))
+		}
+		comp.patchJump(endJump)
+	case "fn":
+		comp.compileFn("", list, false)
+	case "do":
+		comp.compileBody(list.ListVals[1:])
+	case "defn", "defn-memo":
+		if len(list.ListVals) < 4 {
+			errMsg := fmt.Sprintf("Error: too few arguments to %v at line %v.\n", firstVal.Value, comp.line)
+			panic(errMsg)
+		}
+		nameTok := &list.ListVals[1]
+		if nameTok.Type != Parser.IdToken {
+			errMsg := fmt.Sprintf("Error: attempting to assign to a non-identifier in %v at line %v.\n", firstVal.Value, comp.line)
+			panic(errMsg)
+		}
+		memo := firstVal.Value == "defn-memo"
+		if memo && !list.Pure {
+			errMsg := fmt.Sprintf("Error: defn-memo at line %v requires a pure function, but %v calls impure code.\n", comp.line, nameTok.Value)
+			panic(errMsg)
+		}
+		fnTok := Parser.Token{Type: Parser.ListToken, LineNum: list.LineNum, Pure: list.Pure, ListVals: append([]Parser.Token{*firstVal}, list.ListVals[2:]...)}
+		comp.compileFn(nameTok.Value, &fnTok, memo)
+		comp.emit(opDefGlobal, comp.addName(nameTok.Value), 0)
+	case "select":
+		comp.compileSelect(list)
+	case "dosync":
+		if len(list.ListVals) < 2 {
+			comp.emit(opConst, comp.addConst(makeNilCell()))
+			return
+		}
+		fnTok := Parser.Token{Type: Parser.ListToken, LineNum: list.LineNum, ListVals: append([]Parser.Token{*firstVal, {Type: Parser.ListToken}}, list.ListVals[1:]...)}
+		comp.compileFn("dosync", &fnTok, false)
+		comp.emit(opDosync)
+	case "quote":
+		comp.emit(opConst, comp.addConst(evalQuote(list, nil)))
+	default:
+		errMsg := fmt.Sprintf("Error: unhandled special form %v at line %v.\n", firstVal.Value, comp.line)
+		panic(errMsg)
+	}
+}
+
+// compileSelect pushes the channel of each clause, and the value of each
+// send, then emits an opSelect whose targets are the clause bodies. A recv
+// body runs in a scope of one slot holding the value received.
+func (comp *compiler) compileSelect(list *Parser.Token) {
+	clauses := list.ListVals[1:]
+	kinds := make([]int, len(clauses))
+	for i := range clauses {
+		kind := selectClauseKind(&clauses[i], comp.line)
+		kinds[i] = int(kind)
+		if kind == selectDefault {
+			continue
+		}
+		comp.compileToken(&clauses[i].ListVals[1])
+		if kind == selectSend {
+			comp.compileToken(&clauses[i].ListVals[2])
+		}
+	}
+	operands := append([]int{len(clauses)}, kinds...)
+	operands = append(operands, make([]int, len(clauses))...)
+	selectPos := comp.emit(opSelect, operands...)
+	endJumps := make([]int, len(clauses))
+	for i := range clauses {
+		comp.patchOperand(selectPos, 1+len(clauses)+i)
+		kind := selectKind(kinds[i])
+		if kind == selectRecv {
+			comp.emit(opPushScope, 1)
+			comp.emit(opStoreLocal, 0, 0, 0)
+		}
+		comp.compileBody(selectBody(&clauses[i], kind))
+		if kind == selectRecv {
+			comp.emit(opPopScope)
+		}
+		endJumps[i] = comp.emit(opJump, 0)
+	}
+	for _, pos := range endJumps {
+		comp.patchJump(pos)
+	}
+}
+
+func (comp *compiler) compileFn(name string, list *Parser.Token, memo bool) {
+	if len(list.ListVals) < 3 {
+		errMsg := fmt.Sprintf("Error: too few arguments to fn at line %v.\n", comp.line)
+		panic(errMsg)
+	}
+	params := parseParams(&list.ListVals[1], comp.line)
+	fnComp := compiler{proto: &funcProto{name: name, params: params, pure: list.Pure, memo: memo}, env: comp.env, line: comp.line}
+	fnComp.compileBody(list.ListVals[2:])
+	fnComp.emit(opReturn)
+	comp.proto.protos = append(comp.proto.protos, fnComp.proto)
+	comp.emit(opClosure, len(comp.proto.protos)-1)
+}
+
+func (comp *compiler) compileApplication(list *Parser.Token) {
+	firstVal := &list.ListVals[0]
+	if firstVal.Type == Parser.IdToken && len(list.ListVals) == 3 {
+		if binding := comp.systemBinding(firstVal.Value); binding != nil {
+			if funct, ok := binding.Binding.Value.(FunctionObj); ok && funct.GoFunc {
+				if op, ok := builtinOps[funct.FuncType]; ok {
+					comp.compileToken(&list.ListVals[1])
+					comp.compileToken(&list.ListVals[2])
+					comp.emit(op)
+					return
+				}
+			}
+		}
+	}
+	comp.compileToken(firstVal)
+	line := comp.line
+	for i := 1; i < len(list.ListVals); i++ {
+		comp.compileToken(&list.ListVals[i])
+	}
+	comp.line = line
+	comp.emit(opCall, len(list.ListVals)-1, comp.addName(firstVal.Value))
+}
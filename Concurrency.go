@@ -0,0 +1,257 @@
+package Golly
+
+import (
+	"Golly/parser"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Channel is the value of a Golly channel, made by chan.
+type Channel struct {
+	ch chan ListCell
+}
+
+func channelArg(name string, cell *ListCell) (*Channel, error) {
+	ch, ok := cell.Value.(*Channel)
+	if !ok || cell.TypeName != CHANNEL_TYPE_NAME {
+		err := fmt.Sprintf("Error: %v expects a Channel but got %v.\n", name, cell.TypeName)
+		return nil, errors.New(err)
+	}
+	return ch, nil
+}
+
+// recoverChannelPanic turns the panics Go raises for sending on or closing
+// a closed channel into errors.
+func recoverChannelPanic(name string, err *error) {
+	if r := recover(); r != nil {
+		errMsg := fmt.Sprintf("Error: %v on a closed channel.\n", name)
+		*err = errors.New(errMsg)
+	}
+}
+
+func doneChannel(env *Environment) <-chan struct{} {
+	if state := env.evalState(); state != nil {
+		return state.ctx.Done()
+	}
+	return nil
+}
+
+func cancelled(env *Environment) error {
+	return &CancelledError{Cause: env.evalState().ctx.Err()}
+}
+
+// GoChan makes a channel, unbuffered or with the given buffer size.
+func GoChan(parameters []ListCell) ([]*ListCell, error) {
+	size := 0
+	if len(parameters) > 1 {
+		err := fmt.Sprintf("Error: builtin chan expects at most 1 argument but was called with %v.\n", len(parameters))
+		return nil, errors.New(err)
+	} else if len(parameters) == 1 {
+		var ok bool
+		size, ok = parameters[0].Value.(int)
+		if !ok || size < 0 {
+			err := fmt.Sprintf("Error: chan expects a buffer size that is a non-negative int but got %v.\n", parameters[0].TypeName)
+			return nil, errors.New(err)
+		}
+	}
+	returnVal := ListCell{TypeName: CHANNEL_TYPE_NAME, Value: &Channel{ch: make(chan ListCell, size)}}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoSend sends a value on a channel, blocking until it is received or
+// buffered, and returns the value.
+func GoSend(Cell1 *ListCell, Cell2 *ListCell, env *Environment) (returnVals []*ListCell, err error) {
+	ch, err := channelArg("send", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	defer recoverChannelPanic("send", &err)
+	val := *Cell2
+	val.Mutable = false
+	select {
+	case ch.ch <- val:
+		return []*ListCell{&val}, nil
+	case <-doneChannel(env):
+		return nil, cancelled(env)
+	}
+}
+
+// GoRecv receives a value from a channel, or nil once it is closed and
+// drained.
+func GoRecv(Cell1 *ListCell, env *Environment) ([]*ListCell, error) {
+	ch, err := channelArg("recv", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case val, ok := <-ch.ch:
+		if !ok {
+			val = makeNilCell()
+		}
+		return []*ListCell{&val}, nil
+	case <-doneChannel(env):
+		return nil, cancelled(env)
+	}
+}
+
+func GoClose(Cell1 *ListCell) (returnVals []*ListCell, err error) {
+	ch, err := channelArg("close", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	defer recoverChannelPanic("close", &err)
+	close(ch.ch)
+	returnVal := makeNilCell()
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoSpawn calls a function with the given arguments in a new goroutine.
+// The evaluation that started it waits for it to finish, and an error from
+// it stops the evaluation.
+func GoSpawn(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if len(parameters) == 0 {
+		return nil, errors.New("Error: builtin go expects a function to call.\n")
+	}
+	funct, ok := parameters[0].Value.(FunctionObj)
+	if !ok {
+		err := fmt.Sprintf("Error: go expects a function but got %v.\n", parameters[0].TypeName)
+		return nil, errors.New(err)
+	}
+	state := env.evalState()
+	if state == nil {
+		return nil, errors.New("Error: go can only be used during an evaluation started by EvalString, EvalTokens or EvalToken.\n")
+	}
+	args := append([]ListCell{}, parameters[1:]...)
+	state.spawn(func(child *evalState) error {
+		_, err := funct.Call(args, env.withState(child))
+		return err
+	})
+	returnVal := makeNilCell()
+	return []*ListCell{&returnVal}, nil
+}
+
+type selectKind int
+
+const (
+	selectRecv selectKind = iota
+	selectSend
+	selectDefault
+)
+
+// selectClause is one clause of a select once its channel and any value to
+// send have been evaluated.
+type selectClause struct {
+	kind  selectKind
+	ch    *Channel
+	value ListCell
+}
+
+// selectClauseKind checks the shape of a clause of a select form:
+// (recv ch name body...), (send ch value body...) or (default body...).
+func selectClauseKind(clause *Parser.Token, lineNum int) selectKind {
+	if clause.Type == Parser.ListToken && len(clause.ListVals) > 0 && clause.ListVals[0].Type == Parser.IdToken {
+		switch clause.ListVals[0].Value {
+		case "recv":
+			if len(clause.ListVals) >= 3 && clause.ListVals[2].Type == Parser.IdToken {
+				return selectRecv
+			}
+		case "send":
+			if len(clause.ListVals) >= 3 {
+				return selectSend
+			}
+		case "default":
+			return selectDefault
+		}
+	}
+	errMsg := fmt.Sprintf("Error: select clause at line %v should be (recv ch name body...), (send ch value body...) or (default body...).\n", lineNum)
+	panic(errMsg)
+}
+
+// selectBody returns the body of a clause, after its channel and name or
+// value.
+func selectBody(clause *Parser.Token, kind selectKind) []Parser.Token {
+	if kind == selectDefault {
+		return clause.ListVals[1:]
+	}
+	return clause.ListVals[3:]
+}
+
+// runSelect blocks until one of the clauses can go ahead, or runs the
+// default clause if there is one and none can. It returns the index of the
+// clause chosen and, for a receive, the value received.
+func runSelect(clauses []selectClause, lineNum int, env *Environment) (chosen int, received ListCell, err error) {
+	defer recoverChannelPanic(fmt.Sprintf("send in select at line %v", lineNum), &err)
+	cases := make([]reflect.SelectCase, 0, len(clauses)+1)
+	caseClauses := make([]int, 0, len(clauses)+1)
+	defaultClause := -1
+	for i, clause := range clauses {
+		switch clause.kind {
+		case selectRecv:
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(clause.ch.ch)})
+		case selectSend:
+			value := clause.value
+			value.Mutable = false
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(clause.ch.ch), Send: reflect.ValueOf(value)})
+		case selectDefault:
+			if defaultClause >= 0 {
+				errMsg := fmt.Sprintf("Error: select at line %v has more than one default clause.\n", lineNum)
+				return 0, ListCell{}, errors.New(errMsg)
+			}
+			defaultClause = i
+			continue
+		}
+		caseClauses = append(caseClauses, i)
+	}
+	if defaultClause >= 0 {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+		caseClauses = append(caseClauses, defaultClause)
+	} else if done := doneChannel(env); done != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)})
+		caseClauses = append(caseClauses, -1)
+	}
+	chosenCase, recvValue, recvOK := reflect.Select(cases)
+	chosen = caseClauses[chosenCase]
+	if chosen < 0 {
+		return 0, ListCell{}, cancelled(env)
+	}
+	received = makeNilCell()
+	if clauses[chosen].kind == selectRecv && recvOK {
+		received = recvValue.Interface().(ListCell)
+	}
+	return chosen, received, nil
+}
+
+func evalSelect(list *Parser.Token, env *Environment) ListCell {
+	lineNum := list.ListVals[0].LineNum
+	clauseToks := list.ListVals[1:]
+	clauses := make([]selectClause, len(clauseToks))
+	for i := range clauseToks {
+		clause := &clauseToks[i]
+		clauses[i].kind = selectClauseKind(clause, lineNum)
+		if clauses[i].kind == selectDefault {
+			continue
+		}
+		chCell := evalToken(&clause.ListVals[1], env)
+		ch, err := channelArg(clause.ListVals[0].Value, &chCell)
+		if err != nil {
+			panic(err)
+		}
+		clauses[i].ch = ch
+		if clauses[i].kind == selectSend {
+			clauses[i].value = evalToken(&clause.ListVals[2], env)
+		}
+	}
+	chosen, received, err := runSelect(clauses, lineNum, env)
+	if err != nil {
+		panic(err)
+	}
+	clause := &clauseToks[chosen]
+	bodyEnv := env
+	if clauses[chosen].kind == selectRecv {
+		bodyEnv = newChildEnvironment(env)
+		received.Mutable = false
+		bodyEnv.Bindings = append(bodyEnv.Bindings, EnvBinding{Name: clause.ListVals[2].Value, Binding: received})
+	}
+	return evalBody(selectBody(clause, clauses[chosen].kind), bodyEnv)
+}
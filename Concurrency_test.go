@@ -0,0 +1,63 @@
+package Golly
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChannels(t *testing.T) {
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{"(let (ch (chan)) (do (go (fn () (do (send ch 1) (send ch 2) (close ch)))) (+ (recv ch) (* 10 (recv ch)))))", 21},
+		{"(let (ch (chan)) (do (go (fn () (close ch))) (recv ch)))", nil},
+		{"(defn put (ch a b) (send ch (+ a b)))\n(let (ch (chan 1)) (do (go put ch 2 3) (recv ch)))", 5},
+		{"(let (ch (chan)) (select (recv ch v v) (default 0)))", 0},
+		{"(let (ch (chan 1)) (do (send ch 7) (select (recv ch v (+ v 1)) (default 0))))", 8},
+		{"(let (ch (chan 1)) (do (select (send ch 7 1) (default 2)) (recv ch)))", 7},
+		{"(let (ch (chan 1)) (do (send ch 1) (select (send ch 2 1) (default 2))))", 2},
+		{"(let (a (chan) b (chan 1)) (do (send b 9) (select (recv a v v) (recv b v v))))", 9},
+	}
+	for _, backEnd := range backEnds {
+		for _, test := range tests {
+			env := NewEnvironment(CreateSystemFuncs())
+			result, err := EvalString(context.Background(), test.src, env, backEnd.opts)
+			if err != nil || result.Value != test.want {
+				t.Errorf("%v: %v returned %v, %v, want %v", backEnd.name, test.src, result.Value, err, test.want)
+			}
+		}
+	}
+}
+
+func TestChannelErrors(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"(let (ch (chan 1)) (do (close ch) (send ch 1)))", "closed channel"},
+		{"(do (go (fn () (/ 1 0))) 1)", "divide"},
+		{"(recv 1)", "Channel"},
+	}
+	for _, backEnd := range backEnds {
+		for _, test := range tests {
+			env := NewEnvironment(CreateSystemFuncs())
+			_, err := EvalString(context.Background(), test.src, env, backEnd.opts)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("%v: %v returned %v, want an error mentioning %q", backEnd.name, test.src, err, test.want)
+			}
+		}
+	}
+}
+
+func TestBlockedReceiveIsCancelled(t *testing.T) {
+	for _, backEnd := range backEnds {
+		env := NewEnvironment(CreateSystemFuncs())
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		_, err := EvalString(ctx, "(let (ch (chan)) (do (go (fn () (recv ch))) (recv ch)))", env, backEnd.opts)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%v: a receive nothing sends to returned %v, want the deadline exceeded", backEnd.name, err)
+		}
+	}
+}
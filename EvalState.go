@@ -0,0 +1,251 @@
+package Golly
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// cancelCheckInterval is how many evaluation steps pass between checks of
+// the context, so that checking it stays cheap.
+const cancelCheckInterval = 1024
+
+// CancelledError is returned when evaluation stops because its context was
+// cancelled or its deadline passed. Cause is the context's error, so
+// errors.Is(err, context.DeadlineExceeded) tells a timeout apart.
+type CancelledError struct {
+	Cause error
+}
+
+func (err *CancelledError) Error() string {
+	return fmt.Sprintf("Error: evaluation cancelled: %v.\n", err.Cause)
+}
+
+func (err *CancelledError) Unwrap() error {
+	return err.Cause
+}
+
+// Limits caps the work a single evaluation may do. A zero field means no
+// limit. Steps are approximate: the tree-walker counts tokens evaluated and
+// calls made, and the VM counts instructions. Cells counts values bound to
+// names, function arguments and list elements created.
+type Limits struct {
+	MaxSteps      int
+	MaxDepth      int
+	MaxListLength int
+	MaxStringSize int
+	MaxCells      int
+}
+
+// The limits a LimitExceeded can report.
+const (
+	StepLimit       = "steps"
+	DepthLimit      = "call depth"
+	ListLengthLimit = "list length"
+	StringSizeLimit = "string size"
+	CellLimit       = "cells"
+)
+
+// LimitExceeded is returned when evaluation goes past one of its Limits.
+type LimitExceeded struct {
+	Limit string
+	Max   int
+}
+
+func (err *LimitExceeded) Error() string {
+	return fmt.Sprintf("Error: %v limit of %v exceeded.\n", err.Limit, err.Max)
+}
+
+// evalState belongs to one goroutine of one call of an evaluation entry
+// point. It travels on the Environments that evaluation creates, and
+// function calls hand it on from the caller, so a closure made by an
+// earlier evaluation still answers to the context of the one calling it.
+// Goroutines started by the evaluation get states of their own that share
+// its context, limits and counters.
+type evalState struct {
+	ctx    context.Context
+	limits Limits
+	//Steps not yet added to shared.steps.
+	steps  int
+	depth  int
+	shared *sharedState
+	//The dosync transaction this goroutine is in, if any.
+	tx *transaction
+}
+
+// sharedState is the part of an evaluation common to all its goroutines.
+type sharedState struct {
+	steps  int64
+	cells  int64
+	cancel context.CancelFunc
+	tasks  sync.WaitGroup
+	//Holds a token for each busy worker of pmap, par and future.
+	workers chan struct{}
+	once    sync.Once
+	err     error
+}
+
+func newEvalState(ctx context.Context, opts EvalOptions) *evalState {
+	ctx, cancel := context.WithCancel(ctx)
+	shared := sharedState{cancel: cancel, workers: make(chan struct{}, poolSize(opts.PoolSize))}
+	return &evalState{ctx: ctx, limits: opts.Limits, shared: &shared}
+}
+
+// fail records the first error of the evaluation and cancels the rest of
+// it.
+func (state *evalState) fail(err error) {
+	state.shared.once.Do(func() {
+		state.shared.err = err
+	})
+	state.shared.cancel()
+}
+
+// spawn runs call in a new goroutine with a state of its own. An error
+// from it fails the whole evaluation.
+func (state *evalState) spawn(call func(*evalState) error) {
+	child := &evalState{ctx: state.ctx, limits: state.limits, shared: state.shared}
+	state.shared.tasks.Add(1)
+	go func() {
+		defer state.shared.tasks.Done()
+		var err error
+		func() {
+			defer recoverEvalError(&err)
+			err = call(child)
+		}()
+		child.flushSteps()
+		if err != nil {
+			child.fail(err)
+		}
+	}()
+}
+
+// finish ends an evaluation whose main goroutine returned err. It waits
+// for the goroutines the evaluation started and returns the first error
+// from any of them.
+func (state *evalState) finish(err error) error {
+	if err != nil {
+		state.fail(err)
+	}
+	state.shared.tasks.Wait()
+	state.shared.cancel()
+	return state.shared.err
+}
+
+func exceeded(limit string, max int) {
+	panic(&LimitExceeded{Limit: limit, Max: max})
+}
+
+// step counts one unit of evaluation work. Steps are added to the shared
+// count, and the context checked, every cancelCheckInterval steps. A nil
+// state never stops.
+func (state *evalState) step() {
+	if state == nil {
+		return
+	}
+	state.steps++
+	if state.limits.MaxSteps > 0 && atomic.LoadInt64(&state.shared.steps)+int64(state.steps) > int64(state.limits.MaxSteps) {
+		exceeded(StepLimit, state.limits.MaxSteps)
+	}
+	if state.steps == cancelCheckInterval {
+		state.flushSteps()
+		state.checkContext()
+	}
+}
+
+func (state *evalState) flushSteps() {
+	atomic.AddInt64(&state.shared.steps, int64(state.steps))
+	state.steps = 0
+}
+
+// enter and leave bracket a function call.
+func (state *evalState) enter() {
+	if state == nil {
+		return
+	}
+	state.depth++
+	if state.limits.MaxDepth > 0 && state.depth > state.limits.MaxDepth {
+		//The call is not made, so callers only leave the calls they entered.
+		state.depth--
+		exceeded(DepthLimit, state.limits.MaxDepth)
+	}
+}
+
+func (state *evalState) leave() {
+	if state != nil {
+		state.depth--
+	}
+}
+
+func (state *evalState) alloc(cells int) {
+	if state == nil {
+		return
+	}
+	total := atomic.AddInt64(&state.shared.cells, int64(cells))
+	if state.limits.MaxCells > 0 && total > int64(state.limits.MaxCells) {
+		exceeded(CellLimit, state.limits.MaxCells)
+	}
+}
+
+// allocList accounts for a new list of length elements.
+func (state *evalState) allocList(length int) {
+	if state == nil {
+		return
+	}
+	state.checkLength(length)
+	state.alloc(length)
+}
+
+// checkLength checks the length of a list or vector against the limit
+// without counting its elements as new cells, for one that shares its
+// elements with others.
+func (state *evalState) checkLength(length int) {
+	if state != nil && state.limits.MaxListLength > 0 && length > state.limits.MaxListLength {
+		exceeded(ListLengthLimit, state.limits.MaxListLength)
+	}
+}
+
+// allocString accounts for a new string of size bytes.
+func (state *evalState) allocString(size int) {
+	if state == nil {
+		return
+	}
+	if state.limits.MaxStringSize > 0 && size > state.limits.MaxStringSize {
+		exceeded(StringSizeLimit, state.limits.MaxStringSize)
+	}
+	state.alloc(1)
+}
+
+// poll checks the context every cancelCheckInterval iterations of a loop
+// inside a builtin, since the call counts as a single step however long
+// the loop runs. i is the loop's iteration.
+func (state *evalState) poll(i int) {
+	if state != nil && i%cancelCheckInterval == cancelCheckInterval-1 {
+		state.checkContext()
+	}
+}
+
+func (state *evalState) checkContext() {
+	if state == nil {
+		return
+	}
+	if err := state.ctx.Err(); err != nil {
+		panic(&CancelledError{Cause: err})
+	}
+}
+
+func (env *Environment) evalState() *evalState {
+	if env == nil {
+		return nil
+	}
+	return env.state
+}
+
+// withState returns env if it already carries state, or otherwise an empty
+// child of env that does.
+func (env *Environment) withState(state *evalState) *Environment {
+	if env.state == state {
+		return env
+	}
+	return &Environment{Parent: env, System: env.System, state: state}
+}
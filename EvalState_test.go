@@ -0,0 +1,95 @@
+package Golly
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+const spinSource = "(defn spin (n) (if (= n 0) 0 (spin (- n 1))))\n"
+
+func TestLimitsExceeded(t *testing.T) {
+	tests := []struct {
+		src    string
+		limits Limits
+		limit  string
+	}{
+		{spinSource + "(spin 100000)", Limits{MaxSteps: 1000}, StepLimit},
+		{spinSource + "(spin 1000)", Limits{MaxDepth: 100}, DepthLimit},
+		{"(map (fn (n) n) (range 0 100))", Limits{MaxListLength: 50}, ListLengthLimit},
+		{`(fold (fn (acc n) (concat acc "xxxxxxxxxx")) "" (range 0 100))`, Limits{MaxStringSize: 500}, StringSizeLimit},
+		{spinSource + "(spin 1000)", Limits{MaxCells: 200}, CellLimit},
+		{"(map (fn (n) (list n n)) (range 0 100))", Limits{MaxCells: 200}, CellLimit},
+	}
+	for _, backEnd := range backEnds {
+		for _, test := range tests {
+			opts := backEnd.opts
+			opts.Limits = test.limits
+			env := NewEnvironment(CreateSystemFuncs())
+			_, err := EvalString(context.Background(), test.src, env, opts)
+			var limitErr *LimitExceeded
+			if !errors.As(err, &limitErr) || limitErr.Limit != test.limit {
+				t.Errorf("%v: %v returned %v, want the %v limit exceeded", backEnd.name, test.src, err, test.limit)
+			}
+		}
+	}
+}
+
+func TestLimitsAllowWorkWithinThem(t *testing.T) {
+	limits := Limits{MaxSteps: 100000, MaxDepth: 2000, MaxListLength: 1000, MaxStringSize: 1000, MaxCells: 100000}
+	for _, backEnd := range backEnds {
+		opts := backEnd.opts
+		opts.Limits = limits
+		env := NewEnvironment(CreateSystemFuncs())
+		result, err := EvalString(context.Background(), spinSource+"(+ (spin 1000) (length (range 0 1000)))", env, opts)
+		if err != nil || result.Value != 1000 {
+			t.Errorf("%v: work within the limits returned %v, %v", backEnd.name, result.Value, err)
+		}
+	}
+}
+
+func TestLimitsApplyPerEvaluation(t *testing.T) {
+	interp := New(WithLimits(Limits{MaxSteps: 5000}))
+	if _, err := interp.EvalString(context.Background(), spinSource); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := interp.EvalString(context.Background(), "(spin 100)"); err != nil {
+			t.Errorf("evaluation %v returned %v, though each is within the limit", i, err)
+		}
+	}
+}
+
+func TestCancellation(t *testing.T) {
+	for _, backEnd := range backEnds {
+		env := NewEnvironment(CreateSystemFuncs())
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		start := time.Now()
+		_, err := EvalString(ctx, spinSource+"(spin 100000000)", env, backEnd.opts)
+		cancel()
+		var cancelled *CancelledError
+		if !errors.As(err, &cancelled) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%v: a spin past its deadline returned %v", backEnd.name, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%v: a spin past its deadline ran for %v", backEnd.name, elapsed)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New().Call(ctx, "+", IntValue(1), IntValue(2)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Call with a cancelled context returned %v", err)
+	}
+}
+
+func TestDefaultDepthLimit(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithBytecode()}} {
+		interp := New(opts...)
+		_, err := interp.EvalString(context.Background(), "(defn down (n) (+ 1 (down n)))\n(down 1)")
+		var limitErr *LimitExceeded
+		if !errors.As(err, &limitErr) || limitErr.Limit != DepthLimit || limitErr.Max != DefaultMaxDepth {
+			t.Errorf("runaway recursion returned %v, want the default depth limit exceeded", err)
+		}
+	}
+}
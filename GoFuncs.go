@@ -3,8 +3,8 @@ package Golly
 import (
 	"errors"
 	"fmt"
-	"strconv"
-	"unicode"
+	"reflect"
+	"strings"
 )
 
 type baseType int
@@ -28,6 +28,94 @@ const (
 	GoDivideT
 	GoIfT
 	GoEvalT
+	GoEqualT
+	GoLessT
+	GoGreaterT
+	GoLessEqT
+	GoGreaterEqT
+	GoModT
+	GoAbsT
+	GoSqrtT
+	GoPowT
+	GoFloorT
+	GoCeilT
+	GoPrintT
+	GoPrintlnT
+	GoReadLineT
+	GoReadFileT
+	GoWriteFileT
+	GoGetenvT
+	GoHttpGetT
+	GoChanT
+	GoSendT
+	GoRecvT
+	GoCloseT
+	GoSpawnT
+	GoPmapT
+	GoParT
+	GoFutureT
+	GoAwaitT
+	GoAtomT
+	GoSwapT
+	GoResetT
+	GoRefT
+	GoDerefT
+	GoRefSetT
+	GoAlterT
+	GoGetT
+	GoAssocT
+	GoDissocT
+	GoConjT
+	GoCountT
+	GoSubvecT
+	GoCatvecT
+	GoListT
+	GoConsT
+	GoCarT
+	GoFirstT
+	GoCdrT
+	GoRestT
+	GoNthT
+	GoLengthT
+	GoAppendT
+	GoReverseT
+	GoMapT
+	GoFilterT
+	GoReduceT
+	GoFoldT
+	GoRangeT
+	GoSortT
+	GoStrT
+	GoConcatT
+	GoSubstringT
+	GoSplitT
+	GoJoinT
+	GoUpperT
+	GoLowerT
+	GoTrimT
+	GoIndexOfT
+	GoReplaceT
+	GoFormatT
+	GoStringToNumberT
+	GoNumberToStringT
+	GoReCompileT
+	GoReMatchT
+	GoReFindAllT
+	GoReReplaceT
+	GoReSplitT
+	GoCharAlphabeticT
+	GoCharDigitT
+	GoCharWhitespaceT
+	GoCharToIntT
+	GoIntToCharT
+	GoStringToListT
+	GoListToStringT
+	GoSymbolT
+	GoKeywordT
+	GoGensymT
+	GoSymbolToStringT
+	GoDisplayT
+	GoWriteT
 )
 
 const (
@@ -35,9 +123,197 @@ const (
 	LIST_TYPE_NAME        = "List"
 	ENVIRONMENT_TYPE_NAME = "Environment"
 	VAR_TYPE_NAME         = "Var"
+	INT_TYPE_NAME         = "int"
+	FLOAT_TYPE_NAME       = "float"
+	BOOL_TYPE_NAME        = "bool"
+	STRING_TYPE_NAME      = "string"
+	CHAR_TYPE_NAME        = "char"
+	SYMBOL_TYPE_NAME      = "Symbol"
+	KEYWORD_TYPE_NAME     = "Keyword"
+	CHANNEL_TYPE_NAME     = "Channel"
+	FUTURE_TYPE_NAME      = "Future"
+	ATOM_TYPE_NAME        = "Atom"
+	REF_TYPE_NAME         = "Ref"
+	VECTOR_TYPE_NAME      = "Vector"
+	MAP_TYPE_NAME         = "Map"
+	REGEX_TYPE_NAME       = "Regex"
+	NIL_TYPE_NAME         = "nil"
+	TYPE_TYPE_NAME        = "type"
+	UNDECIDED_TYPE_NAME   = "undecided"
 )
 
-func CallGoFunc(funcType goFuncType, parameters []ListCell) ([]*ListCell, error) {
+var goFuncNames = map[goFuncType]string{
+	GoAddT:            "+",
+	GoSubtractT:       "-",
+	GoMultiplyT:       "*",
+	GoDivideT:         "/",
+	GoIfT:             "if",
+	GoEvalT:           "eval",
+	GoEqualT:          "=",
+	GoLessT:           "<",
+	GoGreaterT:        ">",
+	GoLessEqT:         "<=",
+	GoGreaterEqT:      ">=",
+	GoModT:            "mod",
+	GoAbsT:            "abs",
+	GoSqrtT:           "sqrt",
+	GoPowT:            "pow",
+	GoFloorT:          "floor",
+	GoCeilT:           "ceil",
+	GoPrintT:          "print",
+	GoPrintlnT:        "println",
+	GoReadLineT:       "read-line",
+	GoReadFileT:       "read-file",
+	GoWriteFileT:      "write-file",
+	GoGetenvT:         "getenv",
+	GoHttpGetT:        "http-get",
+	GoChanT:           "chan",
+	GoSendT:           "send",
+	GoRecvT:           "recv",
+	GoCloseT:          "close",
+	GoSpawnT:          "go",
+	GoPmapT:           "pmap",
+	GoParT:            "par",
+	GoFutureT:         "future",
+	GoAwaitT:          "await",
+	GoAtomT:           "atom",
+	GoSwapT:           "swap!",
+	GoResetT:          "reset!",
+	GoRefT:            "ref",
+	GoDerefT:          "deref",
+	GoRefSetT:         "ref-set",
+	GoAlterT:          "alter",
+	GoGetT:            "get",
+	GoAssocT:          "assoc",
+	GoDissocT:         "dissoc",
+	GoConjT:           "conj",
+	GoCountT:          "count",
+	GoSubvecT:         "subvec",
+	GoCatvecT:         "catvec",
+	GoListT:           "list",
+	GoConsT:           "cons",
+	GoCarT:            "car",
+	GoFirstT:          "first",
+	GoCdrT:            "cdr",
+	GoRestT:           "rest",
+	GoNthT:            "nth",
+	GoLengthT:         "length",
+	GoAppendT:         "append",
+	GoReverseT:        "reverse",
+	GoMapT:            "map",
+	GoFilterT:         "filter",
+	GoReduceT:         "reduce",
+	GoFoldT:           "fold",
+	GoRangeT:          "range",
+	GoSortT:           "sort",
+	GoStrT:            "str",
+	GoConcatT:         "concat",
+	GoSubstringT:      "substring",
+	GoSplitT:          "split",
+	GoJoinT:           "join",
+	GoUpperT:          "upper",
+	GoLowerT:          "lower",
+	GoTrimT:           "trim",
+	GoIndexOfT:        "index-of",
+	GoReplaceT:        "replace",
+	GoFormatT:         "format",
+	GoStringToNumberT: "string->number",
+	GoNumberToStringT: "number->string",
+	GoReCompileT:      "re-compile",
+	GoReMatchT:        "re-match",
+	GoReFindAllT:      "re-find-all",
+	GoReReplaceT:      "re-replace",
+	GoReSplitT:        "re-split",
+	GoCharAlphabeticT: "char-alphabetic?",
+	GoCharDigitT:      "char-digit?",
+	GoCharWhitespaceT: "char-whitespace?",
+	GoCharToIntT:      "char->int",
+	GoIntToCharT:      "int->char",
+	GoStringToListT:   "string->list",
+	GoListToStringT:   "list->string",
+	GoSymbolT:         "symbol",
+	GoKeywordT:        "keyword",
+	GoGensymT:         "gensym",
+	GoSymbolToStringT: "symbol->string",
+	GoDisplayT:        "display",
+	GoWriteT:          "write",
+}
+
+var goFuncArities = map[goFuncType]int{
+	GoAddT:            2,
+	GoSubtractT:       2,
+	GoMultiplyT:       2,
+	GoDivideT:         2,
+	GoIfT:             3,
+	GoEvalT:           2,
+	GoEqualT:          2,
+	GoLessT:           2,
+	GoGreaterT:        2,
+	GoLessEqT:         2,
+	GoGreaterEqT:      2,
+	GoModT:            2,
+	GoAbsT:            1,
+	GoSqrtT:           1,
+	GoPowT:            2,
+	GoFloorT:          1,
+	GoCeilT:           1,
+	GoReadLineT:       0,
+	GoReadFileT:       1,
+	GoWriteFileT:      2,
+	GoGetenvT:         1,
+	GoHttpGetT:        1,
+	GoSendT:           2,
+	GoRecvT:           1,
+	GoCloseT:          1,
+	GoPmapT:           2,
+	GoAwaitT:          1,
+	GoAtomT:           1,
+	GoResetT:          2,
+	GoRefT:            1,
+	GoDerefT:          1,
+	GoRefSetT:         2,
+	GoCountT:          1,
+	GoConsT:           2,
+	GoCarT:            1,
+	GoFirstT:          1,
+	GoCdrT:            1,
+	GoRestT:           1,
+	GoNthT:            2,
+	GoLengthT:         1,
+	GoReverseT:        1,
+	GoFilterT:         2,
+	GoFoldT:           3,
+	GoSplitT:          2,
+	GoUpperT:          1,
+	GoLowerT:          1,
+	GoTrimT:           1,
+	GoIndexOfT:        2,
+	GoReplaceT:        3,
+	GoStringToNumberT: 1,
+	GoReCompileT:      1,
+	GoReMatchT:        2,
+	GoReFindAllT:      2,
+	GoReReplaceT:      3,
+	GoReSplitT:        2,
+	GoCharAlphabeticT: 1,
+	GoCharDigitT:      1,
+	GoCharWhitespaceT: 1,
+	GoCharToIntT:      1,
+	GoIntToCharT:      1,
+	GoStringToListT:   1,
+	GoListToStringT:   1,
+	GoSymbolT:         1,
+	GoKeywordT:        1,
+	GoSymbolToStringT: 1,
+	GoDisplayT:        1,
+	GoWriteT:          1,
+}
+
+func CallGoFunc(funcType goFuncType, parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if arity, ok := goFuncArities[funcType]; ok && arity != len(parameters) {
+		err := fmt.Sprintf("Error: builtin %v expects %v arguments but was called with %v.\n", goFuncNames[funcType], arity, len(parameters))
+		return nil, errors.New(err)
+	}
 	switch funcType {
 	case GoAddT:
 		res, err := GoAdd(&parameters[0], &parameters[1])
@@ -81,7 +357,155 @@ func CallGoFunc(funcType goFuncType, parameters []ListCell) ([]*ListCell, error)
 		} else {
 			return res, nil
 		}
-
+	case GoEqualT, GoLessT, GoGreaterT, GoLessEqT, GoGreaterEqT:
+		res, err := GoCompare(funcType, &parameters[0], &parameters[1])
+		if err != nil {
+			return nil, err
+		} else {
+			return res, nil
+		}
+	case GoModT:
+		return GoMod(&parameters[0], &parameters[1])
+	case GoAbsT, GoSqrtT, GoFloorT, GoCeilT:
+		return GoMathUnary(funcType, &parameters[0])
+	case GoPowT:
+		return GoPow(&parameters[0], &parameters[1])
+	case GoPrintT, GoPrintlnT:
+		return GoPrint(funcType, parameters, env)
+	case GoReadLineT:
+		return GoReadLine(env)
+	case GoReadFileT:
+		return GoReadFile(&parameters[0], env)
+	case GoWriteFileT:
+		return GoWriteFile(&parameters[0], &parameters[1])
+	case GoGetenvT:
+		return GoGetenv(&parameters[0])
+	case GoHttpGetT:
+		return GoHttpGet(&parameters[0], env)
+	case GoChanT:
+		return GoChan(parameters)
+	case GoSendT:
+		return GoSend(&parameters[0], &parameters[1], env)
+	case GoRecvT:
+		return GoRecv(&parameters[0], env)
+	case GoCloseT:
+		return GoClose(&parameters[0])
+	case GoSpawnT:
+		return GoSpawn(parameters, env)
+	case GoPmapT:
+		return GoPmap(&parameters[0], &parameters[1], env)
+	case GoParT:
+		return GoPar(parameters, env)
+	case GoFutureT:
+		return GoFuture(parameters, env)
+	case GoAwaitT:
+		return GoAwait(&parameters[0], env)
+	case GoAtomT:
+		return GoAtom(&parameters[0])
+	case GoSwapT:
+		return GoSwap(parameters, env)
+	case GoResetT:
+		return GoReset(&parameters[0], &parameters[1])
+	case GoRefT:
+		return GoRef(&parameters[0])
+	case GoDerefT:
+		return GoDeref(&parameters[0], env)
+	case GoRefSetT:
+		return GoRefSet(&parameters[0], &parameters[1], env)
+	case GoAlterT:
+		return GoAlter(parameters, env)
+	case GoGetT:
+		return GoGet(parameters)
+	case GoAssocT:
+		return GoAssoc(parameters, env)
+	case GoDissocT:
+		return GoDissoc(parameters)
+	case GoConjT:
+		return GoConj(parameters, env)
+	case GoCountT:
+		return GoCount(&parameters[0])
+	case GoSubvecT:
+		return GoSubvec(parameters, env)
+	case GoCatvecT:
+		return GoCatvec(parameters, env)
+	case GoListT:
+		return GoList(parameters, env)
+	case GoConsT:
+		return GoCons(&parameters[0], &parameters[1], env)
+	case GoNthT:
+		return GoNth(&parameters[0], &parameters[1])
+	case GoLengthT:
+		return GoLength(&parameters[0])
+	case GoAppendT:
+		return GoAppend(parameters, env)
+	case GoReverseT:
+		return GoReverse(&parameters[0], env)
+	case GoMapT:
+		return GoMap(parameters, env)
+	case GoFilterT:
+		return GoFilter(&parameters[0], &parameters[1], env)
+	case GoRangeT:
+		return GoRange(parameters, env)
+	case GoSortT:
+		return GoSort(parameters, env)
+	case GoCarT, GoFirstT:
+		return GoFirst(funcType, &parameters[0])
+	case GoCdrT, GoRestT:
+		return GoRest(funcType, &parameters[0])
+	case GoReduceT, GoFoldT:
+		return GoReduce(funcType, parameters, env)
+	case GoStrT:
+		return GoStr(parameters, env)
+	case GoConcatT:
+		return GoConcat(parameters, env)
+	case GoSubstringT:
+		return GoSubstring(parameters, env)
+	case GoSplitT:
+		return GoSplit(&parameters[0], &parameters[1], env)
+	case GoJoinT:
+		return GoJoin(parameters, env)
+	case GoIndexOfT:
+		return GoIndexOf(&parameters[0], &parameters[1])
+	case GoReplaceT:
+		return GoReplace(&parameters[0], &parameters[1], &parameters[2], env)
+	case GoFormatT:
+		return GoFormat(parameters, env)
+	case GoStringToNumberT:
+		return GoStringToNumber(&parameters[0])
+	case GoNumberToStringT:
+		return GoNumberToString(parameters, env)
+	case GoReCompileT:
+		return GoReCompile(&parameters[0])
+	case GoReMatchT:
+		return GoReMatch(&parameters[0], &parameters[1], env)
+	case GoReFindAllT:
+		return GoReFindAll(&parameters[0], &parameters[1], env)
+	case GoReReplaceT:
+		return GoReReplace(&parameters[0], &parameters[1], &parameters[2], env)
+	case GoReSplitT:
+		return GoReSplit(&parameters[0], &parameters[1], env)
+	case GoCharToIntT:
+		return GoCharToInt(&parameters[0])
+	case GoIntToCharT:
+		return GoIntToChar(&parameters[0])
+	case GoStringToListT:
+		return GoStringToList(&parameters[0], env)
+	case GoListToStringT:
+		return GoListToString(&parameters[0], env)
+	case GoSymbolT:
+		return GoSymbol(&parameters[0], env)
+	case GoKeywordT:
+		return GoKeyword(&parameters[0], env)
+	case GoGensymT:
+		return GoGensym(parameters)
+	case GoSymbolToStringT:
+		return GoSymbolToString(&parameters[0], env)
+	case GoDisplayT, GoWriteT:
+		return GoDisplay(funcType, &parameters[0], env)
+	case GoCharAlphabeticT, GoCharDigitT, GoCharWhitespaceT:
+		return GoCharPredicate(funcType, &parameters[0])
+	case GoUpperT, GoLowerT, GoTrimT:
+		return GoStringCase(funcType, &parameters[0], env)
 	default:
 		err := fmt.Sprintf("Error: attempting to call unhandled builtin function of type number %v.\n", funcType)
 		return nil, errors.New(err)
@@ -93,6 +517,13 @@ func GoAdd(Cell1 *ListCell, Cell2 *ListCell) ([]*ListCell, error) {
 		err := fmt.Sprintf("Error: attempting to add type %v to type %v, but these types are not compatible.\n", Cell1.TypeName, Cell2.TypeName)
 		return nil, errors.New(err)
 	}
+	if Cell1.TypeName == CHAR_TYPE_NAME {
+		return nil, errors.New("Error: attempting to add chars; convert them with char->int first.\n")
+	}
+	if !isNumber(Cell1) {
+		err := fmt.Sprintf("Error: attempting to add values of type %v, but only numbers can be added.\n", Cell1.TypeName)
+		return nil, errors.New(err)
+	}
 	returnVals := make([]*ListCell, 0, 1)
 	returnVal := ListCell{TypeName: Cell1.TypeName, Mutable: Cell1.Mutable}
 	if val1, ok1 := Cell1.Value.(int); ok1 {
@@ -152,6 +583,13 @@ func GoSubtract(Cell1 *ListCell, Cell2 *ListCell) ([]*ListCell, error) {
 		err := fmt.Sprintf("Error: attempting to subtract type %v from type %v, but these types are not compatible.\n", Cell2.TypeName, Cell1.TypeName)
 		return nil, errors.New(err)
 	}
+	if Cell1.TypeName == CHAR_TYPE_NAME {
+		return nil, errors.New("Error: attempting to subtract chars; convert them with char->int first.\n")
+	}
+	if !isNumber(Cell1) {
+		err := fmt.Sprintf("Error: attempting to subtract values of type %v, but only numbers can be subtracted.\n", Cell1.TypeName)
+		return nil, errors.New(err)
+	}
 	returnVals := make([]*ListCell, 0, 1)
 	returnVal := ListCell{TypeName: Cell1.TypeName, Mutable: Cell1.Mutable}
 	if val1, ok1 := Cell1.Value.(int); ok1 {
@@ -211,6 +649,13 @@ func GoMultiply(Cell1 *ListCell, Cell2 *ListCell) ([]*ListCell, error) {
 		err := fmt.Sprintf("Error: attempting to multiply type %v by type %v, but these types are not compatible.\n", Cell1.TypeName, Cell2.TypeName)
 		return nil, errors.New(err)
 	}
+	if Cell1.TypeName == CHAR_TYPE_NAME {
+		return nil, errors.New("Error: attempting to multiply chars; convert them with char->int first.\n")
+	}
+	if !isNumber(Cell1) {
+		err := fmt.Sprintf("Error: attempting to multiply values of type %v, but only numbers can be multiplied.\n", Cell1.TypeName)
+		return nil, errors.New(err)
+	}
 	returnVals := make([]*ListCell, 0, 1)
 	returnVal := ListCell{TypeName: Cell1.TypeName, Mutable: Cell1.Mutable}
 	if val1, ok1 := Cell1.Value.(int); ok1 {
@@ -270,6 +715,20 @@ func GoDivide(Cell1 *ListCell, Cell2 *ListCell) ([]*ListCell, error) {
 		err := fmt.Sprintf("Error: attempting to divide type %v by type %v, but these types are not compatible.\n", Cell2.TypeName, Cell1.TypeName)
 		return nil, errors.New(err)
 	}
+	if Cell1.TypeName == CHAR_TYPE_NAME {
+		return nil, errors.New("Error: attempting to divide chars; convert them with char->int first.\n")
+	}
+	if !isNumber(Cell1) {
+		err := fmt.Sprintf("Error: attempting to divide values of type %v, but only numbers can be divided.\n", Cell1.TypeName)
+		return nil, errors.New(err)
+	}
+	switch divisor := Cell2.Value.(type) {
+	case int, int64, int32, int16:
+		if divisor == reflect.Zero(reflect.TypeOf(divisor)).Interface() {
+			err := fmt.Sprintf("Error: attempting to divide %v by zero.\n", Cell1.TypeName)
+			return nil, errors.New(err)
+		}
+	}
 	returnVals := make([]*ListCell, 0, 1)
 	returnVal := ListCell{TypeName: Cell1.TypeName, Mutable: Cell1.Mutable}
 	if val1, ok1 := Cell1.Value.(int); ok1 {
@@ -324,6 +783,121 @@ func GoDivide(Cell1 *ListCell, Cell2 *ListCell) ([]*ListCell, error) {
 	return returnVals, nil
 }
 
+// isNumber reports whether cell holds one of the Go number types that the
+// arithmetic builtins work on.
+func isNumber(cell *ListCell) bool {
+	switch cell.Value.(type) {
+	case int, int64, int32, int16, float64, float32:
+		return true
+	}
+	return false
+}
+
+func compareNumbers(Cell1 *ListCell, Cell2 *ListCell) (int, error) {
+	if Cell1.TypeName != Cell2.TypeName {
+		err := fmt.Sprintf("Error: attempting to compare type %v with type %v, but these types are not compatible.\n", Cell1.TypeName, Cell2.TypeName)
+		return 0, errors.New(err)
+	}
+	switch val1 := Cell1.Value.(type) {
+	case int:
+		if val2, ok := Cell2.Value.(int); ok {
+			return compareOrdered(val1 < val2, val1 > val2), nil
+		}
+	case int64:
+		if val2, ok := Cell2.Value.(int64); ok {
+			return compareOrdered(val1 < val2, val1 > val2), nil
+		}
+	case int32:
+		if val2, ok := Cell2.Value.(int32); ok {
+			return compareOrdered(val1 < val2, val1 > val2), nil
+		}
+	case int16:
+		if val2, ok := Cell2.Value.(int16); ok {
+			return compareOrdered(val1 < val2, val1 > val2), nil
+		}
+	case float64:
+		if val2, ok := Cell2.Value.(float64); ok {
+			return compareOrdered(val1 < val2, val1 > val2), nil
+		}
+	case float32:
+		if val2, ok := Cell2.Value.(float32); ok {
+			return compareOrdered(val1 < val2, val1 > val2), nil
+		}
+	case string:
+		if val2, ok := Cell2.Value.(string); ok {
+			return strings.Compare(val1, val2), nil
+		}
+	}
+	err := fmt.Sprintf("Error: attempting to compare type %v with type %v, but they are not both numbers of the same kind.\n", Cell1.TypeName, Cell2.TypeName)
+	return 0, errors.New(err)
+}
+
+func compareOrdered(less, greater bool) int {
+	if less {
+		return -1
+	} else if greater {
+		return 1
+	}
+	return 0
+}
+
+func cellsEqual(Cell1 *ListCell, Cell2 *ListCell) bool {
+	if Cell1.TypeName != Cell2.TypeName {
+		return false
+	}
+	if list1, ok := Cell1.Value.([]ListCell); ok {
+		list2, ok := Cell2.Value.([]ListCell)
+		if !ok || len(list1) != len(list2) {
+			return false
+		}
+		for i := range list1 {
+			if !cellsEqual(&list1[i], &list2[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if equal, ok := collectionsEqual(Cell1, Cell2); ok {
+		return equal
+	}
+	if Cell1.Value == nil || Cell2.Value == nil {
+		return Cell1.Value == nil && Cell2.Value == nil
+	}
+	if !reflect.TypeOf(Cell1.Value).Comparable() || !reflect.TypeOf(Cell2.Value).Comparable() {
+		return false
+	}
+	return Cell1.Value == Cell2.Value
+}
+
+func GoCompare(funcType goFuncType, Cell1 *ListCell, Cell2 *ListCell) ([]*ListCell, error) {
+	returnVals := make([]*ListCell, 0, 1)
+	if funcType == GoEqualT {
+		returnVal := makeBoolCell(cellsEqual(Cell1, Cell2))
+		returnVals = append(returnVals, &returnVal)
+		return returnVals, nil
+	}
+	order, err := compareNumbers(Cell1, Cell2)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := ListCell{TypeName: BOOL_TYPE_NAME}
+	switch funcType {
+	case GoLessT:
+		returnVal.Value = order < 0
+	case GoGreaterT:
+		returnVal.Value = order > 0
+	case GoLessEqT:
+		returnVal.Value = order <= 0
+	case GoGreaterEqT:
+		returnVal.Value = order >= 0
+	default:
+		err := fmt.Sprintf("Error: attempting to compare with unhandled builtin of type number %v.\n", funcType)
+		return nil, errors.New(err)
+	}
+	returnVals = append(returnVals, &returnVal)
+	return returnVals, nil
+}
+
 func GoIf(Cell1 *ListCell, Cell2 *ListCell, Cell3 *ListCell) ([]*ListCell, error) {
 	returnVals := make([]*ListCell, 0, 1)
 	returnVal := new(ListCell)
@@ -335,15 +909,16 @@ func GoIf(Cell1 *ListCell, Cell2 *ListCell, Cell3 *ListCell) ([]*ListCell, error
 		}
 
 	} else {
-		err := fmt.Sprintf("Error: expected bool as first argument to if builtin but got something else.\n", Cell1.TypeName)
+		err := fmt.Sprintf("Error: expected bool as first argument to if builtin but got %v.\n", Cell1.TypeName)
 		return nil, errors.New(err)
 	}
 	returnVals = append(returnVals, returnVal)
 	return returnVals, nil
 }
 
-func EvalPrim(list []ListCell, env Environment) ([]*ListCell, error) {
-	if varName, ok := list[0].Value.(string); ok {
+func EvalPrim(list []ListCell, env *Environment) ([]*ListCell, error) {
+	if sym, ok := list[0].Value.(*SymbolObj); ok {
+		varName := sym.Name()
 		binding := env.findBinding(varName, true, true)
 		if binding == nil {
 			err := fmt.Sprintf("Error: var in first cell in list passed to eval builtin, %v, is not bound.\n", varName)
@@ -361,16 +936,15 @@ func EvalPrim(list []ListCell, env Environment) ([]*ListCell, error) {
 			return nil, errors.New(err)
 		}
 	} else {
-		err := fmt.Sprintf("Error: expected var in first cell of list passed to eval builtin, but it was actually not a var.\n")
+		err := fmt.Sprintf("Error: expected a Symbol in first cell of list passed to eval builtin, but got %v.\n", list[0].TypeName)
 		return nil, errors.New(err)
 	}
-	return nil, nil
 }
 
 func GoEval(Cell1 *ListCell, Cell2 *ListCell) ([]*ListCell, error) {
 	returnVals := make([]*ListCell, 0, 0)
 	if list, ok := Cell1.Value.([]ListCell); ok {
-		if env, ok2 := Cell1.Value.(Environment); ok2 {
+		if env, ok2 := Cell2.Value.(*Environment); ok2 {
 			returnValShad, err := EvalPrim(list, env)
 			if err != nil {
 				return nil, err
@@ -387,7 +961,7 @@ func GoEval(Cell1 *ListCell, Cell2 *ListCell) ([]*ListCell, error) {
 	return returnVals, nil
 }
 
-func Eval(list []ListCell, env Environment) ([]*ListCell, error) {
+func Eval(list []ListCell, env *Environment) ([]*ListCell, error) {
 	returnVals, err := EvalPrim(list, env)
 	if err != nil {
 		return nil, err
@@ -395,123 +969,3 @@ func Eval(list []ListCell, env Environment) ([]*ListCell, error) {
 		return returnVals, nil
 	}
 }
-
-func parseText(input []rune) (CellList, int, error) {
-	list := CellList{}
-	listCells := make([]ListCell, 0, 10)
-	for i := 0; i < len(input); i++ {
-		if unicode.IsSpace(input[i]) {
-			continue
-		}
-		switch input[i] {
-		case ' ':
-			continue
-		case '"':
-			str, strLen, err := parseStringLit(input[i:])
-			if err != nil {
-				return list, i, err
-			} else {
-				listCells = append(listCells, ListCell{Mutable: true, Value: str})
-				i += strLen
-			}
-		case '\\':
-			if i < len(input)+1 {
-				listCells = append(listCells, ListCell{Mutable: true, Value: input[i+1]})
-				i++
-			} else {
-				err := fmt.Sprintf("Error: escape character followed by EOF encountered.\n")
-				return list, i, errors.New(err)
-			}
-		case '(':
-			nestedList, listLen, err := parseText(input[i:])
-			if err != nil {
-				return list, i, err
-			} else {
-				listCells = append(listCells, ListCell{Mutable: true, Value: nestedList})
-				i += listLen
-			}
-
-		case ')':
-			list.Cells = listCells
-			return list, i, nil
-		}
-		if unicode.IsDigit(input[i]) {
-			num, numLen, err := parseNumLit(input[i:])
-			if err != nil {
-				return list, i, err
-			} else {
-				listCells = append(listCells, ListCell{Mutable: true, Value: num})
-				i += numLen
-			}
-
-		} else if unicode.IsLetter(input[i]) {
-			indentifier, identLen, err := parseIdentifier(input[i:])
-			if err != nil {
-				return list, i, err
-			} else {
-				listCells = append(listCells, ListCell{Mutable: true, Value: indentifier})
-				i += identLen
-			}
-		} else {
-			err := fmt.Sprintf("Error: unhandled character encountered.\n")
-			return list, i, errors.New(err)
-		}
-	}
-	err := fmt.Sprintf("Error: attempting to parse empty list.\n")
-	return list, 0, errors.New(err)	
-
-}
-
-func parseStringLit(input []rune) (string, int, error) {
-	for i := 1; i < len(input); i++ {
-		if input[i] == '"' && input[i-1] != '\\' {
-			return fmt.Sprint(input), i, nil
-		}
-	}
-	err := fmt.Sprintf("Error: unterminated string encountered.\n")
-	return "", 0, errors.New(err)
-}
-
-func parseIdentifier(input []rune) (string, int, error) {
-	i := 0
-	for ; i < len(input); i++ {
-		if unicode.IsSpace(input[i]) || input[i] == ')' {
-			break
-		}
-	}
-	return string(input[:i]), i, nil
-}
-
-func parseNumLit(input []rune) (ListCell, int, error) {
-	numPeriods := 0
-	i := 0
-	for ; i < len(input); i++ {
-		if input[i] == '.' {
-			numPeriods++
-			continue
-		}
-		if !unicode.IsNumber(input[i]) {
-			break
-		}
-	}
-	switch numPeriods {
-	case 0:
-		newInt, err := strconv.ParseInt(string(input[:i]), 0, 0)
-		if err != nil {
-			return ListCell{}, i, err
-		} else {
-			return ListCell{Mutable: true, Value: newInt}, i, nil
-		}
-	case 1:
-		newFloat, err := strconv.ParseFloat(string(input[:i]), 0)
-		if err != nil {
-			return ListCell{}, i, err
-		} else {
-			return ListCell{Mutable: true, Value: newFloat}, i, nil
-		}
-	default:
-		err := fmt.Sprintf("Error: poorly formatted float encountered; too many periods.\n")
-		return ListCell{}, i, errors.New(err)
-	}
-
-}
@@ -0,0 +1,47 @@
+package Golly
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestArithmeticRejectsNonNumbers(t *testing.T) {
+	tests := []string{
+		`(+ "a" "b")`,
+		`(- :a :b)`,
+		`(* (list 1) (list 2))`,
+		`(/ true false)`,
+		`(+ 'a 'b)`,
+	}
+	for _, bytecode := range []bool{false, true} {
+		for _, src := range tests {
+			env := NewEnvironment(CreateSystemFuncs())
+			result, err := EvalString(context.Background(), src, env, EvalOptions{Bytecode: bytecode})
+			if err == nil || !strings.Contains(err.Error(), "only numbers can be") {
+				t.Errorf("%v with bytecode %v returned %v, %v, want a type error", src, bytecode, result, err)
+			}
+		}
+	}
+}
+
+func TestArithmeticOnNumbers(t *testing.T) {
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{"(+ 1 2)", 3},
+		{"(- 1.5 0.5)", 1.0},
+		{"(* 3 4)", 12},
+		{"(/ 7 2)", 3},
+	}
+	for _, bytecode := range []bool{false, true} {
+		for _, test := range tests {
+			env := NewEnvironment(CreateSystemFuncs())
+			result, err := EvalString(context.Background(), test.src, env, EvalOptions{Bytecode: bytecode})
+			if err != nil || result.Value != test.want {
+				t.Errorf("%v with bytecode %v returned %v, %v, want %v", test.src, bytecode, result.Value, err, test.want)
+			}
+		}
+	}
+}
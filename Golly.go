@@ -1,52 +1,117 @@
+// Package Golly implements the Golly language: a parser, a tree-walking
+// evaluator and a bytecode compiler and VM. Programs embedding Golly
+// should use Interpreter; the lower-level functions are what it is built
+// on.
 package Golly
 
 import (
 	"Golly/parser"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"strconv"
+	"strings"
+	"sync"
 )
 
 type FunctionObj struct {
-	Parems []string
-	Body   []ListCell
-	Pure   bool
-	GoFunc bool
-	FuncType goFuncType
+	Parems     []string
+	Body       []ListCell
+	Pure       bool
+	GoFunc     bool
+	FuncType   goFuncType
+	Name       string
+	BodyTokens []Parser.Token
+	Env        *Environment
+	proto      *funcProto
+	scope      *vmScope
+	memo       *memoTable
+	host       func(args ...ListCell) (ListCell, error)
 }
 
-func (aFunc *FunctionObj) Call(params []ListCell, env Environment)([]*ListCell, error) {
-	if aFunc.GoFunc{
-		returnedVals, err := CallGoFunc(aFunc.FuncType, params)
-		if err != nil{
+func (aFunc *FunctionObj) Call(params []ListCell, env *Environment) ([]*ListCell, error) {
+	if aFunc.memo != nil {
+		return aFunc.memo.call(aFunc, params, env)
+	}
+	env.evalState().step()
+	if aFunc.host != nil {
+		returnedVal, err := aFunc.callHost(params)
+		if err != nil {
 			return nil, err
-		}else{
+		} else {
+			return []*ListCell{&returnedVal}, nil
+		}
+	} else if aFunc.GoFunc {
+		returnedVals, err := CallGoFunc(aFunc.FuncType, params, env)
+		if err != nil {
+			return nil, err
+		} else {
 			return returnedVals, nil
 		}
-	}else{
+	} else if aFunc.proto != nil {
+		returnedVal, err := runClosure(aFunc, params, env.evalState())
+		if err != nil {
+			return nil, err
+		} else {
+			return []*ListCell{&returnedVal}, nil
+		}
+	} else if aFunc.BodyTokens != nil {
+		returnedVal, err := aFunc.callTokens(params, env.evalState())
+		if err != nil {
+			return nil, err
+		} else {
+			return []*ListCell{&returnedVal}, nil
+		}
+	} else {
 		returnedVals, err := Eval(aFunc.Body, env)
-		if err != nil{
+		if err != nil {
 			return nil, err
-		}else{
+		} else {
 			return returnedVals, nil
 		}
 	}
 }
 
-func makeSysFunc(funcType goFuncType)ListCell{
-	return ListCell{TypeName: FUNCTION_TYPE_NAME, 
-		Value: FunctionObj{Pure: true, GoFunc: true, FuncType: funcType},
+func (aFunc *FunctionObj) callTokens(params []ListCell, state *evalState) (returnedVal ListCell, err error) {
+	defer recoverEvalError(&err)
+	if len(params) != len(aFunc.Parems) {
+		errMsg := fmt.Sprintf("Error: function %v expects %v arguments but was called with %v.\n", aFunc.Name, len(aFunc.Parems), len(params))
+		panic(errMsg)
+	}
+	state.enter()
+	defer state.leave()
+	state.alloc(len(params))
+	callEnv := newChildEnvironment(aFunc.Env)
+	callEnv.state = state
+	for i, name := range aFunc.Parems {
+		param := params[i]
+		param.Mutable = false
+		callEnv.Bindings = append(callEnv.Bindings, EnvBinding{Name: name, Binding: param})
+	}
+	return evalBody(aFunc.BodyTokens, callEnv), nil
+}
+
+func (aFunc *FunctionObj) callHost(params []ListCell) (returnedVal ListCell, err error) {
+	defer recoverEvalError(&err)
+	return aFunc.host(params...)
+}
+
+func makeSysFunc(funcType goFuncType) ListCell {
+	return ListCell{TypeName: FUNCTION_TYPE_NAME,
+		Value:   FunctionObj{Pure: !impureGoFuncs[funcType], GoFunc: true, FuncType: funcType, Name: goFuncNames[funcType]},
 		Mutable: false}
 }
 
-func CreateSystemFuncs() *SysEnvironment{
-	sysBindings := make(map[string]EnvBinding)
-	sysBindings["if"] = EnvBinding{Binding: makeSysFunc(GoIfT)}
-	sysBindings["+"] = EnvBinding{Binding: makeSysFunc(GoAddT)}
-	sysBindings["-"] = EnvBinding{Binding: makeSysFunc(GoSubtractT)}
-	sysBindings["*"] = EnvBinding{Binding: makeSysFunc(GoMultiplyT)}
-	sysBindings["/"] = EnvBinding{Binding: makeSysFunc(GoDivideT)}
-	env := SysEnvironment{Bindings: sysBindings}
-	return &env
+func makeSysType() ListCell {
+	return ListCell{TypeName: TYPE_TYPE_NAME, Value: TypeObj{}, Mutable: false}
+}
+
+// CreateSystemFuncs creates a SysEnvironment granting every capability,
+// reading from os.Stdin and writing to os.Stdout.
+func CreateSystemFuncs() *SysEnvironment {
+	env, _ := NewSysEnvironment(AllCapabilities...)
+	return env
 }
 
 type singleType struct {
@@ -67,11 +132,11 @@ func TypesEqualP(first *ListCell, second *ListCell, lineNum int, caller *string)
 		if secondType, ok := second.Value.(TypeObj); ok {
 			return firstType.EqualTo(&secondType)
 		} else {
-			errMsg := fmt.Sprintf("Error: cell claiming to be a type actually contains something else, in %v at line %v.\n", caller, lineNum)
+			errMsg := fmt.Sprintf("Error: cell claiming to be a type actually contains something else, in %v at line %v.\n", *caller, lineNum)
 			panic(errMsg)
 		}
 	} else {
-		errMsg := fmt.Sprintf("Error: cell claiming to be a type actually contains something else, in %v at line %v.\n", caller, lineNum)
+		errMsg := fmt.Sprintf("Error: cell claiming to be a type actually contains something else, in %v at line %v.\n", *caller, lineNum)
 		panic(errMsg)
 	}
 }
@@ -83,20 +148,62 @@ type EnvBinding struct {
 
 type SysEnvironment struct {
 	Bindings map[string]EnvBinding
+	// Denied maps the builtins left out by NewSysEnvironment to the
+	// capability each needs.
+	Denied map[string]Capability
+	// Stdout and Stdin are used by the io builtins.
+	Stdout io.Writer
+	Stdin  io.Reader
+	io     *sysIO
 }
 
+// Environment holds the bindings of one scope. The root environment holds
+// the globals, and each let and function call evaluates in a child of the
+// environment it was made in.
+//
+// Environments may be shared between goroutines. The SysEnvironment is
+// never modified after it is built, so it is read without locking. A
+// child environment's bindings are all made before anything else can see
+// it, and let always binds into a new child, so locals never change once
+// visible. Globals, including those that defm rebinds, are guarded by a
+// lock in the root; code outside this package should read them with
+// Globals rather than through the root's Bindings.
 type Environment struct {
-	Bindings []EnvBinding
-	Parent   *Environment
-	System   *SysEnvironment
+	Bindings    []EnvBinding
+	Parent      *Environment
+	System      *SysEnvironment
+	globalIndex map[string]int
+	globalsMu   *sync.RWMutex
+	state       *evalState
+}
+
+func NewEnvironment(system *SysEnvironment) *Environment {
+	return &Environment{System: system, globalsMu: &sync.RWMutex{}}
+}
+
+func newChildEnvironment(parent *Environment) *Environment {
+	return &Environment{Parent: parent, System: parent.System, state: parent.state}
+}
+
+func (env *Environment) root() *Environment {
+	for env.Parent != nil {
+		env = env.Parent
+	}
+	return env
 }
 
 func (env Environment) findBinding(name string, recur, checkSystem bool) *EnvBinding {
-	if checkSystem {
+	if checkSystem && env.System != nil {
 		if binding, ok := env.System.Bindings[name]; ok {
 			return &binding
 		}
 	}
+	if env.Parent == nil {
+		if binding, ok := env.loadGlobal(name); ok {
+			return &binding
+		}
+		return nil
+	}
 	for i, binding := range env.Bindings {
 		if binding.Name == name {
 			return &env.Bindings[i]
@@ -112,17 +219,107 @@ func (env Environment) findBinding(name string, recur, checkSystem bool) *EnvBin
 	return nil
 }
 
+// lockGlobals and the functions below guard the root's bindings. A root
+// made without NewEnvironment has no lock and is left unguarded.
+func (root *Environment) lockGlobals(write bool) func() {
+	if root.globalsMu == nil {
+		return func() {}
+	}
+	if write {
+		root.globalsMu.Lock()
+		return root.globalsMu.Unlock
+	}
+	root.globalsMu.RLock()
+	return root.globalsMu.RUnlock
+}
+
+// globalSlot finds name among the bindings of a root environment, using
+// the index kept by addNamedBinding where it can. The caller must hold the
+// root's lock.
+func (root *Environment) globalSlot(name string) int {
+	if i, ok := root.globalIndex[name]; ok && i < len(root.Bindings) && root.Bindings[i].Name == name {
+		return i
+	}
+	for i, binding := range root.Bindings {
+		if binding.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (env *Environment) loadGlobal(name string) (EnvBinding, bool) {
+	root := env.root()
+	defer root.lockGlobals(false)()
+	if i := root.globalSlot(name); i >= 0 {
+		return root.Bindings[i], true
+	}
+	return EnvBinding{}, false
+}
+
+// setGlobal binds name to value in the root environment, replacing a
+// mutable global of the same name. It reports false, binding nothing, if
+// name is a builtin or an immutable global.
+func (env *Environment) setGlobal(name string, value ListCell) bool {
+	if env.System != nil {
+		if _, ok := env.System.Bindings[name]; ok {
+			return false
+		}
+	}
+	root := env.root()
+	defer root.lockGlobals(true)()
+	if i := root.globalSlot(name); i >= 0 {
+		if !root.Bindings[i].Binding.Mutable {
+			return false
+		}
+		root.Bindings[i].Binding = value
+		return true
+	}
+	root.addNamedBinding(name).Binding = value
+	return true
+}
+
+// Globals returns a snapshot of the global bindings, in the order they
+// were first bound.
+func (env *Environment) Globals() []EnvBinding {
+	root := env.root()
+	defer root.lockGlobals(false)()
+	return append([]EnvBinding{}, root.Bindings...)
+}
+
+// DefineGlobal binds name to value in the root environment, the way def
+// does. Builtins and immutable globals cannot be rebound.
+func (env *Environment) DefineGlobal(name string, value ListCell) error {
+	if !env.setGlobal(name, value) {
+		err := fmt.Sprintf("Error: attempting to assign to an immutable identifier %v.\n", name)
+		return errors.New(err)
+	}
+	return nil
+}
+
+func (env *Environment) addNamedBinding(name string) *EnvBinding {
+	newBinding := env.addBinding(false)
+	newBinding.Name = name
+	if env.Parent == nil {
+		if env.globalIndex == nil {
+			env.globalIndex = make(map[string]int)
+		}
+		env.globalIndex[name] = len(env.Bindings) - 1
+	}
+	return newBinding
+}
+
 func (env *Environment) addBinding(recur bool) *EnvBinding {
 	if recur {
 		if env.Parent == nil {
 			env.Bindings = append(env.Bindings, EnvBinding{})
-			return &((env.Bindings)[len(env.Bindings)])
+			return &((env.Bindings)[len(env.Bindings)-1])
 		} else {
 			return env.Parent.addBinding(true)
 		}
 	} else {
 		env.Bindings = append(env.Bindings, EnvBinding{})
-		return &((env.Bindings)[len(env.Bindings)])
+		return &((env.Bindings)[len(env.Bindings)-1])
 	}
 }
 
@@ -137,44 +334,101 @@ type CellList struct {
 	Env   Environment
 }
 
+func makeBoolCell(val bool) ListCell {
+	return ListCell{TypeName: BOOL_TYPE_NAME, Value: val}
+}
+
+func makeNilCell() ListCell {
+	return ListCell{TypeName: NIL_TYPE_NAME}
+}
+
+func firstReturnVal(returnedVals []*ListCell) ListCell {
+	if len(returnedVals) == 0 || returnedVals[0] == nil {
+		return makeNilCell()
+	}
+	return *returnedVals[0]
+}
+
+func recoverEvalError(err *error) {
+	if r := recover(); r != nil {
+		switch e := r.(type) {
+		case error:
+			*err = e
+		case string:
+			*err = errors.New(e)
+		default:
+			*err = fmt.Errorf("Error: %v.\n", e)
+		}
+	}
+}
+
 func evalLitToken(num *Parser.Token, lineNum int, caller *string) ListCell {
 	newValue := ListCell{}
 	switch (*num).LitType {
 	case Parser.FloNum:
-		floatval, err := strconv.ParseFloat((*num).Value, 32)
+		floatval, err := Parser.ParseFloat((*num).Value)
 		if err != nil {
-			errMsg := fmt.Sprintf("Error: cannot parse string %v to float in %v at line %v.\n", (*num).Value, caller, lineNum)
+			errMsg := fmt.Sprintf("Error: cannot parse string %v to float in %v at line %v.\n", (*num).Value, *caller, lineNum)
 			panic(errMsg)
 		} else {
 			newValue.Value = floatval
-			newValue.TypeName = "float"
+			newValue.TypeName = FLOAT_TYPE_NAME
 		}
 	case Parser.FixNum:
 		intval, err := strconv.Atoi((*num).Value)
 		if err != nil {
-			errMsg := fmt.Sprintf("Error: cannot parse string %v to int in %v at line %v.\n", (*num).Value, caller, lineNum)
+			errMsg := fmt.Sprintf("Error: cannot parse string %v to int in %v at line %v.\n", (*num).Value, *caller, lineNum)
 			panic(errMsg)
 		} else {
 			newValue.Value = intval
-			newValue.TypeName = "int"
+			newValue.TypeName = INT_TYPE_NAME
 		}
+	case Parser.String:
+		newValue.Value = (*num).Value
+		newValue.TypeName = STRING_TYPE_NAME
+	case Parser.Keyword:
+		newValue = makeKeywordCell(InternKeyword((*num).Value))
+	case Parser.Char:
+		newValue = makeCharCell([]rune((*num).Value)[0])
+	case Parser.Regex:
+		re, err := compileRegex((*num).Value)
+		if err != nil {
+			errMsg := fmt.Sprintf("Error: cannot compile regex %v in %v at line %v.\n", (*num).Value, *caller, lineNum)
+			panic(errMsg)
+		}
+		newValue = makeRegexCell(re)
 	default:
-		errMsg := fmt.Sprintf("Error: unhandled literal type for %v in %v at line %v.\n", (*num).Value, caller, lineNum)
+		errMsg := fmt.Sprintf("Error: unhandled literal type for %v in %v at line %v.\n", (*num).Value, *caller, lineNum)
 		panic(errMsg)
 	}
 	return newValue
 }
 
-func evalIdToken(identifierName *Parser.Token, env *Environment, lineNum int, caller *string) interface{} {
-	var newValue interface{}
-	valueReferenced := env.findBinding((*identifierName).Value, true, true)
+func evalIdToken(identifierName *Parser.Token, env *Environment, lineNum int, caller *string) ListCell {
+	var valueReferenced *EnvBinding
+	switch identifierName.Scope {
+	case Parser.LocalScope:
+		target := env
+		for depth := identifierName.Depth; depth > 0; depth-- {
+			target = target.Parent
+		}
+		valueReferenced = &target.Bindings[identifierName.Index]
+	case Parser.GlobalScope:
+		if binding, ok := env.loadGlobal(identifierName.Value); ok {
+			valueReferenced = &binding
+		}
+	case Parser.SystemScope:
+		if binding, ok := env.System.Bindings[identifierName.Value]; ok {
+			valueReferenced = &binding
+		}
+	default:
+		valueReferenced = env.findBinding((*identifierName).Value, true, true)
+	}
 	if valueReferenced == nil {
-		errMsg := fmt.Sprintf("Error: attempting to evalute var %v in %v at line %v, but that var is unbound.\n", (*identifierName).Value, caller, lineNum)
+		errMsg := fmt.Sprintf("Error: attempting to evalute var %v in %v at line %v, but that var is unbound.\n", (*identifierName).Value, *caller, lineNum)
 		panic(errMsg)
-	} else {
-		newValue = valueReferenced.Binding
 	}
-	return newValue
+	return valueReferenced.Binding
 }
 
 func parseType(identifierToBindTo, potentialType *Parser.Token, env *Environment, lineNum int, caller *string) (*ListCell, string) {
@@ -183,16 +437,16 @@ func parseType(identifierToBindTo, potentialType *Parser.Token, env *Environment
 	var newType *ListCell
 	switch (*potentialType).Type {
 	case Parser.LiteralToken:
-		errMsg := fmt.Sprintf("Error: attempting use a numeric literal as the type for %v in %v at line %v.\n", identifierToBindTo.Value, caller, lineNum)
+		errMsg := fmt.Sprintf("Error: attempting use a literal as the type for %v in %v at line %v.\n", identifierToBindTo.Value, *caller, lineNum)
 		panic(errMsg)
-	case Parser.DefToken:
-		errMsg := fmt.Sprintf("Error: attempting use a reserved name as the type for %v in %v at line %v.\n", identifierToBindTo.Value, caller, lineNum)
+	case Parser.DefToken, Parser.FormToken:
+		errMsg := fmt.Sprintf("Error: attempting use a reserved name as the type for %v in %v at line %v.\n", identifierToBindTo.Value, *caller, lineNum)
 		panic(errMsg)
 	case Parser.IdToken:
 		potentialNewTypeValue := env.findBinding(potentialType.Value, true, true)
 		if potentialNewTypeValue != nil {
-			if potentialNewTypeValue.Binding.TypeName != "type" {
-				errMsg := fmt.Sprintf("Error: attempting to assign something that is not a type, but a %v, to %v in %v at line %v.\n", potentialNewTypeValue.Binding.TypeName, identifierToBindTo.Value, caller, lineNum)
+			if potentialNewTypeValue.Binding.TypeName != TYPE_TYPE_NAME {
+				errMsg := fmt.Sprintf("Error: attempting to assign something that is not a type, but a %v, to %v in %v at line %v.\n", potentialNewTypeValue.Binding.TypeName, identifierToBindTo.Value, *caller, lineNum)
 				panic(errMsg)
 			} else {
 				newTypeName = potentialType.Value
@@ -200,13 +454,13 @@ func parseType(identifierToBindTo, potentialType *Parser.Token, env *Environment
 			}
 			//Handle var containing quoted type here
 		} else {
-			errMsg := fmt.Sprintf("Error: attempting to assign identifier %v to %v in %v at line %v, but %v is unbound.\n", (*potentialType).Value, (*identifierToBindTo).Value, caller, lineNum, (*potentialType).Value)
+			errMsg := fmt.Sprintf("Error: attempting to assign identifier %v to %v in %v at line %v, but %v is unbound.\n", (*potentialType).Value, (*identifierToBindTo).Value, *caller, lineNum, (*potentialType).Value)
 			panic(errMsg)
 		}
 	case Parser.ListToken:
-		potentialNewType := evalListToken(potentialType)
-		if newType.TypeName != "type" {
-			errMsg := fmt.Sprintf("Error: attempting to assign something that is not a type, but a %v, to %v in %v at line %v.\n", newType.TypeName, (*identifierToBindTo).Value, caller, lineNum)
+		potentialNewType := evalListToken(potentialType, env)
+		if potentialNewType.TypeName != TYPE_TYPE_NAME {
+			errMsg := fmt.Sprintf("Error: attempting to assign something that is not a type, but a %v, to %v in %v at line %v.\n", potentialNewType.TypeName, (*identifierToBindTo).Value, *caller, lineNum)
 			panic(errMsg)
 		} else {
 			typeLiteralFound = true
@@ -214,78 +468,95 @@ func parseType(identifierToBindTo, potentialType *Parser.Token, env *Environment
 		}
 		//Handle function returning quoted type here
 	case Parser.TypeAnnToken:
-		errMsg := fmt.Sprintf("Error: misplaced type annotation marker in %v at line %v.\n", caller, lineNum)
+		errMsg := fmt.Sprintf("Error: misplaced type annotation marker in %v at line %v.\n", *caller, lineNum)
 		panic(errMsg)
 	default:
-		errMsg := fmt.Sprintf("Error: unhandled token type in %v at line %v.\n", caller, lineNum)
+		errMsg := fmt.Sprintf("Error: unhandled token type in %v at line %v.\n", *caller, lineNum)
 		panic(errMsg)
 	}
 	if typeLiteralFound {
 		if _, ok := newType.Value.(TypeObj); !ok {
-			errMsg := fmt.Sprintf("Error: cell claiming to be a type actually contains something else, in %v at line %v.\n", caller, lineNum)
+			errMsg := fmt.Sprintf("Error: cell claiming to be a type actually contains something else, in %v at line %v.\n", *caller, lineNum)
 			panic(errMsg)
 		}
 	}
 	return newType, newTypeName
 }
 
-func parseNewIdentifier(val *Parser.Token, env *Environment, global bool, lineNum int, caller *string) *EnvBinding {
+func checkNewIdentifier(val *Parser.Token, lineNum int, caller *string) {
 	if val.Type != Parser.IdToken {
-		errMsg := fmt.Sprintf("Error: attempting to assign to a non-identifier in %v at line %v.\n", caller, lineNum)
+		errMsg := fmt.Sprintf("Error: attempting to assign to a non-identifier in %v at line %v.\n", *caller, lineNum)
+		panic(errMsg)
+	}
+}
+
+func bindGlobal(val *Parser.Token, value ListCell, env *Environment, lineNum int, caller *string) {
+	checkNewIdentifier(val, lineNum, caller)
+	if !env.setGlobal(val.Value, value) {
+		errMsg := fmt.Sprintf("Error: attempting to assign to an immutable identifier %v in %v at line %v.\n", val.Value, *caller, lineNum)
 		panic(errMsg)
 	}
+}
+
+func parseNewIdentifier(val *Parser.Token, env *Environment, lineNum int, caller *string) *EnvBinding {
+	checkNewIdentifier(val, lineNum, caller)
 	prevBinding := env.findBinding(val.Value, false, true)
 	var newBinding *EnvBinding
 	if prevBinding != nil {
 		if !(*prevBinding).Binding.Mutable {
-			errMsg := fmt.Sprintf("Error: attempting to assign to an immutable identifier in %v at line %v.\n", caller, lineNum)
+			errMsg := fmt.Sprintf("Error: attempting to assign to an immutable identifier %v in %v at line %v.\n", val.Value, *caller, lineNum)
 			panic(errMsg)
 		} else {
 			newBinding = prevBinding
 		}
 	} else {
-		newBinding = env.addBinding(global)
+		newBinding = env.addNamedBinding(val.Value)
 	}
 	return newBinding
 }
 
 func parseIdentifierToBeBound(identifierToBeBoundTo, identifierToBind *Parser.Token, env *Environment, global bool, lineNum int, caller *string) *ListCell {
-	newValue := ListCell{TypeName: "undecided"}
+	newValue := ListCell{TypeName: UNDECIDED_TYPE_NAME}
 	switch (*identifierToBind).Type {
 	case Parser.LiteralToken:
 		newValue = evalLitToken(identifierToBind, lineNum, caller)
-	case Parser.DefToken:
-		errMsg := fmt.Sprintf("Error: attempting to assign reserved name %v to %v in %v at line %v.\n", identifierToBind.Value, identifierToBeBoundTo.Value, caller, lineNum)
+	case Parser.DefToken, Parser.FormToken:
+		errMsg := fmt.Sprintf("Error: attempting to assign reserved name %v to %v in %v at line %v.\n", identifierToBind.Value, identifierToBeBoundTo.Value, *caller, lineNum)
 		panic(errMsg)
 	case Parser.IdToken:
 		potentialNewValue := env.findBinding(identifierToBind.Value, true, true)
 		if potentialNewValue != nil {
-			newValue.Value = potentialNewValue
+			newValue = potentialNewValue.Binding
 		} else {
-			errMsg := fmt.Sprintf("Error: attempting to assign identifier %v to %v in %v at line %v, but %v is unbound.\n", identifierToBind.Value, identifierToBeBoundTo.Value, caller, lineNum, identifierToBind.Value)
+			errMsg := fmt.Sprintf("Error: attempting to assign identifier %v to %v in %v at line %v, but %v is unbound.\n", identifierToBind.Value, identifierToBeBoundTo.Value, *caller, lineNum, identifierToBind.Value)
 			panic(errMsg)
 		}
 	case Parser.ListToken:
-		newValue.Value = evalListToken(identifierToBind)
+		newValue = evalListToken(identifierToBind, env)
+		if aFunc, ok := newValue.Value.(FunctionObj); ok && aFunc.Name == "" {
+			aFunc.Name = identifierToBeBoundTo.Value
+			newValue.Value = aFunc
+		}
+	case Parser.VectorToken, Parser.MapToken:
+		newValue = evalCollectionToken(identifierToBind, env)
 	case Parser.TypeAnnToken:
-		errMsg := fmt.Sprintf("Error: expected identifier to %v in %v at line %v, but got type annotation token \":\".\n", identifierToBeBoundTo.Value, caller, lineNum)
+		errMsg := fmt.Sprintf("Error: expected identifier to %v in %v at line %v, but got type annotation token \":\".\n", identifierToBeBoundTo.Value, *caller, lineNum)
 		panic(errMsg)
 	default:
-		errMsg := fmt.Sprintf("Error: unhandled token type for %v in %v at line %v.\n", identifierToBind.Value, caller, lineNum)
+		errMsg := fmt.Sprintf("Error: unhandled token type for %v in %v at line %v.\n", identifierToBind.Value, *caller, lineNum)
 		panic(errMsg)
 	}
 	return &newValue
 }
 
-func bindVars(list *Parser.Token, env Environment, lineNum int, global, mut bool, caller *string) Environment {
+func bindVars(list *Parser.Token, env *Environment, lineNum int, global, mut bool, caller *string) ListCell {
+	lastBound := makeNilCell()
 	for i := 0; i < len(list.ListVals); i++ {
 		howManyIndicesToJumpForward := 1
 		isTypeAnnotated := false
 		firstListItem := &list.ListVals[i]
-		newBinding := parseNewIdentifier(firstListItem, &env, global, lineNum, caller)
-		newBinding.Name = firstListItem.Value
-		if i >= len(list.ListVals) {
-			errMsg := fmt.Sprintf("Error: nothing to assign to %v in %v at line %v.\n", firstListItem.Value, caller, lineNum)
+		if i+1 >= len(list.ListVals) {
+			errMsg := fmt.Sprintf("Error: nothing to assign to %v in %v at line %v.\n", firstListItem.Value, *caller, lineNum)
 			panic(errMsg)
 		}
 		var potentialNewValue *ListCell
@@ -293,75 +564,377 @@ func bindVars(list *Parser.Token, env Environment, lineNum int, global, mut bool
 		var annotatedTypeValue *ListCell
 		nextListItem := &list.ListVals[i+1]
 		if nextListItem.Type == Parser.TypeAnnToken {
-			if i+2 >= len(list.ListVals) {
-				errMsg := fmt.Sprintf("Error: no type and/or value provided in assignment to %v in %v at line %v.\n", firstListItem.Value, caller, lineNum)
+			if i+3 >= len(list.ListVals) {
+				errMsg := fmt.Sprintf("Error: no type and/or value provided in assignment to %v in %v at line %v.\n", firstListItem.Value, *caller, lineNum)
 				panic(errMsg)
 			} else {
 				potentialTypeItem := &list.ListVals[i+2]
-				annotatedTypeValue, annotatedTypeName = parseType(firstListItem, potentialTypeItem, &env, lineNum, caller)
+				annotatedTypeValue, annotatedTypeName = parseType(firstListItem, potentialTypeItem, env, lineNum, caller)
 				potentialNewValueItem := &list.ListVals[i+3]
-				potentialNewValue = parseIdentifierToBeBound(firstListItem, potentialNewValueItem, &env, global, lineNum, caller)
+				potentialNewValue = parseIdentifierToBeBound(firstListItem, potentialNewValueItem, env, global, lineNum, caller)
 				howManyIndicesToJumpForward = 3
 				isTypeAnnotated = true
 			}
 		} else {
-			potentialNewValue = parseIdentifierToBeBound(firstListItem, nextListItem, &env, global, lineNum, caller)
+			potentialNewValue = parseIdentifierToBeBound(firstListItem, nextListItem, env, global, lineNum, caller)
 		}
 		if isTypeAnnotated {
-			if (annotatedTypeName == "" && TypesEqualP(annotatedTypeValue, &env.findBinding(potentialNewValue.TypeName, true, true).Binding, lineNum, caller)) ||
-				(potentialNewValue.TypeName == "undecided" && potentialNewValue.TypeName == annotatedTypeName) {
-				if annotatedTypeName != "" {
-					potentialNewValue.TypeName = annotatedTypeName
-				}
-			} else {
-				errMsg := fmt.Sprintf("Error: attempting to assign type %v to %v in %v at line %v, but it is already of type %v.\n", annotatedTypeName, firstListItem.Value, caller, lineNum, potentialNewValue.TypeName)
+			typeMatches := false
+			if annotatedTypeName != "" {
+				typeMatches = potentialNewValue.TypeName == annotatedTypeName
+			} else if valueType := env.findBinding(potentialNewValue.TypeName, true, true); valueType != nil {
+				typeMatches = TypesEqualP(annotatedTypeValue, &valueType.Binding, lineNum, caller)
+			}
+			if !typeMatches {
+				errMsg := fmt.Sprintf("Error: attempting to assign type %v to %v in %v at line %v, but it is already of type %v.\n", annotatedTypeName, firstListItem.Value, *caller, lineNum, potentialNewValue.TypeName)
 				panic(errMsg)
 			}
 		}
+		env.state.alloc(1)
 		potentialNewValue.Mutable = mut
-		newBinding.Binding = *potentialNewValue
+		if global {
+			bindGlobal(firstListItem, *potentialNewValue, env, lineNum, caller)
+		} else {
+			newBinding := parseNewIdentifier(firstListItem, env, lineNum, caller)
+			newBinding.Name = firstListItem.Value
+			newBinding.Binding = *potentialNewValue
+		}
+		lastBound = *potentialNewValue
 		i += howManyIndicesToJumpForward
 	}
-	return env
+	return lastBound
+}
+
+func evalToken(tok *Parser.Token, env *Environment) ListCell {
+	env.state.step()
+	caller := "eval"
+	switch tok.Type {
+	case Parser.LiteralToken:
+		return evalLitToken(tok, tok.LineNum, &caller)
+	case Parser.IdToken:
+		return evalIdToken(tok, env, tok.LineNum, &caller)
+	case Parser.ListToken:
+		return evalListToken(tok, env)
+	case Parser.VectorToken, Parser.MapToken:
+		return evalCollectionToken(tok, env)
+	case Parser.DefToken, Parser.FormToken:
+		errMsg := fmt.Sprintf("Error: attempting to evaluate reserved name %v outside of a list at line %v.\n", tok.Value, tok.LineNum)
+		panic(errMsg)
+	case Parser.TypeAnnToken:
+		errMsg := fmt.Sprintf("Error: misplaced type annotation marker at line %v.\n", tok.LineNum)
+		panic(errMsg)
+	default:
+		errMsg := fmt.Sprintf("Error: unhandled token type for %v at line %v.\n", tok.Value, tok.LineNum)
+		panic(errMsg)
+	}
 }
 
-func evalListToken(list *Parser.Token) ListCell {
+func evalBody(body []Parser.Token, env *Environment) ListCell {
+	result := makeNilCell()
+	for i := range body {
+		result = evalToken(&body[i], env)
+	}
+	return result
+}
+
+// evalCollectionToken evaluates the elements of a vector or map literal.
+func evalCollectionToken(tok *Parser.Token, env *Environment) ListCell {
+	vals := make([]ListCell, len(tok.ListVals))
+	for i := range tok.ListVals {
+		vals[i] = evalToken(&tok.ListVals[i], env)
+		vals[i].Mutable = false
+	}
+	if tok.Type == Parser.VectorToken {
+		return makeVectorCell(vals, env)
+	}
+	result, err := makeMapCell(vals, env)
+	if err != nil {
+		errMsg := fmt.Sprintf("%v at line %v.\n", strings.TrimSuffix(err.Error(), ".\n"), tok.LineNum)
+		panic(errMsg)
+	}
+	return result
+}
+
+func evalListToken(list *Parser.Token, env *Environment) ListCell {
+	if len(list.ListVals) == 0 {
+		return ListCell{TypeName: LIST_TYPE_NAME, Value: []ListCell{}}
+	}
 	firstVal := &list.ListVals[0]
-	//	initCellList := CellList{}
-	initEnvironment := Environment{}
 	switch firstVal.Type {
 	case Parser.LiteralToken:
 		errMsg := fmt.Sprintf("Error: attempting to evaluate a literal, %v, at line %v.\n", firstVal.Value, firstVal.LineNum)
 		panic(errMsg)
 	case Parser.DefToken:
 		defKind := &firstVal.Value
-		if len(list.ListVals) < 3 {
-			errMsg := fmt.Sprintf("Error: too few arguments to %v at line %v.\n", defKind, firstVal.LineNum)
+		global := *defKind == "def" || *defKind == "defm"
+		mut := *defKind == "letm" || *defKind == "defm"
+		if len(list.ListVals) < 2 || (!global && len(list.ListVals) < 3) {
+			errMsg := fmt.Sprintf("Error: too few arguments to %v at line %v.\n", *defKind, firstVal.LineNum)
 			panic(errMsg)
 		} else if list.ListVals[1].Type != Parser.ListToken {
-			errMsg := fmt.Sprintf("Error: first argument (%v) to %v at line %v is not a list.\n", list.ListVals[1].Value, defKind, firstVal.LineNum)
+			errMsg := fmt.Sprintf("Error: first argument (%v) to %v at line %v is not a list.\n", list.ListVals[1].Value, *defKind, firstVal.LineNum)
 			panic(errMsg)
-		} else if list.ListVals[2].Type != Parser.ListToken {
-			errMsg := fmt.Sprintf("Error: second argument (%v) to %v  at line %v is not a list.\n", list.ListVals[2].Value, defKind, firstVal.LineNum)
+		}
+		bindEnv := env
+		if !global {
+			bindEnv = newChildEnvironment(env)
+		}
+		lastBound := bindVars(&list.ListVals[1], bindEnv, firstVal.LineNum, global, mut, defKind)
+		if len(list.ListVals) == 2 {
+			return lastBound
+		}
+		return evalBody(list.ListVals[2:], bindEnv)
+	case Parser.FormToken:
+		return evalFormToken(list, env)
+	case Parser.TypeAnnToken:
+		errMsg := fmt.Sprintf("Error: misplaced type annotation marker at line %v.\n", firstVal.LineNum)
+		panic(errMsg)
+	default:
+		return evalApplication(list, env)
+	}
+}
+
+func evalFormToken(list *Parser.Token, env *Environment) ListCell {
+	firstVal := &list.ListVals[0]
+	switch firstVal.Value {
+	case "if":
+		if len(list.ListVals) < 3 || len(list.ListVals) > 4 {
+			errMsg := fmt.Sprintf("Error: if at line %v expects a condition, a consequent and an optional alternative.\n", firstVal.LineNum)
+			panic(errMsg)
+		}
+		condVal := evalToken(&list.ListVals[1], env)
+		cond, ok := condVal.Value.(bool)
+		if !ok {
+			errMsg := fmt.Sprintf("Error: expected bool as condition to if at line %v but got %v.\n", firstVal.LineNum, condVal.TypeName)
 			panic(errMsg)
-		} else if len(list.ListVals) > 3 {
-			errMsg := fmt.Sprintf("Error: too many arguments to %v at line %v.\n", defKind, firstVal.LineNum)
+		}
+		if cond {
+			return evalToken(&list.ListVals[2], env)
+		} else if len(list.ListVals) == 4 {
+			return evalToken(&list.ListVals[3], env)
+		}
+		return makeNilCell()
+	case "fn":
+		if len(list.ListVals) < 3 {
+			errMsg := fmt.Sprintf("Error: too few arguments to fn at line %v.\n", firstVal.LineNum)
 			panic(errMsg)
 		}
-		tmpCallerName := "let"
-		initEnvironment = bindVars(&list.ListVals[1], initEnvironment, firstVal.LineNum, true, true, &tmpCallerName)
+		return makeFuncCell("", &list.ListVals[1], list.ListVals[2:], env, firstVal.LineNum, list.Pure)
+	case "do":
+		return evalBody(list.ListVals[1:], env)
+	case "defn", "defn-memo":
+		if len(list.ListVals) < 4 {
+			errMsg := fmt.Sprintf("Error: too few arguments to %v at line %v.\n", firstVal.Value, firstVal.LineNum)
+			panic(errMsg)
+		}
+		newFunc := makeFuncCell(list.ListVals[1].Value, &list.ListVals[2], list.ListVals[3:], env, firstVal.LineNum, list.Pure)
+		if firstVal.Value == "defn-memo" {
+			newFunc.Value = memoise(newFunc.Value.(FunctionObj), firstVal.LineNum)
+		}
+		bindGlobal(&list.ListVals[1], newFunc, env, firstVal.LineNum, &firstVal.Value)
+		return newFunc
+	case "select":
+		return evalSelect(list, env)
+	case "dosync":
+		return evalDosync(list, env)
+	case "quote":
+		return evalQuote(list, env)
 	default:
-		errMsg := fmt.Sprintf("Error: unhandled token type for %v at line %v.\n", firstVal.Value, firstVal.LineNum)
+		errMsg := fmt.Sprintf("Error: unhandled special form %v at line %v.\n", firstVal.Value, firstVal.LineNum)
 		panic(errMsg)
 	}
-	return ListCell{}
 }
 
-func Initialise(input string)ListCell{
-	res := Parser.Lex(&input)
-	tokens := Parser.ParseList(res, 0)
-	for _, tok := range tokens.ListVals {
-		fmt.Println(tok)
+func parseParams(params *Parser.Token, lineNum int) []string {
+	if params.Type != Parser.ListToken {
+		errMsg := fmt.Sprintf("Error: expected parameter list for function at line %v but got %v.\n", lineNum, params.Value)
+		panic(errMsg)
+	}
+	names := make([]string, 0, len(params.ListVals))
+	for _, param := range params.ListVals {
+		if param.Type != Parser.IdToken {
+			errMsg := fmt.Sprintf("Error: function parameter %v at line %v is not an identifier.\n", param.Value, lineNum)
+			panic(errMsg)
+		}
+		names = append(names, param.Value)
 	}
-	return evalListToken(&tokens.ListVals[0])
+	return names
+}
+
+func makeFuncCell(name string, params *Parser.Token, body []Parser.Token, env *Environment, lineNum int, pure bool) ListCell {
+	newFunc := FunctionObj{Name: name, Parems: parseParams(params, lineNum), BodyTokens: body, Env: env, Pure: pure}
+	return ListCell{TypeName: FUNCTION_TYPE_NAME, Value: newFunc}
+}
+
+func evalApplication(list *Parser.Token, env *Environment) ListCell {
+	firstVal := &list.ListVals[0]
+	head := evalToken(firstVal, env)
+	funct, ok := head.Value.(FunctionObj)
+	if !ok {
+		errMsg := fmt.Sprintf("Error: attempting to call %v at line %v, but it is a %v rather than a function.\n", firstVal.Value, firstVal.LineNum, head.TypeName)
+		panic(errMsg)
+	}
+	params := make([]ListCell, len(list.ListVals)-1)
+	for i := range params {
+		params[i] = evalToken(&list.ListVals[i+1], env)
+	}
+	returnedVals, err := funct.Call(params, env)
+	if err != nil {
+		panic(err)
+	}
+	return firstReturnVal(returnedVals)
+}
+
+// EvalOptions selects how parsed code is evaluated.
+type EvalOptions struct {
+	// Bytecode compiles each top-level form and runs it on the stack VM
+	// instead of walking the token tree.
+	Bytecode bool
+	// Optimise folds pure builtin calls on literals and drops unused pure
+	// let bindings before evaluation.
+	Optimise bool
+	// Limits caps the work each evaluation may do.
+	Limits Limits
+	// PoolSize is the number of worker goroutines pmap, par and future
+	// may use at once in each evaluation. Zero means GOMAXPROCS.
+	PoolSize int
+}
+
+func Parse(input string) (tokens Parser.Token, err error) {
+	defer recoverEvalError(&err)
+	lexemes := Parser.Lex(&input)
+	return Parser.ParseList(lexemes, 1), nil
+}
+
+func EvalString(ctx context.Context, input string, env *Environment, opts EvalOptions) (ListCell, error) {
+	tokens, err := Parse(input)
+	if err != nil {
+		return ListCell{}, err
+	}
+	return EvalTokens(ctx, &tokens, env, opts)
+}
+
+// EvalReader reads the forms of in one at a time, evaluating each before
+// reading the next, and returns the value of the last one. Unlike
+// EvalString, each form is resolved only when it is reached, so, as at the
+// REPL, a form can refer only to globals defined before it; in return the
+// input is never held whole, and a form runs as soon as it has been read.
+// The forms are one evaluation, so opts.Limits bound them all together, as
+// they would in EvalString.
+func EvalReader(ctx context.Context, in io.Reader, env *Environment, opts EvalOptions) (ListCell, error) {
+	if err := ctx.Err(); err != nil {
+		return ListCell{}, &CancelledError{Cause: err}
+	}
+	state := newEvalState(ctx, opts)
+	result, err := evalReader(Parser.NewReader(in), env.withState(state), opts)
+	if err := state.finish(err); err != nil {
+		return ListCell{}, err
+	}
+	return result, nil
+}
+
+func evalReader(reader *Parser.Reader, env *Environment, opts EvalOptions) (ListCell, error) {
+	result := makeNilCell()
+	for {
+		tok, err := reader.Read()
+		if err == io.EOF {
+			return result, nil
+		} else if err != nil {
+			return ListCell{}, err
+		}
+		if err := env.state.ctx.Err(); err != nil {
+			return ListCell{}, &CancelledError{Cause: err}
+		}
+		result, err = evalForm(&tok, env, opts)
+		if err != nil {
+			return ListCell{}, err
+		}
+	}
+}
+
+// EvalTokens evaluates each form of a parsed top-level list in turn and
+// returns the value of the last one. The whole list is resolved before
+// anything runs, so unbound vars are reported without side effects. If ctx
+// is cancelled, evaluation stops with a *CancelledError. Goroutines started
+// with go are waited for, and the first error from any of them is
+// returned.
+func EvalTokens(ctx context.Context, tokens *Parser.Token, env *Environment, opts EvalOptions) (ListCell, error) {
+	if err := ctx.Err(); err != nil {
+		return ListCell{}, &CancelledError{Cause: err}
+	}
+	state := newEvalState(ctx, opts)
+	result, err := evalTokens(tokens, env.withState(state), opts)
+	if err := state.finish(err); err != nil {
+		return ListCell{}, err
+	}
+	return result, nil
+}
+
+func evalTokens(tokens *Parser.Token, env *Environment, opts EvalOptions) (ListCell, error) {
+	if errs := Resolve(tokens, env); len(errs) > 0 {
+		return ListCell{}, errs[0]
+	}
+	if opts.Optimise {
+		Optimise(tokens, env)
+		//Dropped bindings move the addresses of those after them.
+		Resolve(tokens, env)
+	}
+	result := makeNilCell()
+	for i := range tokens.ListVals {
+		var err error
+		result, err = evalResolvedToken(&tokens.ListVals[i], env, opts)
+		if err != nil {
+			return ListCell{}, err
+		}
+	}
+	return result, nil
+}
+
+func EvalToken(ctx context.Context, tok *Parser.Token, env *Environment, opts EvalOptions) (ListCell, error) {
+	if err := ctx.Err(); err != nil {
+		return ListCell{}, &CancelledError{Cause: err}
+	}
+	state := newEvalState(ctx, opts)
+	result, err := evalForm(tok, env.withState(state), opts)
+	if err := state.finish(err); err != nil {
+		return ListCell{}, err
+	}
+	return result, nil
+}
+
+// evalForm resolves, optimises and evaluates one top-level form in env,
+// which carries the state of the evaluation it is part of.
+func evalForm(tok *Parser.Token, env *Environment, opts EvalOptions) (ListCell, error) {
+	if errs := resolveForm(tok, env); len(errs) > 0 {
+		return ListCell{}, errs[0]
+	}
+	if opts.Optimise {
+		optimiseToken(tok, env)
+		resolveForm(tok, env)
+	}
+	return evalResolvedToken(tok, env, opts)
+}
+
+func evalResolvedToken(tok *Parser.Token, env *Environment, opts EvalOptions) (result ListCell, err error) {
+	//Purity is marked per form, once the globals defined by earlier forms are bound.
+	markPurity(tok, env, "")
+	if opts.Bytecode {
+		proto, err := compileResolved(tok, env)
+		if err != nil {
+			return ListCell{}, err
+		}
+		return runProto(proto, vmScopeFromEnv(env), env, env.state)
+	}
+	defer recoverEvalError(&err)
+	return evalToken(tok, env), nil
+}
+
+// Initialise evaluates the first form of input in a fresh environment.
+//
+// Deprecated: it ignores any later forms and panics on errors; use an
+// Interpreter instead.
+func Initialise(input string) ListCell {
+	res := Parser.Lex(&input)
+	tokens := Parser.ParseList(res, 1)
+	return evalToken(&tokens.ListVals[0], NewEnvironment(CreateSystemFuncs()))
 }
@@ -0,0 +1,107 @@
+package Golly
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestSharedEnvironment evaluates, calls and defines globals on one
+// Interpreter from many goroutines at once. Run it with go test -race.
+func TestSharedEnvironment(t *testing.T) {
+	const workers, rounds = 8, 50
+	for _, opts := range [][]Option{nil, {WithBytecode()}} {
+		interp := New(opts...)
+		setup := "(defn square (n) (* n n))\n(defm (latest 0))"
+		if _, err := interp.EvalString(context.Background(), setup); err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		errs := make(chan error, workers*rounds*4)
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < rounds; i++ {
+					name := fmt.Sprintf("g-%v-%v", w, i)
+					src := fmt.Sprintf("(def (%v (square %v)))\n(defm (latest %v))\n%v", name, i, i, name)
+					result, err := interp.EvalString(context.Background(), src)
+					if err != nil {
+						errs <- err
+					} else if result.Value != i*i {
+						errs <- fmt.Errorf("%v evaluated to %v, want %v", name, result.Value, i*i)
+					}
+					result, err = interp.Call(context.Background(), "square", IntValue(w))
+					if err != nil {
+						errs <- err
+					} else if result.Value != w*w {
+						errs <- fmt.Errorf("square of %v returned %v", w, result.Value)
+					}
+					if err := interp.Define(name+"-host", IntValue(i)); err != nil {
+						errs <- err
+					}
+					if _, ok := interp.Get("latest"); !ok {
+						errs <- fmt.Errorf("latest is unbound")
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Error(err)
+		}
+		for w := 0; w < workers; w++ {
+			for i := 0; i < rounds; i++ {
+				name := fmt.Sprintf("g-%v-%v", w, i)
+				if value, ok := interp.Get(name); !ok || value.Value != i*i {
+					t.Errorf("%v is %v, %v after the goroutines finished, want %v", name, value.Value, ok, i*i)
+				}
+				if _, ok := interp.Get(name + "-host"); !ok {
+					t.Errorf("%v-host is unbound after the goroutines finished", name)
+				}
+			}
+		}
+	}
+}
+
+// TestSharedEnvironmentRedefinition rebinds one defm global from many
+// goroutines while others read it, and checks that every read sees a value
+// some goroutine wrote.
+func TestSharedEnvironmentRedefinition(t *testing.T) {
+	env := NewEnvironment(CreateSystemFuncs())
+	if _, err := EvalString(context.Background(), "(defm (shared 0))", env, EvalOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 400)
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				src := fmt.Sprintf("(defm (shared %v))", w*100+i)
+				if _, err := EvalString(context.Background(), src, env, EvalOptions{}); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				result, err := EvalString(context.Background(), "(+ shared 0)", env, EvalOptions{Bytecode: true})
+				if err != nil {
+					errs <- err
+				} else if n, ok := result.Value.(int); !ok || n < 0 || n >= 400 {
+					errs <- fmt.Errorf("shared read as %v", result.Value)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
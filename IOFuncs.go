@@ -0,0 +1,135 @@
+package Golly
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+func stringArg(name string, cell *ListCell) (string, error) {
+	str, ok := cell.Value.(string)
+	if !ok || cell.TypeName != STRING_TYPE_NAME {
+		err := fmt.Sprintf("Error: %v expects a string but got %v.\n", name, cell.TypeName)
+		return "", errors.New(err)
+	}
+	return str, nil
+}
+
+func makeStringCell(str string, env *Environment) ListCell {
+	env.evalState().allocString(len(str))
+	return ListCell{TypeName: STRING_TYPE_NAME, Value: str}
+}
+
+// GoPrint writes its arguments to the SysEnvironment's Stdout separated by
+// spaces, followed by a newline for println.
+func GoPrint(funcType goFuncType, parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	items := make([]string, len(parameters))
+	for i, param := range parameters {
+		items[i] = displayCell(param)
+	}
+	text := strings.Join(items, " ")
+	if funcType == GoPrintlnT {
+		text += "\n"
+	}
+	if err := env.System.write(text); err != nil {
+		return nil, err
+	}
+	returnVal := makeNilCell()
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoReadLine reads a line from the SysEnvironment's Stdin without its line
+// ending, or returns nil at the end of the input.
+func GoReadLine(env *Environment) ([]*ListCell, error) {
+	line, ok, err := env.System.readLine()
+	if err != nil {
+		return nil, err
+	}
+	returnVal := makeNilCell()
+	if ok {
+		returnVal = makeStringCell(strings.TrimRight(line, "\r\n"), env)
+	}
+	return []*ListCell{&returnVal}, nil
+}
+
+func GoReadFile(Cell1 *ListCell, env *Environment) ([]*ListCell, error) {
+	path, err := stringArg("read-file", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		errMsg := fmt.Sprintf("Error: read-file could not read %v: %v.\n", path, err)
+		return nil, errors.New(errMsg)
+	}
+	returnVal := makeStringCell(string(contents), env)
+	return []*ListCell{&returnVal}, nil
+}
+
+func GoWriteFile(Cell1 *ListCell, Cell2 *ListCell) ([]*ListCell, error) {
+	path, err := stringArg("write-file", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := stringArg("write-file", Cell2)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, []byte(contents), 0666); err != nil {
+		errMsg := fmt.Sprintf("Error: write-file could not write %v: %v.\n", path, err)
+		return nil, errors.New(errMsg)
+	}
+	returnVal := makeNilCell()
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoGetenv returns the value of an environment variable, or nil if it is
+// not set.
+func GoGetenv(Cell1 *ListCell) ([]*ListCell, error) {
+	name, err := stringArg("getenv", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := makeNilCell()
+	if val, ok := os.LookupEnv(name); ok {
+		returnVal = ListCell{TypeName: STRING_TYPE_NAME, Value: val}
+	}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoHttpGet fetches a URL and returns the body as a string. The request is
+// abandoned if the evaluation's context is cancelled.
+func GoHttpGet(Cell1 *ListCell, env *Environment) ([]*ListCell, error) {
+	url, err := stringArg("http-get", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		errMsg := fmt.Sprintf("Error: http-get could not request %v: %v.\n", url, err)
+		return nil, errors.New(errMsg)
+	}
+	if state := env.evalState(); state != nil {
+		req = req.WithContext(state.ctx)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		errMsg := fmt.Sprintf("Error: http-get of %v failed: %v.\n", url, err)
+		return nil, errors.New(errMsg)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Sprintf("Error: http-get of %v failed with status %v.\n", url, resp.Status)
+		return nil, errors.New(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		errMsg := fmt.Sprintf("Error: http-get of %v failed: %v.\n", url, err)
+		return nil, errors.New(errMsg)
+	}
+	returnVal := makeStringCell(string(body), env)
+	return []*ListCell{&returnVal}, nil
+}
@@ -0,0 +1,221 @@
+package Golly
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Value is a Golly value as seen by Go code embedding an Interpreter.
+type Value = ListCell
+
+// Interpreter evaluates Golly code in one persistent global environment.
+// None of its methods write to stdout or let a panic escape; every failure
+// is returned as an error.
+//
+// Its methods may be called from several goroutines at once. Each call
+// evaluates with its own local scopes and shares the globals, which are
+// locked as described on Environment. Rebinding a defm global is atomic,
+// but reading it and then rebinding it is not.
+type Interpreter struct {
+	env  *Environment
+	opts EvalOptions
+}
+
+// Option configures an Interpreter created by New.
+type Option func(*Interpreter)
+
+// WithBytecode makes the Interpreter compile each form and run it on the
+// stack VM instead of walking the token tree.
+func WithBytecode() Option {
+	return func(interp *Interpreter) {
+		interp.opts.Bytecode = true
+	}
+}
+
+// WithOptimise turns on constant folding and dead binding elimination.
+func WithOptimise() Option {
+	return func(interp *Interpreter) {
+		interp.opts.Optimise = true
+	}
+}
+
+// WithSystem makes the Interpreter use sys for its builtins instead of a
+// SysEnvironment granting every capability. Use NewSysEnvironment to
+// build one for untrusted scripts.
+func WithSystem(sys *SysEnvironment) Option {
+	return func(interp *Interpreter) {
+		interp.env = NewEnvironment(sys)
+	}
+}
+
+// WithLimits caps the work each call of EvalString, EvalFile, EvalReader
+// or Call may do; going over a limit returns a *LimitExceeded. The forms
+// EvalReader reads share one set of limits, however many there are. It
+// replaces the default limits, which only bound call depth.
+func WithLimits(limits Limits) Option {
+	return func(interp *Interpreter) {
+		interp.opts.Limits = limits
+	}
+}
+
+// WithPoolSize sets how many worker goroutines pmap, par and future may use
+// at once in each call of EvalString, EvalFile, EvalReader or Call. By
+// default it is GOMAXPROCS.
+func WithPoolSize(size int) Option {
+	return func(interp *Interpreter) {
+		interp.opts.PoolSize = size
+	}
+}
+
+// DefaultMaxDepth is the call depth an Interpreter allows unless WithLimits
+// says otherwise. It keeps runaway recursion in the tree-walker from
+// overflowing the Go stack.
+const DefaultMaxDepth = 100000
+
+// New creates an Interpreter, by default with every builtin.
+func New(opts ...Option) *Interpreter {
+	interp := Interpreter{}
+	interp.opts.Limits.MaxDepth = DefaultMaxDepth
+	for _, opt := range opts {
+		opt(&interp)
+	}
+	if interp.env == nil {
+		interp.env = NewEnvironment(CreateSystemFuncs())
+	}
+	return &interp
+}
+
+// EvalString evaluates every form in src and returns the value of the last
+// one. A leading #! line is skipped, so scripts can be run directly. If ctx
+// is cancelled first, the error is a *CancelledError.
+func (interp *Interpreter) EvalString(ctx context.Context, src string) (result Value, err error) {
+	defer recoverEvalError(&err)
+	return EvalString(ctx, stripShebang(src), interp.env, interp.opts)
+}
+
+// EvalFile reads the file at path and evaluates it as EvalString does.
+func (interp *Interpreter) EvalFile(ctx context.Context, path string) (Value, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Value{}, err
+	}
+	return interp.EvalString(ctx, string(src))
+}
+
+// EvalReader evaluates the forms read from in as the package-level
+// EvalReader does, one at a time, skipping a leading #! line.
+func (interp *Interpreter) EvalReader(ctx context.Context, in io.Reader) (result Value, err error) {
+	defer recoverEvalError(&err)
+	buffered := bufio.NewReader(in)
+	if err := skipShebang(buffered); err != nil {
+		return Value{}, err
+	}
+	return EvalReader(ctx, buffered, interp.env, interp.opts)
+}
+
+// Define binds name to value as an immutable global, as def would.
+func (interp *Interpreter) Define(name string, value Value) error {
+	value.Mutable = false
+	return interp.env.DefineGlobal(name, value)
+}
+
+// Get returns the value of a global or builtin.
+func (interp *Interpreter) Get(name string) (Value, bool) {
+	if binding := interp.env.findBinding(name, false, true); binding != nil {
+		return binding.Binding, true
+	}
+	return Value{}, false
+}
+
+// Call calls the function bound to name with args.
+func (interp *Interpreter) Call(ctx context.Context, name string, args ...Value) (result Value, err error) {
+	defer recoverEvalError(&err)
+	if err := ctx.Err(); err != nil {
+		return Value{}, &CancelledError{Cause: err}
+	}
+	value, ok := interp.Get(name)
+	if capability, denied := interp.env.System.Denied[name]; !ok && denied {
+		return Value{}, &PermissionError{Name: name, Capability: capability}
+	}
+	if !ok {
+		err := fmt.Sprintf("Error: attempting to call %v, but that var is unbound.\n", name)
+		return Value{}, errors.New(err)
+	}
+	funct, ok := value.Value.(FunctionObj)
+	if !ok {
+		err := fmt.Sprintf("Error: attempting to call %v, but it is a %v rather than a function.\n", name, value.TypeName)
+		return Value{}, errors.New(err)
+	}
+	state := newEvalState(ctx, interp.opts)
+	returnedVals, err := funct.Call(args, interp.env.withState(state))
+	if err := state.finish(err); err != nil {
+		return Value{}, err
+	}
+	return firstReturnVal(returnedVals), nil
+}
+
+// skipShebang reads past a leading #! line of in, leaving its newline so
+// that errors still report the right line numbers.
+func skipShebang(in *bufio.Reader) error {
+	if start, _ := in.Peek(2); string(start) != "#!" {
+		return nil
+	}
+	for {
+		next, err := in.Peek(1)
+		if err == io.EOF || err == nil && next[0] == '\n' {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		in.ReadByte()
+	}
+}
+
+// stripShebang returns src without a leading #! line, as skipShebang reads
+// it.
+func stripShebang(src string) string {
+	if !strings.HasPrefix(src, "#!") {
+		return src
+	}
+	in := bufio.NewReader(strings.NewReader(src))
+	skipShebang(in)
+	rest, _ := ioutil.ReadAll(in)
+	return string(rest)
+}
+
+// IntValue, FloatValue, BoolValue, StringValue, ListValue and NilValue
+// build Values to pass to Define and Call.
+func IntValue(val int) Value {
+	return Value{TypeName: INT_TYPE_NAME, Value: val}
+}
+
+func FloatValue(val float64) Value {
+	return Value{TypeName: FLOAT_TYPE_NAME, Value: val}
+}
+
+func BoolValue(val bool) Value {
+	return makeBoolCell(val)
+}
+
+func StringValue(val string) Value {
+	return Value{TypeName: STRING_TYPE_NAME, Value: val}
+}
+
+func ListValue(vals ...Value) Value {
+	return Value{TypeName: LIST_TYPE_NAME, Value: append([]ListCell{}, vals...)}
+}
+
+func NilValue() Value {
+	return makeNilCell()
+}
+
+// FuncValue wraps a Go function as a Golly function value. Host functions
+// are never treated as pure.
+func FuncValue(name string, fn func(args ...Value) (Value, error)) Value {
+	return Value{TypeName: FUNCTION_TYPE_NAME, Value: FunctionObj{Name: name, host: fn}}
+}
@@ -0,0 +1,134 @@
+package Golly
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestInterpreterKeepsGlobals(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithBytecode()}, {WithOptimise()}} {
+		interp := New(opts...)
+		if _, err := interp.EvalString(context.Background(), "(def (x 2))\n(defn add (a b) (+ a b))"); err != nil {
+			t.Fatal(err)
+		}
+		result, err := interp.EvalString(context.Background(), "(add x 40)")
+		if err != nil || result.Value != 42 {
+			t.Errorf("a later EvalString returned %v, %v, want 42", result.Value, err)
+		}
+		result, err = interp.Call(context.Background(), "add", IntValue(1), IntValue(2))
+		if err != nil || result.Value != 3 {
+			t.Errorf("Call of add returned %v, %v, want 3", result.Value, err)
+		}
+	}
+}
+
+func TestInterpreterDefineAndGet(t *testing.T) {
+	interp := New()
+	if err := interp.Define("n", IntValue(5)); err != nil {
+		t.Fatal(err)
+	}
+	result, err := interp.EvalString(context.Background(), "(+ n 1)")
+	if err != nil || result.Value != 6 {
+		t.Errorf("a script using a Defined global returned %v, %v, want 6", result.Value, err)
+	}
+	if value, ok := interp.Get("n"); !ok || value.Value != 5 || value.Mutable {
+		t.Errorf("Get(n) = %+v, %v, want an immutable 5", value, ok)
+	}
+	if _, ok := interp.Get("+"); !ok {
+		t.Error("Get(+) did not find the builtin")
+	}
+	if _, ok := interp.Get("missing"); ok {
+		t.Error("Get found an unbound name")
+	}
+	if err := interp.Define("n", IntValue(6)); err == nil {
+		t.Error("Define rebound an immutable global")
+	}
+	if err := interp.Define("+", IntValue(6)); err == nil {
+		t.Error("Define rebound a builtin")
+	}
+}
+
+func TestInterpreterHostFunctions(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithBytecode()}} {
+		interp := New(opts...)
+		interp.Define("twice", FuncValue("twice", func(args ...Value) (Value, error) {
+			return IntValue(2 * args[0].Value.(int)), nil
+		}))
+		interp.Define("fail", FuncValue("fail", func(args ...Value) (Value, error) {
+			return Value{}, errors.New("host failure")
+		}))
+		interp.Define("explode", FuncValue("explode", func(args ...Value) (Value, error) {
+			panic("host panic")
+		}))
+		result, err := interp.EvalString(context.Background(), "(twice (twice 3))")
+		if err != nil || result.Value != 12 {
+			t.Errorf("calling a host function returned %v, %v, want 12", result.Value, err)
+		}
+		if _, err := interp.EvalString(context.Background(), "(fail)"); err == nil || !strings.Contains(err.Error(), "host failure") {
+			t.Errorf("a failing host function returned %v", err)
+		}
+		if _, err := interp.EvalString(context.Background(), "(explode)"); err == nil || !strings.Contains(err.Error(), "host panic") {
+			t.Errorf("a panicking host function returned %v", err)
+		}
+	}
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	interp := New()
+	interp.Define("n", IntValue(5))
+	if _, err := interp.EvalString(context.Background(), "(+ 1"); err == nil {
+		t.Error("EvalString of an unbalanced form returned no error")
+	}
+	if _, err := interp.Call(context.Background(), "missing"); err == nil || !strings.Contains(err.Error(), "unbound") {
+		t.Errorf("Call of an unbound name returned %v", err)
+	}
+	if _, err := interp.Call(context.Background(), "n"); err == nil || !strings.Contains(err.Error(), "rather than a function") {
+		t.Errorf("Call of an int returned %v", err)
+	}
+	if _, err := interp.EvalFile(context.Background(), "no/such/file.golly"); err == nil {
+		t.Error("EvalFile of a missing file returned no error")
+	}
+}
+
+func TestEvalReaderSharesLimits(t *testing.T) {
+	src := "(defn count-down (n) (if (= n 0) 0 (count-down (- n 1))))\n" + strings.Repeat("(count-down 200)\n", 20)
+	for _, opts := range [][]Option{nil, {WithBytecode()}} {
+		interp := New(append(opts, WithLimits(Limits{MaxSteps: 5000}))...)
+		_, err := interp.EvalReader(context.Background(), strings.NewReader(src))
+		var limitErr *LimitExceeded
+		if !errors.As(err, &limitErr) || limitErr.Limit != StepLimit {
+			t.Errorf("EvalReader of forms together over the step limit returned %v, want a step LimitExceeded", err)
+		}
+	}
+}
+
+func TestEvalReaderSkipsShebang(t *testing.T) {
+	interp := New()
+	result, err := interp.EvalReader(context.Background(), strings.NewReader("#!/usr/bin/env golly run\n(+ 1 2)\n(+ 3 4)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Value != 7 {
+		t.Errorf("EvalReader returned %v, want 7", result.Value)
+	}
+	_, err = interp.EvalReader(context.Background(), strings.NewReader("#!golly\n\n(undefined-thing)"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("EvalReader after a #! line returned %v, want an error at line 3", err)
+	}
+}
+
+func TestStripShebang(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"#!/usr/bin/env golly\n(+ 1 2)", "\n(+ 1 2)"},
+		{"#!golly", ""},
+		{"(+ 1 2)", "(+ 1 2)"},
+		{"#(+ 1 2)", "#(+ 1 2)"},
+	}
+	for _, test := range tests {
+		if got := stripShebang(test.src); got != test.want {
+			t.Errorf("stripShebang(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package Golly
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// listArg returns the elements of a List. nil counts as the empty list.
+func listArg(name string, cell *ListCell) ([]ListCell, error) {
+	if cell.TypeName == NIL_TYPE_NAME {
+		return nil, nil
+	}
+	vals, ok := cell.Value.([]ListCell)
+	if !ok || cell.TypeName != LIST_TYPE_NAME {
+		err := fmt.Sprintf("Error: %v expects a List but got %v.\n", name, cell.TypeName)
+		return nil, errors.New(err)
+	}
+	return vals, nil
+}
+
+// seqArg returns the elements of a List or Vector, and whether it was a
+// Vector. nil counts as the empty list.
+func seqArg(name string, cell *ListCell) ([]ListCell, bool, error) {
+	if vec, ok := cell.Value.(*Vector); ok {
+		return vec.Slice(), true, nil
+	} else if cell.TypeName == NIL_TYPE_NAME {
+		return nil, false, nil
+	}
+	vals, ok := cell.Value.([]ListCell)
+	if !ok || cell.TypeName != LIST_TYPE_NAME {
+		err := fmt.Sprintf("Error: %v expects a List or Vector but got %v.\n", name, cell.TypeName)
+		return nil, false, errors.New(err)
+	}
+	return vals, false, nil
+}
+
+func funcArg(name string, cell *ListCell) (FunctionObj, error) {
+	funct, ok := cell.Value.(FunctionObj)
+	if !ok {
+		err := fmt.Sprintf("Error: %v expects a function but got %v.\n", name, cell.TypeName)
+		return FunctionObj{}, errors.New(err)
+	}
+	return funct, nil
+}
+
+// callFunction calls a function passed to a builtin and returns its result.
+func callFunction(funct FunctionObj, env *Environment, args ...ListCell) (ListCell, error) {
+	returnedVals, err := funct.Call(args, env)
+	if err != nil {
+		return ListCell{}, err
+	}
+	return firstReturnVal(returnedVals), nil
+}
+
+// newList makes a List cell of vals, counting it against the evaluation's
+// limits.
+func newList(vals []ListCell, env *Environment) ListCell {
+	env.evalState().allocList(len(vals))
+	for i := range vals {
+		vals[i].Mutable = false
+	}
+	return ListCell{TypeName: LIST_TYPE_NAME, Value: vals}
+}
+
+// newSeq makes a List cell of vals, or a Vector cell if vector is set.
+func newSeq(vals []ListCell, vector bool, env *Environment) ListCell {
+	if !vector {
+		return newList(vals, env)
+	}
+	for i := range vals {
+		vals[i].Mutable = false
+	}
+	return makeVectorCell(vals, env)
+}
+
+func GoList(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	returnVal := newList(append([]ListCell{}, parameters...), env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoCons returns a list of a value followed by the elements of a list.
+func GoCons(Cell1 *ListCell, Cell2 *ListCell, env *Environment) ([]*ListCell, error) {
+	tail, err := listArg("cons", Cell2)
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]ListCell, 0, len(tail)+1)
+	vals = append(append(vals, *Cell1), tail...)
+	returnVal := newList(vals, env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoFirst returns the first element of a list or vector, or nil if it is
+// empty.
+func GoFirst(funcType goFuncType, Cell1 *ListCell) ([]*ListCell, error) {
+	if vec, ok := Cell1.Value.(*Vector); ok {
+		returnVal := makeNilCell()
+		if vec.Len() > 0 {
+			returnVal = vec.Nth(0)
+		}
+		return []*ListCell{&returnVal}, nil
+	}
+	vals, _, err := seqArg(goFuncNames[funcType], Cell1)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := makeNilCell()
+	if len(vals) > 0 {
+		returnVal = vals[0]
+	}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoRest returns all but the first element of a list or vector. The result
+// shares the list's elements, so it costs nothing to make, or the vector's
+// nodes, all but those down its left edge.
+func GoRest(funcType goFuncType, Cell1 *ListCell) ([]*ListCell, error) {
+	if vec, ok := Cell1.Value.(*Vector); ok {
+		returnVal := ListCell{TypeName: VECTOR_TYPE_NAME, Value: vec.Subvec(min(1, vec.Len()), vec.Len())}
+		return []*ListCell{&returnVal}, nil
+	}
+	vals, _, err := seqArg(goFuncNames[funcType], Cell1)
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) > 0 {
+		vals = vals[1:]
+	}
+	returnVal := ListCell{TypeName: LIST_TYPE_NAME, Value: vals[:len(vals):len(vals)]}
+	return []*ListCell{&returnVal}, nil
+}
+
+func GoNth(Cell1 *ListCell, Cell2 *ListCell) ([]*ListCell, error) {
+	vals, err := listArg("nth", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	index, err := indexArg("nth", Cell2)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(vals) {
+		err := fmt.Sprintf("Error: nth index %v is out of range for a List of %v elements.\n", index, len(vals))
+		return nil, errors.New(err)
+	}
+	returnVal := vals[index]
+	return []*ListCell{&returnVal}, nil
+}
+
+func GoLength(Cell1 *ListCell) ([]*ListCell, error) {
+	vals, err := listArg("length", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := ListCell{TypeName: INT_TYPE_NAME, Value: len(vals)}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoAppend returns the elements of all its list arguments in one list.
+func GoAppend(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	lists := make([][]ListCell, len(parameters))
+	total := 0
+	for i := range parameters {
+		vals, err := listArg("append", &parameters[i])
+		if err != nil {
+			return nil, err
+		}
+		lists[i] = vals
+		total += len(vals)
+	}
+	vals := make([]ListCell, 0, total)
+	for _, list := range lists {
+		vals = append(vals, list...)
+	}
+	returnVal := newList(vals, env)
+	return []*ListCell{&returnVal}, nil
+}
+
+func GoReverse(Cell1 *ListCell, env *Environment) ([]*ListCell, error) {
+	vals, err := listArg("reverse", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	reversed := make([]ListCell, len(vals))
+	state := env.evalState()
+	for i, val := range vals {
+		state.poll(i)
+		reversed[len(vals)-1-i] = val
+	}
+	returnVal := newList(reversed, env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoMap calls a function on the elements of one or more lists or vectors,
+// taking an element from each per call, and returns the results in a
+// vector if the first was one, or a list. It stops at the end of the
+// shortest.
+func GoMap(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if len(parameters) < 2 {
+		err := fmt.Sprintf("Error: builtin map expects a function and at least one List or Vector but was called with %v arguments.\n", len(parameters))
+		return nil, errors.New(err)
+	}
+	funct, err := funcArg("map", &parameters[0])
+	if err != nil {
+		return nil, err
+	}
+	lists := make([][]ListCell, len(parameters)-1)
+	length := -1
+	vector := false
+	for i := range lists {
+		var isVector bool
+		lists[i], isVector, err = seqArg("map", &parameters[i+1])
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			vector = isVector
+		}
+		if length < 0 || len(lists[i]) < length {
+			length = len(lists[i])
+		}
+	}
+	results := make([]ListCell, length)
+	for i := range results {
+		args := make([]ListCell, len(lists))
+		for j := range lists {
+			args[j] = lists[j][i]
+		}
+		results[i], err = callFunction(funct, env, args...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	returnVal := newSeq(results, vector, env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoFilter returns the elements of a list or vector for which a predicate
+// returns true, in the same kind of collection.
+func GoFilter(Cell1 *ListCell, Cell2 *ListCell, env *Environment) ([]*ListCell, error) {
+	funct, err := funcArg("filter", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	vals, vector, err := seqArg("filter", Cell2)
+	if err != nil {
+		return nil, err
+	}
+	kept := make([]ListCell, 0, len(vals))
+	for _, val := range vals {
+		result, err := callFunction(funct, env, val)
+		if err != nil {
+			return nil, err
+		}
+		keep, ok := result.Value.(bool)
+		if !ok {
+			err := fmt.Sprintf("Error: filter expects its predicate to return a bool but it returned %v.\n", result.TypeName)
+			return nil, errors.New(err)
+		}
+		if keep {
+			kept = append(kept, val)
+		}
+	}
+	returnVal := newSeq(kept, vector, env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoReduce combines the elements of a list or vector from left to right, calling a
+// function on the result so far and the next element. reduce without an
+// initial value starts from the first element, and returns nil for an
+// empty list.
+func GoReduce(funcType goFuncType, parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	name := goFuncNames[funcType]
+	if len(parameters) != 3 && (funcType != GoReduceT || len(parameters) != 2) {
+		err := fmt.Sprintf("Error: builtin %v expects a function, an initial value and a List or Vector but was called with %v arguments.\n", name, len(parameters))
+		return nil, errors.New(err)
+	}
+	funct, err := funcArg(name, &parameters[0])
+	if err != nil {
+		return nil, err
+	}
+	vals, _, err := seqArg(name, &parameters[len(parameters)-1])
+	if err != nil {
+		return nil, err
+	}
+	acc := makeNilCell()
+	if len(parameters) == 3 {
+		acc = parameters[1]
+	} else if len(vals) > 0 {
+		acc, vals = vals[0], vals[1:]
+	}
+	for _, val := range vals {
+		acc, err = callFunction(funct, env, acc, val)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []*ListCell{&acc}, nil
+}
+
+// GoRange returns the list of ints from start, which defaults to 0, up to
+// but not including end, counting by step, which defaults to 1.
+func GoRange(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if len(parameters) == 0 || len(parameters) > 3 {
+		err := fmt.Sprintf("Error: builtin range expects an end, a start and an end, or a start, an end and a step but was called with %v arguments.\n", len(parameters))
+		return nil, errors.New(err)
+	}
+	bounds := []int{0, 0, 1}
+	for i := range parameters {
+		bound, ok := parameters[i].Value.(int)
+		if !ok {
+			err := fmt.Sprintf("Error: range expects ints but got %v.\n", parameters[i].TypeName)
+			return nil, errors.New(err)
+		}
+		bounds[i] = bound
+	}
+	if len(parameters) == 1 {
+		bounds[0], bounds[1] = 0, bounds[0]
+	}
+	start, end, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return nil, errors.New("Error: range expects a non-zero step.\n")
+	}
+	length, ok := rangeLength(start, end, step)
+	if !ok {
+		err := fmt.Sprintf("Error: range from %v to %v by %v has more elements than a List can hold.\n", start, end, step)
+		return nil, errors.New(err)
+	}
+	state := env.evalState()
+	state.allocList(length)
+	vals := make([]ListCell, length)
+	for i := range vals {
+		state.poll(i)
+		vals[i] = ListCell{TypeName: INT_TYPE_NAME, Value: start + i*step}
+	}
+	returnVal := ListCell{TypeName: LIST_TYPE_NAME, Value: vals}
+	return []*ListCell{&returnVal}, nil
+}
+
+// rangeLength returns the number of ints range counts from start up to end
+// by step, or false if there are more than a List can hold, which is taken
+// to be math.MaxInt32. The difference between start and end is taken as
+// unsigned, so that it cannot overflow.
+func rangeLength(start, end, step int) (int, bool) {
+	var span, stride uint64
+	if step > 0 && end > start {
+		span, stride = uint64(end)-uint64(start), uint64(step)
+	} else if step < 0 && end < start {
+		span, stride = uint64(start)-uint64(end), -uint64(step)
+	} else {
+		return 0, true
+	}
+	length := span / stride
+	if span%stride != 0 {
+		length++
+	}
+	if length > math.MaxInt32 {
+		return 0, false
+	}
+	return int(length), true
+}
+
+// GoSort returns a list sorted into ascending order, or with a comparator,
+// into the order in which the comparator returns true for an element and
+// any that follow it. The sort is stable.
+func GoSort(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if len(parameters) == 0 || len(parameters) > 2 {
+		err := fmt.Sprintf("Error: builtin sort expects an optional comparator and a List but was called with %v arguments.\n", len(parameters))
+		return nil, errors.New(err)
+	}
+	vals, err := listArg("sort", &parameters[len(parameters)-1])
+	if err != nil {
+		return nil, err
+	}
+	sorted := make([]ListCell, len(vals))
+	copy(sorted, vals)
+	var sortErr error
+	state := env.evalState()
+	comparisons := 0
+	less := func(i, j int) bool {
+		state.poll(comparisons)
+		comparisons++
+		order, err := compareNumbers(&sorted[i], &sorted[j])
+		if err != nil && sortErr == nil {
+			sortErr = err
+		}
+		return order < 0
+	}
+	if len(parameters) == 2 {
+		funct, err := funcArg("sort", &parameters[0])
+		if err != nil {
+			return nil, err
+		}
+		less = func(i, j int) bool {
+			if sortErr != nil {
+				return false
+			}
+			result, err := callFunction(funct, env, sorted[i], sorted[j])
+			if err != nil {
+				sortErr = err
+				return false
+			}
+			isLess, ok := result.Value.(bool)
+			if !ok {
+				err := fmt.Sprintf("Error: sort expects its comparator to return a bool but it returned %v.\n", result.TypeName)
+				sortErr = errors.New(err)
+			}
+			return isLess
+		}
+	}
+	sort.SliceStable(sorted, less)
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	returnVal := newList(sorted, env)
+	return []*ListCell{&returnVal}, nil
+}
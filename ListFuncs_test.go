@@ -0,0 +1,138 @@
+package Golly
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListBuiltins(t *testing.T) {
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{"(list 1 2 3)", []interface{}{1, 2, 3}},
+		{"(cons 0 (list 1 2))", []interface{}{0, 1, 2}},
+		{"(cons 1 nil)", []interface{}{1}},
+		{"(car (list 1 2))", 1},
+		{"(first (list 1 2))", 1},
+		{"(cdr (list 1 2 3))", []interface{}{2, 3}},
+		{"(rest (list 1))", []interface{}{}},
+		{"(nth (list 1 2 3) 2)", 3},
+		{"(length (list 1 2 3))", 3},
+		{"(length nil)", 0},
+		{"(car nil)", nil},
+		{"(reduce + nil)", nil},
+		{"(append (list 1) (list 2 3) nil)", []interface{}{1, 2, 3}},
+		{"(reverse (list 1 2 3))", []interface{}{3, 2, 1}},
+		{"(map (fn (n) (* n n)) (list 1 2 3))", []interface{}{1, 4, 9}},
+		{"(map + (list 1 2 3) (list 10 20))", []interface{}{11, 22}},
+		{"(filter (fn (n) (> n 1)) (list 1 2 3))", []interface{}{2, 3}},
+		{"(reduce + (list 1 2 3 4))", 10},
+		{"(reduce (fn (acc n) (- acc n)) 10 (list 1 2))", 7},
+		{"(fold (fn (acc n) (cons n acc)) nil (list 1 2 3))", []interface{}{3, 2, 1}},
+		{"(range 0 5)", []interface{}{0, 1, 2, 3, 4}},
+		{"(range 5 0 -2)", []interface{}{5, 3, 1}},
+		{"(first [1 2])", 1},
+		{"(first [])", nil},
+		{"(= (rest [1 2 3]) [2 3])", true},
+		{"(= (rest []) [])", true},
+		{"(= (map (fn (n) (* n n)) [1 2 3]) [1 4 9])", true},
+		{"(map + (list 1 2) [10 20 30])", []interface{}{11, 22}},
+		{"(= (filter (fn (n) (> n 1)) [1 2 3]) [2 3])", true},
+		{"(reduce + [1 2 3 4])", 10},
+		{"(fold (fn (acc n) (cons n acc)) nil [1 2])", []interface{}{2, 1}},
+		{"(count [1 2 3])", 3},
+		{"(sort (list 3 1 2))", []interface{}{1, 2, 3}},
+		{"(sort > (list 3 1 2))", []interface{}{3, 2, 1}},
+		{"(sort (fn (a b) (< (car a) (car b))) (list (list 1 1) (list 0 2) (list 1 3)))", []interface{}{[]interface{}{0, 2}, []interface{}{1, 1}, []interface{}{1, 3}}},
+	}
+	for _, backEnd := range backEnds {
+		for _, test := range tests {
+			env := NewEnvironment(CreateSystemFuncs())
+			result, err := EvalString(context.Background(), test.src, env, backEnd.opts)
+			if err != nil || !reflect.DeepEqual(goValue(result), test.want) {
+				t.Errorf("%v: %v returned %#v, %v, want %#v", backEnd.name, test.src, goValue(result), err, test.want)
+			}
+		}
+	}
+}
+
+func TestListBuiltinErrors(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"(car 1)", "car"},
+		{"(nth (list 1 2) 5)", "nth"},
+		{"(map 1 (list 1))", "map"},
+		{"(filter (fn (n) n) (list 1))", "filter"},
+		{"(reduce + 0)", "reduce"},
+		{"(first {1 2})", "first expects a List or Vector but got Map"},
+		{"(map (fn (n) n) {1 2})", "map expects a List or Vector but got Map"},
+		{"(sort (list 1 true))", "compare"},
+		{"(sort 1 2 3)", "sort"},
+	}
+	for _, backEnd := range backEnds {
+		for _, test := range tests {
+			env := NewEnvironment(CreateSystemFuncs())
+			_, err := EvalString(context.Background(), test.src, env, backEnd.opts)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("%v: %v returned %v, want an error mentioning %q", backEnd.name, test.src, err, test.want)
+			}
+		}
+	}
+}
+
+func TestRangeLength(t *testing.T) {
+	tests := []struct {
+		start, end, step, want int
+		ok                     bool
+	}{
+		{0, 10, 1, 10, true},
+		{0, 10, 3, 4, true},
+		{10, 0, -3, 4, true},
+		{0, 10, -1, 0, true},
+		{5, 5, 1, 0, true},
+		{-9223372036854775807, 9223372036854775807, 1, 0, false},
+		{9223372036854775807, -9223372036854775808, -9223372036854775808, 2, true},
+	}
+	for _, test := range tests {
+		got, ok := rangeLength(test.start, test.end, test.step)
+		if got != test.want || ok != test.ok {
+			t.Errorf("rangeLength(%v, %v, %v) = %v, %v, want %v, %v", test.start, test.end, test.step, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestRangeOverflowIsAnError(t *testing.T) {
+	env := NewEnvironment(CreateSystemFuncs())
+	_, err := EvalString(context.Background(), "(range -9223372036854775807 9223372036854775807)", env, EvalOptions{})
+	if err == nil || !strings.Contains(err.Error(), "more elements than a List can hold") {
+		t.Errorf("an overflowing range returned %v", err)
+	}
+}
+
+func TestRangeHonoursDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	env := NewEnvironment(CreateSystemFuncs())
+	_, err := EvalString(ctx, "(do (range 0 10) (range 0 20000000))", env, EvalOptions{})
+	var cancelled *CancelledError
+	if !errors.As(err, &cancelled) {
+		t.Errorf("a range past its deadline returned %v, want a CancelledError", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("a range past its deadline ran for %v", elapsed)
+	}
+}
+
+func TestRangeListLengthLimit(t *testing.T) {
+	env := NewEnvironment(CreateSystemFuncs())
+	_, err := EvalString(context.Background(), "(range 0 100000000)", env, EvalOptions{Limits: Limits{MaxListLength: 1000}})
+	var limitErr *LimitExceeded
+	if !errors.As(err, &limitErr) || limitErr.Limit != ListLengthLimit {
+		t.Errorf("a range over the list length limit returned %v", err)
+	}
+}
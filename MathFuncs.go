@@ -0,0 +1,92 @@
+package Golly
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+func GoMod(Cell1 *ListCell, Cell2 *ListCell) ([]*ListCell, error) {
+	val1, ok1 := Cell1.Value.(int)
+	val2, ok2 := Cell2.Value.(int)
+	if !ok1 || !ok2 {
+		err := fmt.Sprintf("Error: mod expects two ints but got %v and %v.\n", Cell1.TypeName, Cell2.TypeName)
+		return nil, errors.New(err)
+	}
+	if val2 == 0 {
+		return nil, errors.New("Error: attempting to take an int modulo zero.\n")
+	}
+	returnVal := ListCell{TypeName: INT_TYPE_NAME, Value: val1 % val2}
+	return []*ListCell{&returnVal}, nil
+}
+
+// toFloat widens an int or float cell to a float64.
+func toFloat(cell *ListCell) (float64, bool) {
+	switch val := cell.Value.(type) {
+	case int:
+		return float64(val), true
+	case float64:
+		return val, true
+	}
+	return 0, false
+}
+
+// GoMathUnary implements abs, sqrt, floor and ceil. abs keeps the type of
+// its argument, sqrt always gives a float, and floor and ceil turn a float
+// into an int.
+func GoMathUnary(funcType goFuncType, Cell1 *ListCell) ([]*ListCell, error) {
+	var returnVal ListCell
+	if val, ok := Cell1.Value.(int); ok && funcType != GoSqrtT {
+		if funcType == GoAbsT && val < 0 {
+			val = -val
+		}
+		returnVal = ListCell{TypeName: INT_TYPE_NAME, Value: val}
+		return []*ListCell{&returnVal}, nil
+	}
+	val, ok := toFloat(Cell1)
+	if !ok {
+		err := fmt.Sprintf("Error: %v expects a number but got %v.\n", goFuncNames[funcType], Cell1.TypeName)
+		return nil, errors.New(err)
+	}
+	switch funcType {
+	case GoAbsT:
+		returnVal = ListCell{TypeName: FLOAT_TYPE_NAME, Value: math.Abs(val)}
+	case GoSqrtT:
+		if val < 0 {
+			err := fmt.Sprintf("Error: attempting to take the square root of the negative number %v.\n", val)
+			return nil, errors.New(err)
+		}
+		returnVal = ListCell{TypeName: FLOAT_TYPE_NAME, Value: math.Sqrt(val)}
+	case GoFloorT:
+		returnVal = ListCell{TypeName: INT_TYPE_NAME, Value: int(math.Floor(val))}
+	case GoCeilT:
+		returnVal = ListCell{TypeName: INT_TYPE_NAME, Value: int(math.Ceil(val))}
+	}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoPow raises an int to a non-negative int power exactly; any other
+// combination of numbers gives a float.
+func GoPow(Cell1 *ListCell, Cell2 *ListCell) ([]*ListCell, error) {
+	base, ok1 := Cell1.Value.(int)
+	exponent, ok2 := Cell2.Value.(int)
+	if ok1 && ok2 && exponent >= 0 {
+		result := 1
+		for ; exponent > 0; exponent >>= 1 {
+			if exponent&1 == 1 {
+				result *= base
+			}
+			base *= base
+		}
+		returnVal := ListCell{TypeName: INT_TYPE_NAME, Value: result}
+		return []*ListCell{&returnVal}, nil
+	}
+	floatBase, ok1 := toFloat(Cell1)
+	floatExponent, ok2 := toFloat(Cell2)
+	if !ok1 || !ok2 {
+		err := fmt.Sprintf("Error: pow expects two numbers but got %v and %v.\n", Cell1.TypeName, Cell2.TypeName)
+		return nil, errors.New(err)
+	}
+	returnVal := ListCell{TypeName: FLOAT_TYPE_NAME, Value: math.Pow(floatBase, floatExponent)}
+	return []*ListCell{&returnVal}, nil
+}
@@ -0,0 +1,454 @@
+package Golly
+
+import (
+	"Golly/parser"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// exprPure reports whether evaluating tok can have no side effects and
+// depends on nothing but its inputs. It is conservative: calls through
+// locals, mutable globals and globals not yet bound all count as impure.
+// So do impure functions and fns with impure bodies wherever they appear,
+// since a builtin such as map may call a function it is passed. selfName
+// is the name a function under analysis is being bound to, so that direct
+// recursion doesn't make it impure.
+func exprPure(tok *Parser.Token, env *Environment, selfName string) bool {
+	switch tok.Type {
+	case Parser.LiteralToken:
+		return true
+	case Parser.IdToken:
+		if tok.Scope == Parser.LocalScope || tok.Value == selfName {
+			return true
+		}
+		if env.System != nil {
+			if binding, ok := env.System.Bindings[tok.Value]; ok {
+				funct, isFunc := binding.Binding.Value.(FunctionObj)
+				return !isFunc || funct.Pure
+			}
+		}
+		if binding, ok := env.loadGlobal(tok.Value); ok {
+			funct, isFunc := binding.Binding.Value.(FunctionObj)
+			return !binding.Binding.Mutable && (!isFunc || funct.Pure)
+		}
+		return true
+	case Parser.ListToken:
+		return listPure(tok, env, selfName)
+	case Parser.VectorToken, Parser.MapToken:
+		return bodyPure(tok.ListVals, env, selfName)
+	default:
+		return false
+	}
+}
+
+func bodyPure(body []Parser.Token, env *Environment, selfName string) bool {
+	for i := range body {
+		if !exprPure(&body[i], env, selfName) {
+			return false
+		}
+	}
+	return true
+}
+
+func listPure(list *Parser.Token, env *Environment, selfName string) bool {
+	if len(list.ListVals) == 0 {
+		return true
+	}
+	firstVal := &list.ListVals[0]
+	switch firstVal.Type {
+	case Parser.DefToken:
+		if firstVal.Value == "def" || firstVal.Value == "defm" || len(list.ListVals) < 2 {
+			return false
+		}
+		bindingsPure := true
+		forEachBinding(&list.ListVals[1], func(nameTok, typeTok, valueTok *Parser.Token) {
+			bindingsPure = bindingsPure && exprPure(valueTok, env, selfName)
+		})
+		return bindingsPure && bodyPure(list.ListVals[2:], env, selfName)
+	case Parser.FormToken:
+		switch firstVal.Value {
+		case "fn":
+			return len(list.ListVals) < 3 || bodyPure(list.ListVals[2:], env, selfName)
+		case "if", "do":
+			return bodyPure(list.ListVals[1:], env, selfName)
+		case "quote":
+			return true
+		default:
+			return false
+		}
+	case Parser.IdToken:
+		if !calleePure(firstVal, env, selfName) {
+			return false
+		}
+		return bodyPure(list.ListVals[1:], env, selfName)
+	case Parser.ListToken:
+		if !isFnForm(firstVal) || len(firstVal.ListVals) < 3 || !bodyPure(firstVal.ListVals[2:], env, selfName) {
+			return false
+		}
+		return bodyPure(list.ListVals[1:], env, selfName)
+	default:
+		return false
+	}
+}
+
+func calleePure(head *Parser.Token, env *Environment, selfName string) bool {
+	if head.Value == selfName && selfName != "" {
+		return true
+	}
+	var binding *EnvBinding
+	if env.System != nil && head.Scope != Parser.LocalScope {
+		if sysBinding, ok := env.System.Bindings[head.Value]; ok {
+			binding = &sysBinding
+		}
+	}
+	if binding == nil && head.Scope != Parser.LocalScope {
+		if globalBinding, ok := env.loadGlobal(head.Value); ok {
+			binding = &globalBinding
+		}
+	}
+	if binding == nil || binding.Binding.Mutable {
+		return false
+	}
+	funct, ok := binding.Binding.Value.(FunctionObj)
+	return ok && funct.Pure
+}
+
+// markPurity sets Pure on every fn and defn list under tok whose body is
+// pure, which is then carried over to the FunctionObjs they create.
+func markPurity(tok *Parser.Token, env *Environment, selfName string) {
+	if tok.Type == Parser.VectorToken || tok.Type == Parser.MapToken {
+		for i := range tok.ListVals {
+			markPurity(&tok.ListVals[i], env, "")
+		}
+		return
+	} else if tok.Type != Parser.ListToken || len(tok.ListVals) == 0 || isQuoteForm(tok) {
+		return
+	}
+	firstVal := &tok.ListVals[0]
+	if firstVal.Type == Parser.FormToken {
+		switch firstVal.Value {
+		case "fn":
+			if len(tok.ListVals) > 2 {
+				tok.Pure = bodyPure(tok.ListVals[2:], env, selfName)
+			}
+		case "defn", "defn-memo":
+			if len(tok.ListVals) > 3 {
+				tok.Pure = bodyPure(tok.ListVals[3:], env, tok.ListVals[1].Value)
+			}
+		}
+	}
+	if firstVal.Type == Parser.DefToken && len(tok.ListVals) > 1 {
+		forEachBinding(&tok.ListVals[1], func(nameTok, typeTok, valueTok *Parser.Token) {
+			markPurity(valueTok, env, nameTok.Value)
+		})
+		for i := 2; i < len(tok.ListVals); i++ {
+			markPurity(&tok.ListVals[i], env, "")
+		}
+		return
+	}
+	for i := range tok.ListVals {
+		markPurity(&tok.ListVals[i], env, "")
+	}
+}
+
+// Optimise rewrites a resolved top-level list in place: calls to pure
+// builtins with scalar results, such as arithmetic, whose arguments are all
+// literals are folded into their results, ifs on a literal condition are
+// replaced by the branch taken, and let bindings that are never referenced
+// and whose values are pure are dropped. A dropped binding is never
+// evaluated, so an error it would have raised, such as dividing by zero,
+// goes unreported. The list must be resolved first, so that a call through
+// a local is not taken for a call of the global it shadows, and again
+// afterwards, since dropping a binding moves the addresses of the rest.
+func Optimise(tokens *Parser.Token, env *Environment) {
+	for i := range tokens.ListVals {
+		optimiseToken(&tokens.ListVals[i], env)
+	}
+}
+
+func optimiseToken(tok *Parser.Token, env *Environment) {
+	if tok.Type == Parser.VectorToken || tok.Type == Parser.MapToken {
+		for i := range tok.ListVals {
+			optimiseToken(&tok.ListVals[i], env)
+		}
+		return
+	} else if tok.Type != Parser.ListToken || len(tok.ListVals) == 0 || isQuoteForm(tok) {
+		return
+	}
+	for i := range tok.ListVals {
+		optimiseToken(&tok.ListVals[i], env)
+	}
+	firstVal := &tok.ListVals[0]
+	switch firstVal.Type {
+	case Parser.IdToken:
+		foldCall(tok, env)
+	case Parser.FormToken:
+		if firstVal.Value == "if" {
+			foldIf(tok)
+		}
+	case Parser.DefToken:
+		if firstVal.Value == "let" || firstVal.Value == "letm" {
+			eliminateDeadBindings(tok, env)
+		}
+	}
+}
+
+// foldableGoFuncs are the pure builtins foldCall may run while optimising:
+// those whose results are scalars a literal can stand for, computed in time
+// proportional to their arguments. Builtins that build collections, such as
+// range, are left alone, since folding one would do its work even in code
+// that never runs, only for the result to be thrown away.
+var foldableGoFuncs = map[goFuncType]bool{
+	GoAddT:            true,
+	GoSubtractT:       true,
+	GoMultiplyT:       true,
+	GoDivideT:         true,
+	GoEqualT:          true,
+	GoLessT:           true,
+	GoGreaterT:        true,
+	GoLessEqT:         true,
+	GoGreaterEqT:      true,
+	GoModT:            true,
+	GoAbsT:            true,
+	GoSqrtT:           true,
+	GoPowT:            true,
+	GoFloorT:          true,
+	GoCeilT:           true,
+	GoUpperT:          true,
+	GoLowerT:          true,
+	GoTrimT:           true,
+	GoIndexOfT:        true,
+	GoStringToNumberT: true,
+	GoNumberToStringT: true,
+	GoCharAlphabeticT: true,
+	GoCharDigitT:      true,
+	GoCharWhitespaceT: true,
+	GoCharToIntT:      true,
+	GoIntToCharT:      true,
+	GoKeywordT:        true,
+}
+
+func foldCall(list *Parser.Token, env *Environment) {
+	if env == nil || env.System == nil {
+		return
+	}
+	binding, ok := env.System.Bindings[list.ListVals[0].Value]
+	if !ok {
+		return
+	}
+	funct, ok := binding.Binding.Value.(FunctionObj)
+	if !ok || !funct.GoFunc || !funct.Pure || !foldableGoFuncs[funct.FuncType] {
+		return
+	}
+	params := make([]ListCell, 0, len(list.ListVals)-1)
+	for i := 1; i < len(list.ListVals); i++ {
+		param, ok := literalValue(&list.ListVals[i], env)
+		if !ok {
+			return
+		}
+		params = append(params, param)
+	}
+	result, err := callFolded(funct, params, env)
+	if err != nil {
+		//Leave the call in place so the error is reported when it runs.
+		return
+	}
+	if folded, ok := valueToLiteral(result, list.LineNum); ok {
+		*list = folded
+	}
+}
+
+// callFolded calls a builtin being folded, turning a panic, such as a
+// LimitExceeded, into an error so that the call is left unfolded.
+func callFolded(funct FunctionObj, params []ListCell, env *Environment) (result ListCell, err error) {
+	defer recoverEvalError(&err)
+	returnedVals, err := funct.Call(params, env)
+	if err != nil {
+		return ListCell{}, err
+	}
+	return firstReturnVal(returnedVals), nil
+}
+
+func literalValue(tok *Parser.Token, env *Environment) (ListCell, bool) {
+	caller := "optimise"
+	switch tok.Type {
+	case Parser.LiteralToken:
+		val, err := literalCell(tok, &caller)
+		return val, err == nil
+	case Parser.IdToken:
+		if binding, ok := env.System.Bindings[tok.Value]; ok && binding.Binding.TypeName == BOOL_TYPE_NAME {
+			return binding.Binding, true
+		}
+	}
+	return ListCell{}, false
+}
+
+func literalCell(tok *Parser.Token, caller *string) (val ListCell, err error) {
+	defer recoverEvalError(&err)
+	return evalLitToken(tok, tok.LineNum, caller), nil
+}
+
+func valueToLiteral(val ListCell, lineNum int) (Parser.Token, bool) {
+	switch v := val.Value.(type) {
+	case int:
+		if val.TypeName == INT_TYPE_NAME {
+			return Parser.Token{Type: Parser.LiteralToken, LitType: Parser.FixNum, Value: strconv.Itoa(v), LineNum: lineNum}, true
+		}
+	case float64:
+		if val.TypeName == FLOAT_TYPE_NAME {
+			return Parser.Token{Type: Parser.LiteralToken, LitType: Parser.FloNum, Value: Parser.FloatLiteral(v), LineNum: lineNum}, true
+		}
+	case *KeywordObj:
+		return Parser.Token{Type: Parser.LiteralToken, LitType: Parser.Keyword, Value: v.Name(), LineNum: lineNum}, true
+	case rune:
+		if val.TypeName == CHAR_TYPE_NAME {
+			return Parser.Token{Type: Parser.LiteralToken, LitType: Parser.Char, Value: string(v), LineNum: lineNum}, true
+		}
+	case string:
+		if val.TypeName == STRING_TYPE_NAME {
+			return Parser.Token{Type: Parser.LiteralToken, LitType: Parser.String, Value: v, LineNum: lineNum}, true
+		}
+	case bool:
+		return Parser.Token{Type: Parser.IdToken, Value: strconv.FormatBool(v), LineNum: lineNum}, true
+	}
+	return Parser.Token{}, false
+}
+
+func foldIf(list *Parser.Token) {
+	if len(list.ListVals) < 3 || len(list.ListVals) > 4 {
+		return
+	}
+	cond := &list.ListVals[1]
+	if cond.Type != Parser.IdToken || (cond.Value != "true" && cond.Value != "false") {
+		return
+	}
+	if cond.Value == "true" {
+		*list = list.ListVals[2]
+	} else if len(list.ListVals) == 4 {
+		*list = list.ListVals[3]
+	} else {
+		*list = Parser.Token{Type: Parser.IdToken, Value: "nil", LineNum: list.LineNum}
+	}
+}
+
+func referencesName(tokens []Parser.Token, name string) bool {
+	for i := range tokens {
+		tok := &tokens[i]
+		if tok.Type == Parser.IdToken && tok.Value == name {
+			return true
+		}
+		if (tok.Type == Parser.ListToken || tok.Type == Parser.VectorToken || tok.Type == Parser.MapToken) && referencesName(tok.ListVals, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func eliminateDeadBindings(list *Parser.Token, env *Environment) {
+	if len(list.ListVals) < 3 || list.ListVals[1].Type != Parser.ListToken {
+		return
+	}
+	bindings := list.ListVals[1].ListVals
+	kept := make([]Parser.Token, 0, len(bindings))
+	i := 0
+	for ; i+1 < len(bindings); i += 2 {
+		if bindings[i+1].Type == Parser.TypeAnnToken {
+			//Annotated bindings are kept so a mismatched type is still reported.
+			if i+3 >= len(bindings) {
+				break
+			}
+			kept = append(kept, bindings[i:i+4]...)
+			i += 2
+			continue
+		}
+		name := bindings[i].Value
+		rest := append(append([]Parser.Token{}, bindings[i+1:]...), list.ListVals[2:]...)
+		if referencesName(rest, name) || !exprPure(&bindings[i+1], env, name) {
+			kept = append(kept, bindings[i], bindings[i+1])
+		}
+	}
+	//Anything malformed is left for the evaluator to report.
+	kept = append(kept, bindings[i:]...)
+	if len(kept) == 0 {
+		doTok := Parser.Token{Type: Parser.FormToken, Value: "do", LineNum: list.ListVals[0].LineNum}
+		list.ListVals = append([]Parser.Token{doTok}, list.ListVals[2:]...)
+		return
+	}
+	list.ListVals[1].ListVals = kept
+}
+
+// memoTable caches the results of a pure function by argument values. It
+// is shared by every copy of the FunctionObj it belongs to.
+type memoTable struct {
+	mu      sync.Mutex
+	results map[string]ListCell
+}
+
+func newMemoTable() *memoTable {
+	return &memoTable{results: make(map[string]ListCell)}
+}
+
+func memoise(aFunc FunctionObj, lineNum int) FunctionObj {
+	if !aFunc.Pure {
+		errMsg := fmt.Sprintf("Error: defn-memo at line %v requires a pure function, but %v calls impure code.\n", lineNum, aFunc.Name)
+		panic(errMsg)
+	}
+	aFunc.memo = newMemoTable()
+	return aFunc
+}
+
+func (table *memoTable) call(aFunc *FunctionObj, params []ListCell, env *Environment) ([]*ListCell, error) {
+	uncached := *aFunc
+	uncached.memo = nil
+	key, ok := memoKey(params)
+	if !ok {
+		return uncached.Call(params, env)
+	}
+	table.mu.Lock()
+	cached, found := table.results[key]
+	table.mu.Unlock()
+	if found {
+		return []*ListCell{&cached}, nil
+	}
+	returnedVals, err := uncached.Call(params, env)
+	if err != nil {
+		return nil, err
+	}
+	result := firstReturnVal(returnedVals)
+	table.mu.Lock()
+	table.results[key] = result
+	table.mu.Unlock()
+	return []*ListCell{&result}, nil
+}
+
+// memoKey builds a cache key from plain data arguments; anything else,
+// such as functions, makes the call uncacheable.
+func memoKey(params []ListCell) (string, bool) {
+	var key strings.Builder
+	for _, param := range params {
+		if !writeMemoKey(&key, &param) {
+			return "", false
+		}
+	}
+	return key.String(), true
+}
+
+func writeMemoKey(key *strings.Builder, cell *ListCell) bool {
+	switch val := cell.Value.(type) {
+	case int, int64, int32, int16, float64, float32, bool, nil:
+		fmt.Fprintf(key, "%v:%v;", cell.TypeName, val)
+	case []ListCell:
+		fmt.Fprintf(key, "%v(", cell.TypeName)
+		for i := range val {
+			if !writeMemoKey(key, &val[i]) {
+				return false
+			}
+		}
+		key.WriteString(");")
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package Golly
+
+import (
+	"Golly/parser"
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func optimised(t *testing.T, src string) Parser.Token {
+	t.Helper()
+	tokens, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := NewEnvironment(CreateSystemFuncs())
+	if errs := Resolve(&tokens, env); len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+	Optimise(&tokens, env)
+	return tokens.ListVals[0]
+}
+
+func TestFoldScalarCalls(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"(+ 1 (* 2 3))", "7"},
+		{"(/ 1.0 4.0)", "0.25"},
+		{"(upper \"abc\")", "ABC"},
+		{"(< 1 2)", "true"},
+	}
+	for _, test := range tests {
+		if tok := optimised(t, test.src); tok.Type == Parser.ListToken || tok.Value != test.want {
+			t.Errorf("optimising %v gave %+v, want %v", test.src, tok, test.want)
+		}
+	}
+}
+
+func TestDontFoldCollections(t *testing.T) {
+	start := time.Now()
+	tok := optimised(t, "(defn f () (range 0 30000000))")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("optimising a range call took %v", elapsed)
+	}
+	if body := tok.ListVals[3]; body.Type != Parser.ListToken || body.ListVals[0].Value != "range" {
+		t.Errorf("range call was folded into %+v", body)
+	}
+}
+
+func TestDontFoldErrors(t *testing.T) {
+	if tok := optimised(t, "(/ 1 0)"); tok.Type != Parser.ListToken {
+		t.Errorf("division by zero was folded into %+v", tok)
+	}
+}
+
+func TestFoldingPanicIsReturned(t *testing.T) {
+	env := NewEnvironment(CreateSystemFuncs())
+	opts := EvalOptions{Optimise: true, Limits: Limits{MaxSteps: 1}}
+	_, err := EvalString(context.Background(), "(+ (+ 1 2) (+ 3 4))", env, opts)
+	var limitErr *LimitExceeded
+	if !errors.As(err, &limitErr) {
+		t.Errorf("EvalString over the step limit while folding returned %v, want a LimitExceeded", err)
+	}
+}
+
+// TestLocalsShadowingPureGlobals checks that a let binding calling a local
+// that shadows a pure global is not dropped as if it called the global.
+func TestLocalsShadowingPureGlobals(t *testing.T) {
+	srcs := []string{
+		`(let (g (fn (x) (display "SIDE")) unused (g 1)) 0)`,
+		"(defn h (g) (let (unused (g 1)) 0))\n(h (fn (x) (display \"SIDE\")))",
+	}
+	for _, backEnd := range backEnds {
+		for _, src := range srcs {
+			sys := CreateSystemFuncs()
+			var out bytes.Buffer
+			sys.Stdout = &out
+			env := NewEnvironment(sys)
+			opts := backEnd.opts
+			opts.Optimise = true
+			//g is bound before src is optimised, as at the REPL.
+			if _, err := EvalString(context.Background(), "(defn g (x) x)", env, opts); err != nil {
+				t.Fatal(err)
+			}
+			result, err := EvalString(context.Background(), src, env, opts)
+			if err != nil || result.Value != 0 || out.String() != "SIDE" {
+				t.Errorf("%v: %v returned %v, %v and printed %q, want 0 and SIDE", backEnd.name, src, result.Value, err, out.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package Golly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Future is the value of a Golly future, made by future and read by await.
+type Future struct {
+	done   chan struct{}
+	result ListCell
+	err    error
+}
+
+// poolSize is the number of worker goroutines an evaluation may run pmap,
+// par and future calls on when EvalOptions.PoolSize is zero.
+func poolSize(size int) int {
+	if size > 0 {
+		return size
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// parallel calls every one of calls and returns their results in order.
+// Each call runs on a worker from the evaluation's pool, or on the calling
+// goroutine when none is free, so nested use of the pool cannot deadlock.
+// The first error stops the calls not yet started and is returned.
+func (state *evalState) parallel(calls []func(*evalState) (ListCell, error)) ([]ListCell, error) {
+	results := make([]ListCell, len(calls))
+	if state == nil {
+		for i, call := range calls {
+			result, err := call(nil)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = result
+		}
+		return results, nil
+	}
+	ctx, cancel := context.WithCancel(state.ctx)
+	defer cancel()
+	var tasks sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	run := func(i int, child *evalState) {
+		var err error
+		func() {
+			defer recoverEvalError(&err)
+			results[i], err = calls[i](child)
+		}()
+		child.flushSteps()
+		if err != nil {
+			once.Do(func() {
+				firstErr = err
+			})
+			cancel()
+		}
+	}
+	for i := range calls {
+		if ctx.Err() != nil {
+			break
+		}
+		child := &evalState{ctx: ctx, limits: state.limits, depth: state.depth, shared: state.shared}
+		select {
+		case state.shared.workers <- struct{}{}:
+			tasks.Add(1)
+			go func(i int) {
+				defer tasks.Done()
+				defer func() { <-state.shared.workers }()
+				run(i, child)
+			}(i)
+		default:
+			run(i, child)
+		}
+	}
+	tasks.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	state.checkContext()
+	return results, nil
+}
+
+// pureFuncArg checks that cell holds a pure function, as the parallel
+// builtins require so that the order calls run in cannot be observed.
+func pureFuncArg(name string, cell *ListCell) (FunctionObj, error) {
+	funct, ok := cell.Value.(FunctionObj)
+	if !ok {
+		err := fmt.Sprintf("Error: %v expects a function but got %v.\n", name, cell.TypeName)
+		return FunctionObj{}, errors.New(err)
+	}
+	if !funct.Pure {
+		funcName := funct.Name
+		if funcName == "" {
+			funcName = "an anonymous function"
+		}
+		err := fmt.Sprintf("Error: %v expects a pure function, but %v is impure.\n", name, funcName)
+		return FunctionObj{}, errors.New(err)
+	}
+	return funct, nil
+}
+
+func callWith(funct FunctionObj, args []ListCell, env *Environment) func(*evalState) (ListCell, error) {
+	return func(state *evalState) (ListCell, error) {
+		callEnv := env
+		if state != nil {
+			callEnv = env.withState(state)
+		}
+		returnedVals, err := funct.Call(args, callEnv)
+		if err != nil {
+			return ListCell{}, err
+		}
+		return firstReturnVal(returnedVals), nil
+	}
+}
+
+func makeListCell(vals []ListCell, env *Environment) ListCell {
+	env.evalState().allocList(len(vals))
+	return ListCell{TypeName: LIST_TYPE_NAME, Value: vals}
+}
+
+// GoPmap calls a pure function on every element of a list in parallel and
+// returns the list of results.
+func GoPmap(Cell1 *ListCell, Cell2 *ListCell, env *Environment) ([]*ListCell, error) {
+	funct, err := pureFuncArg("pmap", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	vals, ok := Cell2.Value.([]ListCell)
+	if !ok || Cell2.TypeName != LIST_TYPE_NAME {
+		err := fmt.Sprintf("Error: pmap expects a List but got %v.\n", Cell2.TypeName)
+		return nil, errors.New(err)
+	}
+	calls := make([]func(*evalState) (ListCell, error), len(vals))
+	for i := range vals {
+		calls[i] = callWith(funct, []ListCell{vals[i]}, env)
+	}
+	results, err := env.evalState().parallel(calls)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := makeListCell(results, env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoPar calls pure functions of no arguments in parallel and returns the
+// list of their results.
+func GoPar(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	calls := make([]func(*evalState) (ListCell, error), len(parameters))
+	for i := range parameters {
+		funct, err := pureFuncArg("par", &parameters[i])
+		if err != nil {
+			return nil, err
+		}
+		calls[i] = callWith(funct, nil, env)
+	}
+	results, err := env.evalState().parallel(calls)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := makeListCell(results, env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoFuture starts a call of a pure function with the given arguments on a
+// worker and returns a Future for its result. With no worker free, the
+// call is made before future returns.
+func GoFuture(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if len(parameters) == 0 {
+		return nil, errors.New("Error: builtin future expects a function to call.\n")
+	}
+	funct, err := pureFuncArg("future", &parameters[0])
+	if err != nil {
+		return nil, err
+	}
+	call := callWith(funct, append([]ListCell{}, parameters[1:]...), env)
+	future := &Future{done: make(chan struct{})}
+	returnVal := ListCell{TypeName: FUTURE_TYPE_NAME, Value: future}
+	state := env.evalState()
+	resolve := func(child *evalState) {
+		defer close(future.done)
+		defer recoverEvalError(&future.err)
+		future.result, future.err = call(child)
+	}
+	if state == nil {
+		resolve(nil)
+		return []*ListCell{&returnVal}, nil
+	}
+	child := &evalState{ctx: state.ctx, limits: state.limits, depth: state.depth, shared: state.shared}
+	select {
+	case state.shared.workers <- struct{}{}:
+		state.shared.tasks.Add(1)
+		go func() {
+			defer state.shared.tasks.Done()
+			defer func() { <-state.shared.workers }()
+			resolve(child)
+			child.flushSteps()
+		}()
+	default:
+		resolve(child)
+		child.flushSteps()
+	}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoAwait waits for a Future and returns its result, or the error its call
+// failed with.
+func GoAwait(Cell1 *ListCell, env *Environment) ([]*ListCell, error) {
+	future, ok := Cell1.Value.(*Future)
+	if !ok || Cell1.TypeName != FUTURE_TYPE_NAME {
+		err := fmt.Sprintf("Error: await expects a Future but got %v.\n", Cell1.TypeName)
+		return nil, errors.New(err)
+	}
+	select {
+	case <-future.done:
+	case <-doneChannel(env):
+		return nil, cancelled(env)
+	}
+	if future.err != nil {
+		return nil, future.err
+	}
+	returnVal := future.result
+	return []*ListCell{&returnVal}, nil
+}
@@ -0,0 +1,125 @@
+package Golly
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// goValue returns the Go value of a cell, with lists as slices of the Go
+// values of their elements, so results can be compared with DeepEqual.
+func goValue(cell ListCell) interface{} {
+	if vals, ok := cell.Value.([]ListCell); ok {
+		list := make([]interface{}, len(vals))
+		for i, val := range vals {
+			list[i] = goValue(val)
+		}
+		return list
+	}
+	return cell.Value
+}
+
+// pair is a list of 1 and 2, made with par.
+const pair = "(par (fn () 1) (fn () 2))"
+
+func TestParallelBuiltins(t *testing.T) {
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{"(pmap (fn (n) (* n n)) (par (fn () 1) (fn () 2) (fn () 3)))", []interface{}{1, 4, 9}},
+		{"(defn inc (n) (+ n 1))\n(pmap inc " + pair + ")", []interface{}{2, 3}},
+		{"(par (fn () 1) (fn () (+ 1 1)))", []interface{}{1, 2}},
+		{"(await (future (fn (a b) (* a b)) 6 7))", 42},
+		{"(pmap (fn (n) (pmap (fn (m) (* n m)) " + pair + ")) " + pair + ")", []interface{}{[]interface{}{1, 2}, []interface{}{2, 4}}},
+	}
+	for _, backEnd := range backEnds {
+		for _, size := range []int{1, 4} {
+			for _, test := range tests {
+				opts := backEnd.opts
+				opts.PoolSize = size
+				env := NewEnvironment(CreateSystemFuncs())
+				result, err := EvalString(context.Background(), test.src, env, opts)
+				if err != nil || !reflect.DeepEqual(goValue(result), test.want) {
+					t.Errorf("%v with %v workers: %v returned %v, %v, want %v", backEnd.name, size, test.src, goValue(result), err, test.want)
+				}
+			}
+		}
+	}
+}
+
+func TestParallelRefusesImpureFunctions(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"(pmap (fn (n) (println n)) " + pair + ")", "pmap expects a pure function, but an anonymous function is impure"},
+		{"(defn shout (n) (println n))\n(pmap shout " + pair + ")", "pmap expects a pure function, but shout is impure"},
+		{"(par (fn () 1) (fn () (println 2)))", "par expects a pure function"},
+		{"(future (fn () (println 1)))", "future expects a pure function"},
+		{"(pmap 1 " + pair + ")", "pmap expects a function"},
+	}
+	for _, backEnd := range backEnds {
+		for _, test := range tests {
+			sys := CreateSystemFuncs()
+			sys.Stdout = nil
+			_, err := EvalString(context.Background(), test.src, NewEnvironment(sys), backEnd.opts)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("%v: %v returned %v, want an error mentioning %q", backEnd.name, test.src, err, test.want)
+			}
+		}
+	}
+}
+
+func TestParallelErrorsPropagate(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"(await (future (fn () (/ 1 0))))", "divide int by zero"},
+		{"(let (f (future (fn (n) (/ n 0)) 1)) (await f))", "divide int by zero"},
+		{"(pmap (fn (n) (/ 1 n)) (par (fn () 1) (fn () 0)))", "divide int by zero"},
+		{"(par (fn () 1) (fn () (/ 2 0)))", "divide int by zero"},
+	}
+	for _, backEnd := range backEnds {
+		for _, test := range tests {
+			_, err := EvalString(context.Background(), test.src, NewEnvironment(CreateSystemFuncs()), backEnd.opts)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("%v: %v returned %v, want an error mentioning %q", backEnd.name, test.src, err, test.want)
+			}
+		}
+	}
+}
+
+func TestParallelPoolIsBounded(t *testing.T) {
+	if poolSize(0) != runtime.GOMAXPROCS(0) || poolSize(3) != 3 {
+		t.Errorf("poolSize(0) = %v and poolSize(3) = %v, want GOMAXPROCS and 3", poolSize(0), poolSize(3))
+	}
+	for _, size := range []int{1, 2, 4} {
+		state := newEvalState(context.Background(), EvalOptions{PoolSize: size})
+		var running, most int32
+		calls := make([]func(*evalState) (ListCell, error), 32)
+		for i := range calls {
+			calls[i] = func(*evalState) (ListCell, error) {
+				now := atomic.AddInt32(&running, 1)
+				for {
+					seen := atomic.LoadInt32(&most)
+					if now <= seen || atomic.CompareAndSwapInt32(&most, seen, now) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return makeNilCell(), nil
+			}
+		}
+		if _, err := state.parallel(calls); err != nil {
+			t.Fatal(err)
+		}
+		//The calling goroutine runs a call itself whenever every worker is busy.
+		if most > int32(size)+1 {
+			t.Errorf("%v calls ran at once with %v workers, want at most %v", most, size, size+1)
+		}
+		if err := state.finish(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package Golly
+
+import (
+	"Golly/parser"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Print returns a value written in Golly syntax. Reading the text back, as
+// quoted data, gives a value equal to the one printed, for everything but
+// functions and references such as atoms and channels, which print as
+// #<fn name> or #<Atom> and cannot be read. Symbols and keywords print as
+// their names, which symbol, keyword and gensym only accept if they read
+// back. Infinite floats print as ##Inf and ##-Inf, and NaN as ##NaN, which
+// reads back as NaN but, being NaN, is equal to nothing.
+func Print(value ListCell) string {
+	var text strings.Builder
+	printCell(&text, value, true)
+	return text.String()
+}
+
+// Write writes a value to w as Print does.
+func Write(w io.Writer, value ListCell) error {
+	_, err := io.WriteString(w, Print(value))
+	return err
+}
+
+// displayCell renders a value the way print and display show it: like
+// Print, but with strings and chars as their bare text.
+func displayCell(cell ListCell) string {
+	var text strings.Builder
+	printCell(&text, cell, false)
+	return text.String()
+}
+
+func printCell(text *strings.Builder, cell ListCell, readable bool) {
+	switch val := cell.Value.(type) {
+	case nil:
+		text.WriteString("nil")
+	case bool:
+		text.WriteString(strconv.FormatBool(val))
+	case int:
+		text.WriteString(strconv.Itoa(val))
+	case float64:
+		text.WriteString(Parser.FloatLiteral(val))
+	case string:
+		if readable && cell.TypeName == STRING_TYPE_NAME {
+			text.WriteString(Parser.QuoteString(val))
+		} else {
+			text.WriteString(val)
+		}
+	case rune:
+		if cell.TypeName != CHAR_TYPE_NAME {
+			text.WriteString(strconv.Itoa(int(val)))
+		} else if readable {
+			text.WriteString(Parser.CharLiteral(val))
+		} else {
+			text.WriteRune(val)
+		}
+	case *regexp.Regexp:
+		text.WriteString(Parser.RegexLiteral(val.String()))
+	case *SymbolObj, *KeywordObj:
+		text.WriteString(fmt.Sprint(val))
+	case []ListCell:
+		printSequence(text, "(", val, ")", readable)
+	case *Vector:
+		printSequence(text, "[", val.Slice(), "]", readable)
+	case *HashMap:
+		entries := make([]ListCell, 0, 2*val.Len())
+		val.Each(func(key, value ListCell) {
+			entries = append(entries, key, value)
+		})
+		printSequence(text, "{", entries, "}", readable)
+	case FunctionObj:
+		if val.Name == "" {
+			text.WriteString("#<fn>")
+		} else {
+			text.WriteString("#<fn " + val.Name + ">")
+		}
+	case *Atom, *Ref, *Channel, *Future, *Environment:
+		text.WriteString("#<" + cell.TypeName + ">")
+	default:
+		text.WriteString(fmt.Sprint(val))
+	}
+}
+
+func printSequence(text *strings.Builder, open string, vals []ListCell, close string, readable bool) {
+	text.WriteString(open)
+	for i, val := range vals {
+		if i > 0 {
+			text.WriteByte(' ')
+		}
+		printCell(text, val, readable)
+	}
+	text.WriteString(close)
+}
+
+// GoDisplay writes a value to the SysEnvironment's Stdout as print shows
+// it, and GoWrite as Print writes it, neither followed by a newline.
+func GoDisplay(funcType goFuncType, Cell1 *ListCell, env *Environment) ([]*ListCell, error) {
+	text := displayCell(*Cell1)
+	if funcType == GoWriteT {
+		text = Print(*Cell1)
+	}
+	if err := env.System.write(text); err != nil {
+		return nil, err
+	}
+	returnVal := makeNilCell()
+	return []*ListCell{&returnVal}, nil
+}
@@ -0,0 +1,83 @@
+package Golly
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPrintReadsBack(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"(+ 1 2)", "3"},
+		{"(/ 1.0 4.0)", "0.25"},
+		{"(* 2.0 3.0)", "6.0"},
+		{"(/ 1.0 0.0)", "##Inf"},
+		{"(/ -1.0 0.0)", "##-Inf"},
+		{"(- (/ 1.0 0.0) (/ 1.0 0.0))", "##NaN"},
+		{`"say \"hi\"\n"`, `"say \"hi\"\n"`},
+		{`\space`, `\space`},
+		{":key", ":key"},
+		{"'sym", "sym"},
+		{`(symbol "a-b?")`, "a-b?"},
+		{`(symbol "let")`, "let"},
+		{`(keyword "k2")`, ":k2"},
+		{"(list 1 [2.5 \"three\"] {:four \\4})", `(1 [2.5 "three"] {:four \4})`},
+	}
+	for _, test := range tests {
+		env := NewEnvironment(CreateSystemFuncs())
+		value, err := EvalString(context.Background(), test.src, env, EvalOptions{})
+		if err != nil {
+			t.Errorf("%v returned %v", test.src, err)
+			continue
+		}
+		text := Print(value)
+		if text != test.want {
+			t.Errorf("Print of %v = %v, want %v", test.src, text, test.want)
+		}
+		read, err := EvalString(context.Background(), "'"+text, env, EvalOptions{})
+		if err != nil {
+			t.Errorf("reading %v back returned %v", text, err)
+			continue
+		}
+		if Print(read) != text {
+			t.Errorf("%v read back as %v", text, Print(read))
+		}
+		if f, ok := value.Value.(float64); !ok || !math.IsNaN(f) {
+			if !cellsEqual(&value, &read) {
+				t.Errorf("%v read back as a value not equal to it", text)
+			}
+		}
+	}
+}
+
+func TestUnreadableNamesAreRefused(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{`(symbol "1")`, "symbol expects a name that reads back"},
+		{`(symbol ":x")`, "symbol expects a name that reads back"},
+		{`(symbol "")`, "symbol expects a name that reads back"},
+		{`(symbol "a b")`, "symbol expects a name that reads back"},
+		{`(symbol "nil")`, "symbol expects a name that reads back"},
+		{`(keyword "a b")`, "keyword expects a name that reads back"},
+		{`(keyword "")`, "keyword expects a name that reads back"},
+		{`(keyword "a)")`, "keyword expects a name that reads back"},
+		{`(gensym "1")`, "gensym expects a prefix"},
+	}
+	for _, test := range tests {
+		env := NewEnvironment(CreateSystemFuncs())
+		result, err := EvalString(context.Background(), test.src, env, EvalOptions{})
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%v returned %v, %v, want an error mentioning %q", test.src, Print(result), err, test.want)
+		}
+	}
+}
+
+func TestSpecialFloatLiterals(t *testing.T) {
+	for _, bytecode := range []bool{false, true} {
+		env := NewEnvironment(CreateSystemFuncs())
+		result, err := EvalString(context.Background(), "(list (< ##-Inf 0.0) (> ##Inf 0.0) (= ##NaN ##NaN))", env, EvalOptions{Bytecode: bytecode})
+		if err != nil || Print(result) != "(true true false)" {
+			t.Errorf("comparing special floats with bytecode %v returned %v, %v", bytecode, Print(result), err)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package Golly
+
+import (
+	"Golly/parser"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Atom is the value of a Golly atom: a single value that swap! and reset!
+// replace atomically.
+type Atom struct {
+	value atomic.Pointer[ListCell]
+}
+
+// Ref is the value of a Golly ref: a value that can only be changed inside
+// a dosync transaction, together with any other refs.
+type Ref struct {
+	id      uint64
+	mu      sync.Mutex
+	value   ListCell
+	version uint64
+}
+
+var lastRefId uint64
+
+// maxTxRetries is how many times a dosync transaction is retried after
+// conflicting with others before giving up.
+const maxTxRetries = 10000
+
+// transaction holds the refs a dosync transaction has read, with the
+// versions it saw, and the values it will write to them when it commits.
+type transaction struct {
+	reads  map[*Ref]ListCell
+	seen   map[*Ref]uint64
+	writes map[*Ref]ListCell
+}
+
+func newTransaction() *transaction {
+	return &transaction{reads: make(map[*Ref]ListCell), seen: make(map[*Ref]uint64), writes: make(map[*Ref]ListCell)}
+}
+
+func (tx *transaction) read(ref *Ref) ListCell {
+	if val, ok := tx.writes[ref]; ok {
+		return val
+	}
+	if val, ok := tx.reads[ref]; ok {
+		return val
+	}
+	ref.mu.Lock()
+	val, version := ref.value, ref.version
+	ref.mu.Unlock()
+	tx.reads[ref] = val
+	tx.seen[ref] = version
+	return val
+}
+
+// valid reports whether no ref the transaction has read has changed since.
+func (tx *transaction) valid() bool {
+	for ref, version := range tx.seen {
+		ref.mu.Lock()
+		current := ref.version
+		ref.mu.Unlock()
+		if current != version {
+			return false
+		}
+	}
+	return true
+}
+
+// commit writes the transaction's values if no ref it read has changed,
+// locking every ref involved in order of creation so that commits cannot
+// deadlock.
+func (tx *transaction) commit() bool {
+	refs := make([]*Ref, 0, len(tx.seen)+len(tx.writes))
+	for ref := range tx.seen {
+		refs = append(refs, ref)
+	}
+	for ref := range tx.writes {
+		if _, ok := tx.seen[ref]; !ok {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].id < refs[j].id
+	})
+	for _, ref := range refs {
+		ref.mu.Lock()
+		defer ref.mu.Unlock()
+	}
+	for ref, version := range tx.seen {
+		if ref.version != version {
+			return false
+		}
+	}
+	for ref, val := range tx.writes {
+		ref.value = val
+		ref.version++
+	}
+	return true
+}
+
+// runTransaction runs body in a transaction on env's state, retrying it
+// whenever another transaction changes a ref it read before it commits. A
+// dosync inside another joins the outer transaction.
+func runTransaction(env *Environment, body func() (ListCell, error)) (ListCell, error) {
+	state := env.evalState()
+	if state == nil {
+		return ListCell{}, errors.New("Error: dosync can only be used during an evaluation started by EvalString, EvalTokens or EvalToken.\n")
+	}
+	if state.tx != nil {
+		return body()
+	}
+	for attempt := 0; attempt <= maxTxRetries; attempt++ {
+		tx := newTransaction()
+		state.tx = tx
+		result, err := func() (result ListCell, err error) {
+			defer recoverEvalError(&err)
+			return body()
+		}()
+		state.tx = nil
+		if err == nil && tx.commit() {
+			return result, nil
+		} else if err != nil && tx.valid() {
+			return ListCell{}, err
+		}
+		state.checkContext()
+	}
+	err := fmt.Sprintf("Error: dosync transaction retried more than %v times.\n", maxTxRetries)
+	return ListCell{}, errors.New(err)
+}
+
+func evalDosync(list *Parser.Token, env *Environment) ListCell {
+	result, err := runTransaction(env, func() (ListCell, error) {
+		return evalBody(list.ListVals[1:], newChildEnvironment(env)), nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func atomArg(name string, cell *ListCell) (*Atom, error) {
+	atom, ok := cell.Value.(*Atom)
+	if !ok || cell.TypeName != ATOM_TYPE_NAME {
+		err := fmt.Sprintf("Error: %v expects an Atom but got %v.\n", name, cell.TypeName)
+		return nil, errors.New(err)
+	}
+	return atom, nil
+}
+
+func refArg(name string, cell *ListCell) (*Ref, error) {
+	ref, ok := cell.Value.(*Ref)
+	if !ok || cell.TypeName != REF_TYPE_NAME {
+		err := fmt.Sprintf("Error: %v expects a Ref but got %v.\n", name, cell.TypeName)
+		return nil, errors.New(err)
+	}
+	return ref, nil
+}
+
+func txArg(name string, env *Environment) (*transaction, error) {
+	state := env.evalState()
+	if state == nil || state.tx == nil {
+		err := fmt.Sprintf("Error: %v can only be used inside dosync.\n", name)
+		return nil, errors.New(err)
+	}
+	return state.tx, nil
+}
+
+// GoAtom makes an atom holding a value.
+func GoAtom(Cell1 *ListCell) ([]*ListCell, error) {
+	atom := &Atom{}
+	val := *Cell1
+	val.Mutable = false
+	atom.value.Store(&val)
+	returnVal := ListCell{TypeName: ATOM_TYPE_NAME, Value: atom}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoSwap replaces the value of an atom with the result of calling a pure
+// function on it and any further arguments. If another swap! or reset!
+// changes the atom first, the function is called again on the new value.
+func GoSwap(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if len(parameters) < 2 {
+		err := fmt.Sprintf("Error: builtin swap! expects an atom and a function but was called with %v arguments.\n", len(parameters))
+		return nil, errors.New(err)
+	}
+	atom, err := atomArg("swap!", &parameters[0])
+	if err != nil {
+		return nil, err
+	}
+	funct, err := pureFuncArg("swap!", &parameters[1])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]ListCell, len(parameters)-1)
+	copy(args[1:], parameters[2:])
+	for {
+		env.evalState().step()
+		old := atom.value.Load()
+		args[0] = *old
+		returnedVals, err := funct.Call(args, env)
+		if err != nil {
+			return nil, err
+		}
+		val := firstReturnVal(returnedVals)
+		val.Mutable = false
+		if atom.value.CompareAndSwap(old, &val) {
+			return []*ListCell{&val}, nil
+		}
+	}
+}
+
+// GoReset sets the value of an atom.
+func GoReset(Cell1 *ListCell, Cell2 *ListCell) ([]*ListCell, error) {
+	atom, err := atomArg("reset!", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	val := *Cell2
+	val.Mutable = false
+	atom.value.Store(&val)
+	return []*ListCell{&val}, nil
+}
+
+// GoRef makes a ref holding a value.
+func GoRef(Cell1 *ListCell) ([]*ListCell, error) {
+	ref := &Ref{id: atomic.AddUint64(&lastRefId, 1), value: *Cell1}
+	ref.value.Mutable = false
+	returnVal := ListCell{TypeName: REF_TYPE_NAME, Value: ref}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoDeref returns the value of an atom or ref. Inside dosync a ref reads as
+// the transaction sees it.
+func GoDeref(Cell1 *ListCell, env *Environment) ([]*ListCell, error) {
+	var returnVal ListCell
+	switch Cell1.TypeName {
+	case ATOM_TYPE_NAME:
+		atom, err := atomArg("deref", Cell1)
+		if err != nil {
+			return nil, err
+		}
+		returnVal = *atom.value.Load()
+	case REF_TYPE_NAME:
+		ref, err := refArg("deref", Cell1)
+		if err != nil {
+			return nil, err
+		}
+		if state := env.evalState(); state != nil && state.tx != nil {
+			returnVal = state.tx.read(ref)
+		} else {
+			ref.mu.Lock()
+			returnVal = ref.value
+			ref.mu.Unlock()
+		}
+	default:
+		err := fmt.Sprintf("Error: deref expects an Atom or a Ref but got %v.\n", Cell1.TypeName)
+		return nil, errors.New(err)
+	}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoRefSet sets the value of a ref when the enclosing dosync commits.
+func GoRefSet(Cell1 *ListCell, Cell2 *ListCell, env *Environment) ([]*ListCell, error) {
+	ref, err := refArg("ref-set", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := txArg("ref-set", env)
+	if err != nil {
+		return nil, err
+	}
+	val := *Cell2
+	val.Mutable = false
+	tx.writes[ref] = val
+	return []*ListCell{&val}, nil
+}
+
+// GoAlter sets the value of a ref, when the enclosing dosync commits, to
+// the result of calling a pure function on its value and any further
+// arguments.
+func GoAlter(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if len(parameters) < 2 {
+		err := fmt.Sprintf("Error: builtin alter expects a ref and a function but was called with %v arguments.\n", len(parameters))
+		return nil, errors.New(err)
+	}
+	ref, err := refArg("alter", &parameters[0])
+	if err != nil {
+		return nil, err
+	}
+	funct, err := pureFuncArg("alter", &parameters[1])
+	if err != nil {
+		return nil, err
+	}
+	tx, err := txArg("alter", env)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]ListCell{tx.read(ref)}, parameters[2:]...)
+	returnedVals, err := funct.Call(args, env)
+	if err != nil {
+		return nil, err
+	}
+	val := firstReturnVal(returnedVals)
+	val.Mutable = false
+	tx.writes[ref] = val
+	return []*ListCell{&val}, nil
+}
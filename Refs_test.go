@@ -0,0 +1,86 @@
+package Golly
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRefTransactions(t *testing.T) {
+	tests := []struct {
+		src  string
+		want int
+	}{
+		{"(let (r (ref 1)) (dosync (ref-set r 2) (+ (deref r) (* 100 (dosync (alter r (fn (x) (* x 10))))))))", 2002},
+		{"(let (r (ref 1)) (do (dosync (ref-set r 5)) (deref r)))", 5},
+		{"(let (a (atom 1)) (do (reset! a 9) (deref a)))", 9},
+		{"(let (a (atom 1)) (do (swap! a (fn (x) (+ x 2))) (deref a)))", 3},
+	}
+	for _, backEnd := range backEnds {
+		for _, test := range tests {
+			env := NewEnvironment(CreateSystemFuncs())
+			result, err := EvalString(context.Background(), test.src, env, backEnd.opts)
+			if err != nil || result.Value != test.want {
+				t.Errorf("%v: %v returned %v, %v, want %v", backEnd.name, test.src, result.Value, err, test.want)
+			}
+		}
+	}
+}
+
+// TestConcurrentTransactions starts goroutines that transfer between two
+// refs and count with an atom, and checks, once the evaluation that started
+// them has waited for them, that no update was lost however often they
+// retried.
+func TestConcurrentTransactions(t *testing.T) {
+	setup := `(def (a (ref 1000)))
+(def (b (ref 0)))
+(def (c (atom 0)))
+(defn transfer (n) (dosync (alter a (fn (x) (- x n))) (alter b (fn (x) (+ x n)))))
+(defn work () (do (transfer 1) (swap! c (fn (x) (+ x 1)))))
+(defn spawn (n) (if (= n 0) 0 (do (go work) (spawn (- n 1)))))`
+	for _, backEnd := range backEnds {
+		env := NewEnvironment(CreateSystemFuncs())
+		if _, err := EvalString(context.Background(), setup, env, backEnd.opts); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := EvalString(context.Background(), "(spawn 100)", env, backEnd.opts); err != nil {
+			t.Fatal(err)
+		}
+		for name, want := range map[string]int{"a": 900, "b": 100, "c": 100} {
+			result, err := EvalString(context.Background(), "(deref "+name+")", env, backEnd.opts)
+			if err != nil || result.Value != want {
+				t.Errorf("%v: after the transfers %v holds %v, %v, want %v", backEnd.name, name, result.Value, err, want)
+			}
+		}
+	}
+}
+
+func TestFailedTransactionWritesNothing(t *testing.T) {
+	for _, backEnd := range backEnds {
+		env := NewEnvironment(CreateSystemFuncs())
+		_, err := EvalString(context.Background(), "(def (r (ref 1)))\n(dosync (ref-set r 2) (/ 1 0))", env, backEnd.opts)
+		if err == nil {
+			t.Errorf("%v: a failing transaction returned no error", backEnd.name)
+		}
+		result, err := EvalString(context.Background(), "(deref r)", env, backEnd.opts)
+		if err != nil || result.Value != 1 {
+			t.Errorf("%v: after a failed transaction the ref holds %v, %v, want 1", backEnd.name, result.Value, err)
+		}
+	}
+}
+
+func TestRefErrors(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"(let (r (ref 1)) (ref-set r 2))", "dosync"},
+		{"(let (r (ref 1)) (dosync (alter r (fn (x) (do (println x) x)))))", "pure"},
+	}
+	for _, backEnd := range backEnds {
+		for _, test := range tests {
+			env := NewEnvironment(CreateSystemFuncs())
+			_, err := EvalString(context.Background(), test.src, env, backEnd.opts)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("%v: %v returned %v, want an error mentioning %q", backEnd.name, test.src, err, test.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package Golly
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// regexCache holds the compiled form of every pattern compiled so far, so
+// that a regex literal or string pattern in a loop is compiled only once.
+var regexCache sync.Map
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		errMsg := fmt.Sprintf("Error: invalid regex %q; %v.\n", pattern, err)
+		return nil, errors.New(errMsg)
+	}
+	regexCache.Store(pattern, re)
+	return re, nil
+}
+
+func makeRegexCell(re *regexp.Regexp) ListCell {
+	return ListCell{TypeName: REGEX_TYPE_NAME, Value: re}
+}
+
+// regexArg returns the Regex a builtin was passed, compiling it first if it
+// was passed as a string.
+func regexArg(name string, cell *ListCell) (*regexp.Regexp, error) {
+	switch val := cell.Value.(type) {
+	case *regexp.Regexp:
+		return val, nil
+	case string:
+		if cell.TypeName == STRING_TYPE_NAME {
+			return compileRegex(val)
+		}
+	}
+	err := fmt.Sprintf("Error: %v expects a Regex or a string but got %v.\n", name, cell.TypeName)
+	return nil, errors.New(err)
+}
+
+// matchCell makes the value of a match from the offsets regexp reports for
+// it: the matched string if the pattern has no capture groups, otherwise a
+// list of the matched string followed by each group, with nil for a group
+// that took no part in the match.
+func matchCell(str string, offsets []int, env *Environment) ListCell {
+	if len(offsets) == 2 {
+		return makeStringCell(str[offsets[0]:offsets[1]], env)
+	}
+	vals := make([]ListCell, len(offsets)/2)
+	for i := range vals {
+		start, end := offsets[2*i], offsets[2*i+1]
+		if start < 0 {
+			vals[i] = makeNilCell()
+		} else {
+			vals[i] = makeStringCell(str[start:end], env)
+		}
+	}
+	return newList(vals, env)
+}
+
+func regexAndString(name string, Cell1 *ListCell, Cell2 *ListCell) (*regexp.Regexp, string, error) {
+	re, err := regexArg(name, Cell1)
+	if err != nil {
+		return nil, "", err
+	}
+	str, err := stringArg(name, Cell2)
+	if err != nil {
+		return nil, "", err
+	}
+	return re, str, nil
+}
+
+// GoReCompile compiles a pattern in Go's regexp syntax.
+func GoReCompile(Cell1 *ListCell) ([]*ListCell, error) {
+	pattern, err := stringArg("re-compile", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	re, err := compileRegex(pattern)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := makeRegexCell(re)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoReMatch returns the first match of a regex in a string, or nil if there
+// is none.
+func GoReMatch(Cell1 *ListCell, Cell2 *ListCell, env *Environment) ([]*ListCell, error) {
+	re, str, err := regexAndString("re-match", Cell1, Cell2)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := makeNilCell()
+	if offsets := re.FindStringSubmatchIndex(str); offsets != nil {
+		returnVal = matchCell(str, offsets, env)
+	}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoReFindAll returns the list of every match of a regex in a string, in
+// the form re-match returns each.
+func GoReFindAll(Cell1 *ListCell, Cell2 *ListCell, env *Environment) ([]*ListCell, error) {
+	re, str, err := regexAndString("re-find-all", Cell1, Cell2)
+	if err != nil {
+		return nil, err
+	}
+	matches := re.FindAllStringSubmatchIndex(str, -1)
+	vals := make([]ListCell, len(matches))
+	for i, offsets := range matches {
+		vals[i] = matchCell(str, offsets, env)
+	}
+	returnVal := newList(vals, env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoReReplace replaces every match of a regex in a string. In the
+// replacement, $1 or ${name} stands for the text of a capture group.
+func GoReReplace(Cell1 *ListCell, Cell2 *ListCell, Cell3 *ListCell, env *Environment) ([]*ListCell, error) {
+	re, str, err := regexAndString("re-replace", Cell1, Cell2)
+	if err != nil {
+		return nil, err
+	}
+	replacement, err := stringArg("re-replace", Cell3)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := makeStringCell(re.ReplaceAllString(str, replacement), env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoReSplit returns the list of the parts of a string between matches of a
+// regex.
+func GoReSplit(Cell1 *ListCell, Cell2 *ListCell, env *Environment) ([]*ListCell, error) {
+	re, str, err := regexAndString("re-split", Cell1, Cell2)
+	if err != nil {
+		return nil, err
+	}
+	parts := re.Split(str, -1)
+	vals := make([]ListCell, len(parts))
+	for i, part := range parts {
+		vals[i] = makeStringCell(part, env)
+	}
+	returnVal := newList(vals, env)
+	return []*ListCell{&returnVal}, nil
+}
@@ -0,0 +1,67 @@
+package Golly
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegexBuiltins(t *testing.T) {
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{`(re-match #"a+b" "xaaabz")`, "aaab"},
+		{`(re-match #"\d+" "ab123")`, "123"},
+		{`(re-match #"say \"hi\"" "they say \"hi\"")`, `say "hi"`},
+		{`(re-match #"\\" "a\\b")`, `\`},
+		{`(re-match #"x" "abc")`, nil},
+		{`(re-match #"(\w+)@(\w+)?" "me@")`, []interface{}{"me@", "me", nil}},
+		{`(re-find-all #"\d" "a1b2")`, []interface{}{"1", "2"}},
+		{`(re-find-all #"(\w)=(\d)" "a=1 b=2")`, []interface{}{[]interface{}{"a=1", "a", "1"}, []interface{}{"b=2", "b", "2"}}},
+		{`(re-replace #"(?P<y>\d{4})-(\d\d)" "2024-05" "$2/${y}")`, "05/2024"},
+		{`(re-split #",\s*" "a, b,c")`, []interface{}{"a", "b", "c"}},
+		{`(re-match "b+" "abbc")`, "bb"},
+		{`(re-match (re-compile "^[a-z]+$") "abc")`, "abc"},
+	}
+	for _, backEnd := range backEnds {
+		for _, test := range tests {
+			env := NewEnvironment(CreateSystemFuncs())
+			result, err := EvalString(context.Background(), test.src, env, backEnd.opts)
+			if err != nil || !reflect.DeepEqual(goValue(result), test.want) {
+				t.Errorf("%v: %v returned %#v, %v, want %#v", backEnd.name, test.src, goValue(result), err, test.want)
+			}
+		}
+	}
+}
+
+func TestRegexLiteralIsARegex(t *testing.T) {
+	for _, src := range []string{`#"a+"`, `(re-compile "a+")`} {
+		env := NewEnvironment(CreateSystemFuncs())
+		result, err := EvalString(context.Background(), src, env, EvalOptions{})
+		if err != nil || result.TypeName != REGEX_TYPE_NAME {
+			t.Errorf("%v returned a %v, %v, want a Regex", src, result.TypeName, err)
+		}
+	}
+}
+
+func TestRegexErrors(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"(+ 1 2)\n(re-match #\"a(\" \"a\")", "malformed regex literal at line 2"},
+		{`(re-match #"abc`, "unterminated string starting at line 1"},
+		{`(re-compile "a(")`, "invalid regex"},
+		{`(re-match "[" "x")`, "invalid regex"},
+		{`(re-match 1 "x")`, "re-match expects a Regex or a string"},
+		{`(re-split #"," 5)`, "re-split"},
+	}
+	for _, backEnd := range backEnds {
+		for _, test := range tests {
+			env := NewEnvironment(CreateSystemFuncs())
+			_, err := EvalString(context.Background(), test.src, env, backEnd.opts)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("%v: %v returned %v, want an error mentioning %q", backEnd.name, test.src, err, test.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package Golly
+
+import (
+	"Golly/parser"
+	"fmt"
+)
+
+// UnboundVarError reports an identifier that is not bound in any enclosing
+// scope, among the globals or in the SysEnvironment.
+type UnboundVarError struct {
+	Name    string
+	LineNum int
+}
+
+func (err *UnboundVarError) Error() string {
+	return fmt.Sprintf("Error: var %v at line %v is unbound.\n", err.Name, err.LineNum)
+}
+
+type resolveScope struct {
+	names  []string
+	parent *resolveScope
+}
+
+func (scope *resolveScope) slotOf(name string) int {
+	for i := len(scope.names) - 1; i >= 0; i-- {
+		if scope.names[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (scope *resolveScope) declare(name string) int {
+	if slot := scope.slotOf(name); slot >= 0 {
+		return slot
+	}
+	scope.names = append(scope.names, name)
+	return len(scope.names) - 1
+}
+
+type resolver struct {
+	env     *Environment
+	globals map[string]bool
+	scope   *resolveScope
+	errs    []error
+}
+
+// Resolve annotates every identifier in a parsed top-level list with its
+// lexical address, so the evaluator can find local bindings by (depth,
+// index) rather than by name. Scopes mirror the environments the evaluator
+// creates: one per let and one per function call. Identifiers that cannot
+// be bound at all are returned as UnboundVarErrors.
+func Resolve(tokens *Parser.Token, env *Environment) []error {
+	res := newResolver(env)
+	for i := range tokens.ListVals {
+		res.collectGlobals(&tokens.ListVals[i])
+	}
+	for i := range tokens.ListVals {
+		res.resolveToken(&tokens.ListVals[i])
+	}
+	return res.errs
+}
+
+func resolveForm(tok *Parser.Token, env *Environment) []error {
+	res := newResolver(env)
+	res.collectGlobals(tok)
+	res.resolveToken(tok)
+	return res.errs
+}
+
+func newResolver(env *Environment) *resolver {
+	res := resolver{env: env, globals: make(map[string]bool)}
+	if env == nil {
+		return &res
+	}
+	//Locals of a non-root starting environment become the outermost scopes, so their addresses line up at run time.
+	var envScopes []*Environment
+	for scopeEnv := env; scopeEnv.Parent != nil; scopeEnv = scopeEnv.Parent {
+		envScopes = append(envScopes, scopeEnv)
+	}
+	for i := len(envScopes) - 1; i >= 0; i-- {
+		scope := resolveScope{parent: res.scope}
+		for _, binding := range envScopes[i].Bindings {
+			scope.names = append(scope.names, binding.Name)
+		}
+		res.scope = &scope
+	}
+	for _, binding := range env.Globals() {
+		res.globals[binding.Name] = true
+	}
+	return &res
+}
+
+func (res *resolver) collectGlobals(tok *Parser.Token) {
+	if tok.Type != Parser.ListToken || len(tok.ListVals) == 0 || isQuoteForm(tok) {
+		return
+	}
+	firstVal := &tok.ListVals[0]
+	if firstVal.Type == Parser.DefToken && (firstVal.Value == "def" || firstVal.Value == "defm") && len(tok.ListVals) > 1 {
+		forEachBinding(&tok.ListVals[1], func(nameTok, typeTok, valueTok *Parser.Token) {
+			res.globals[nameTok.Value] = true
+		})
+	} else if firstVal.Type == Parser.FormToken && (firstVal.Value == "defn" || firstVal.Value == "defn-memo") && len(tok.ListVals) > 1 {
+		res.globals[tok.ListVals[1].Value] = true
+	}
+	for i := range tok.ListVals {
+		res.collectGlobals(&tok.ListVals[i])
+	}
+}
+
+// forEachBinding walks the name/value pairs of a let or def binding list,
+// skipping over type annotations. Malformed lists are left for the
+// evaluator to report.
+func forEachBinding(list *Parser.Token, visit func(nameTok, typeTok, valueTok *Parser.Token)) {
+	if list.Type != Parser.ListToken {
+		return
+	}
+	bindings := list.ListVals
+	for i := 0; i+1 < len(bindings); i += 2 {
+		if bindings[i+1].Type == Parser.TypeAnnToken {
+			if i+3 >= len(bindings) {
+				return
+			}
+			visit(&bindings[i], &bindings[i+2], &bindings[i+3])
+			i += 2
+		} else {
+			visit(&bindings[i], nil, &bindings[i+1])
+		}
+	}
+}
+
+func (res *resolver) systemBinding(name string) bool {
+	if res.env == nil || res.env.System == nil {
+		return false
+	}
+	_, ok := res.env.System.Bindings[name]
+	return ok
+}
+
+func (res *resolver) resolveIdentifier(tok *Parser.Token) {
+	if res.systemBinding(tok.Value) {
+		tok.Scope = Parser.SystemScope
+		return
+	}
+	depth := 0
+	for scope := res.scope; scope != nil; scope = scope.parent {
+		if slot := scope.slotOf(tok.Value); slot >= 0 {
+			tok.Scope, tok.Depth, tok.Index = Parser.LocalScope, depth, slot
+			return
+		}
+		depth++
+	}
+	if res.globals[tok.Value] {
+		tok.Scope = Parser.GlobalScope
+		return
+	}
+	tok.Scope = Parser.Unresolved
+	if res.env != nil && res.env.System != nil {
+		if capability, ok := res.env.System.Denied[tok.Value]; ok {
+			res.errs = append(res.errs, &PermissionError{Name: tok.Value, Capability: capability, LineNum: tok.LineNum})
+			return
+		}
+	}
+	res.errs = append(res.errs, &UnboundVarError{Name: tok.Value, LineNum: tok.LineNum})
+}
+
+func (res *resolver) resolveToken(tok *Parser.Token) {
+	switch tok.Type {
+	case Parser.IdToken:
+		res.resolveIdentifier(tok)
+	case Parser.ListToken:
+		res.resolveList(tok)
+	case Parser.VectorToken, Parser.MapToken:
+		res.resolveBody(tok.ListVals)
+	}
+}
+
+func (res *resolver) resolveBody(body []Parser.Token) {
+	for i := range body {
+		res.resolveToken(&body[i])
+	}
+}
+
+func (res *resolver) resolveList(list *Parser.Token) {
+	if len(list.ListVals) == 0 {
+		return
+	}
+	firstVal := &list.ListVals[0]
+	switch firstVal.Type {
+	case Parser.DefToken:
+		if len(list.ListVals) < 2 {
+			return
+		}
+		if firstVal.Value == "def" || firstVal.Value == "defm" {
+			forEachBinding(&list.ListVals[1], func(nameTok, typeTok, valueTok *Parser.Token) {
+				if typeTok != nil {
+					res.resolveToken(typeTok)
+				}
+				res.resolveToken(valueTok)
+				nameTok.Scope = Parser.GlobalScope
+			})
+			res.resolveBody(list.ListVals[2:])
+			return
+		}
+		res.scope = &resolveScope{parent: res.scope}
+		forEachBinding(&list.ListVals[1], func(nameTok, typeTok, valueTok *Parser.Token) {
+			if typeTok != nil {
+				res.resolveToken(typeTok)
+			}
+			if isFnForm(valueTok) {
+				res.scope.declare(nameTok.Value)
+			}
+			res.resolveToken(valueTok)
+			nameTok.Scope, nameTok.Depth, nameTok.Index = Parser.LocalScope, 0, res.scope.declare(nameTok.Value)
+		})
+		res.resolveBody(list.ListVals[2:])
+		res.scope = res.scope.parent
+	case Parser.FormToken:
+		switch firstVal.Value {
+		case "fn":
+			if len(list.ListVals) > 1 {
+				res.resolveFn(&list.ListVals[1], list.ListVals[2:])
+			}
+		case "defn", "defn-memo":
+			if len(list.ListVals) > 2 {
+				list.ListVals[1].Scope = Parser.GlobalScope
+				res.resolveFn(&list.ListVals[2], list.ListVals[3:])
+			}
+		case "select":
+			for i := 1; i < len(list.ListVals); i++ {
+				res.resolveSelectClause(&list.ListVals[i])
+			}
+		case "dosync":
+			//The body runs as a function of no arguments, so that it can
+			//be retried.
+			res.resolveFn(&Parser.Token{Type: Parser.ListToken}, list.ListVals[1:])
+		case "quote":
+			//Quoted code is data, so its identifiers are left alone.
+		default:
+			res.resolveBody(list.ListVals[1:])
+		}
+	default:
+		res.resolveBody(list.ListVals)
+	}
+}
+
+// resolveSelectClause resolves one clause of a select. The name a recv
+// clause binds gets a scope of its own around the clause's body.
+func (res *resolver) resolveSelectClause(clause *Parser.Token) {
+	if clause.Type != Parser.ListToken || len(clause.ListVals) == 0 || clause.ListVals[0].Type != Parser.IdToken {
+		res.resolveToken(clause)
+		return
+	}
+	switch clause.ListVals[0].Value {
+	case "recv":
+		if len(clause.ListVals) < 3 {
+			res.resolveBody(clause.ListVals[1:])
+			return
+		}
+		res.resolveToken(&clause.ListVals[1])
+		nameTok := &clause.ListVals[2]
+		scope := resolveScope{parent: res.scope, names: []string{nameTok.Value}}
+		nameTok.Scope, nameTok.Depth, nameTok.Index = Parser.LocalScope, 0, 0
+		res.scope = &scope
+		res.resolveBody(clause.ListVals[3:])
+		res.scope = scope.parent
+	case "send", "default":
+		res.resolveBody(clause.ListVals[1:])
+	default:
+		res.resolveToken(clause)
+	}
+}
+
+func (res *resolver) resolveFn(params *Parser.Token, body []Parser.Token) {
+	scope := resolveScope{parent: res.scope}
+	for i := range params.ListVals {
+		param := &params.ListVals[i]
+		param.Scope, param.Depth, param.Index = Parser.LocalScope, 0, len(scope.names)
+		scope.names = append(scope.names, param.Value)
+	}
+	res.scope = &scope
+	res.resolveBody(body)
+	res.scope = scope.parent
+}
@@ -0,0 +1,118 @@
+package Golly
+
+import (
+	"Golly/parser"
+	"context"
+	"testing"
+)
+
+// resolved parses src and resolves it against a fresh environment.
+func resolved(t *testing.T, src string, env *Environment) (Parser.Token, []error) {
+	t.Helper()
+	tree, err := Parse(src)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", src, err)
+	}
+	errs := Resolve(&tree, env)
+	return tree, errs
+}
+
+// findIdent returns the nth identifier called name in tok, counting from 0
+// in the order they are written.
+func findIdent(tok *Parser.Token, name string, nth int) *Parser.Token {
+	var found *Parser.Token
+	var walk func(tok *Parser.Token)
+	walk = func(tok *Parser.Token) {
+		if found != nil {
+			return
+		}
+		if tok.Type == Parser.IdToken && tok.Value == name {
+			if nth == 0 {
+				found = tok
+				return
+			}
+			nth--
+		}
+		for i := range tok.ListVals {
+			walk(&tok.ListVals[i])
+		}
+	}
+	walk(tok)
+	return found
+}
+
+func TestResolveAddresses(t *testing.T) {
+	src := "(def (g 1))\n(defn f (a b) (let (c a) (fn (d) (+ a b c d g))))"
+	tree, errs := resolved(t, src, NewEnvironment(CreateSystemFuncs()))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	tests := []struct {
+		name         string
+		nth          int
+		scope        int
+		depth, index int
+	}{
+		//The uses inside the innermost fn.
+		{"a", 2, int(Parser.LocalScope), 2, 0},
+		{"b", 1, int(Parser.LocalScope), 2, 1},
+		{"c", 1, int(Parser.LocalScope), 1, 0},
+		{"d", 1, int(Parser.LocalScope), 0, 0},
+		{"g", 1, int(Parser.GlobalScope), 0, 0},
+		{"+", 0, int(Parser.SystemScope), 0, 0},
+	}
+	for _, test := range tests {
+		tok := findIdent(&tree, test.name, test.nth)
+		if tok == nil {
+			t.Fatalf("no %v number %v in the tree", test.name, test.nth)
+		}
+		if int(tok.Scope) != test.scope || tok.Scope == Parser.LocalScope && (tok.Depth != test.depth || tok.Index != test.index) {
+			t.Errorf("%v resolved to scope %v at %v, %v, want scope %v at %v, %v", test.name, tok.Scope, tok.Depth, tok.Index, test.scope, test.depth, test.index)
+		}
+	}
+}
+
+func TestResolveGlobalsDefinedLater(t *testing.T) {
+	_, errs := resolved(t, "(defn f () (g))\n(defn g () 1)", NewEnvironment(CreateSystemFuncs()))
+	if len(errs) > 0 {
+		t.Errorf("a call to a global defined later returned %v", errs)
+	}
+}
+
+func TestResolveUnbound(t *testing.T) {
+	_, errs := resolved(t, "(let (x 1) (+ x y))\n(+ x 1)", NewEnvironment(CreateSystemFuncs()))
+	if len(errs) != 2 {
+		t.Fatalf("Resolve returned %v, want 2 errors", errs)
+	}
+	if err, ok := errs[0].(*UnboundVarError); !ok || err.Name != "y" || err.LineNum != 1 {
+		t.Errorf("the first error is %v, want y unbound at line 1", errs[0])
+	}
+	if err, ok := errs[1].(*UnboundVarError); !ok || err.Name != "x" || err.LineNum != 2 {
+		t.Errorf("the second error is %v, want x unbound at line 2", errs[1])
+	}
+}
+
+func TestResolveShadowing(t *testing.T) {
+	src := "(def (x 1))\n(defn f (x) (let (x (+ x 10)) (let (y x) (+ x y))))\n(+ (f 5) (* x 100))"
+	for _, backEnd := range backEnds {
+		env := NewEnvironment(CreateSystemFuncs())
+		result, err := EvalString(context.Background(), src, env, backEnd.opts)
+		if err != nil || result.Value != 130 {
+			t.Errorf("%v: shadowing returned %v, %v, want 130", backEnd.name, result.Value, err)
+		}
+	}
+}
+
+func TestResolveDenied(t *testing.T) {
+	sys, err := NewSysEnvironment(CoreCapability)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, errs := resolved(t, "(let (x 1) (+ x y))\n(println x)", NewEnvironment(sys))
+	if len(errs) != 3 {
+		t.Fatalf("Resolve returned %v, want 3 errors", errs)
+	}
+	if err, ok := errs[1].(*PermissionError); !ok || err.Name != "println" || err.Capability != IOCapability || err.LineNum != 2 {
+		t.Errorf("the second error is %v, want println denied at line 2", errs[1])
+	}
+}
@@ -0,0 +1,267 @@
+package Golly
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// runeIndex converts a byte offset in str to a count of runes.
+func runeIndex(str string, offset int) int {
+	if offset < 0 {
+		return offset
+	}
+	return utf8.RuneCountInString(str[:offset])
+}
+
+// GoStr returns its arguments displayed as print shows them, run together.
+func GoStr(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	var str strings.Builder
+	for _, param := range parameters {
+		str.WriteString(displayCell(param))
+	}
+	returnVal := makeStringCell(str.String(), env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoConcat joins strings end to end. Unlike str, it accepts only strings.
+func GoConcat(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	var str strings.Builder
+	for i := range parameters {
+		part, err := stringArg("concat", &parameters[i])
+		if err != nil {
+			return nil, err
+		}
+		str.WriteString(part)
+	}
+	returnVal := makeStringCell(str.String(), env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoSubstring returns the characters of a string from start up to but not
+// including end, which defaults to the end of the string. Both count
+// characters rather than bytes.
+func GoSubstring(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if len(parameters) != 2 && len(parameters) != 3 {
+		err := fmt.Sprintf("Error: builtin substring expects a string, a start and an optional end but was called with %v arguments.\n", len(parameters))
+		return nil, errors.New(err)
+	}
+	str, err := stringArg("substring", &parameters[0])
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(str)
+	start, err := indexArg("substring", &parameters[1])
+	if err != nil {
+		return nil, err
+	}
+	end := len(runes)
+	if len(parameters) == 3 {
+		end, err = indexArg("substring", &parameters[2])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if start < 0 || end > len(runes) || start > end {
+		err := fmt.Sprintf("Error: substring range %v to %v is out of range for a string of %v characters.\n", start, end, len(runes))
+		return nil, errors.New(err)
+	}
+	returnVal := makeStringCell(string(runes[start:end]), env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoSplit returns the list of the parts of a string between occurrences of
+// a separator. An empty separator splits the string into its characters.
+func GoSplit(Cell1 *ListCell, Cell2 *ListCell, env *Environment) ([]*ListCell, error) {
+	str, err := stringArg("split", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	sep, err := stringArg("split", Cell2)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(str, sep)
+	vals := make([]ListCell, len(parts))
+	for i, part := range parts {
+		vals[i] = makeStringCell(part, env)
+	}
+	returnVal := newList(vals, env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoJoin displays the elements of a List or Vector as print shows them and
+// returns them run together, with a separator between them if one is
+// given first.
+func GoJoin(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if len(parameters) != 1 && len(parameters) != 2 {
+		err := fmt.Sprintf("Error: builtin join expects an optional separator and a List but was called with %v arguments.\n", len(parameters))
+		return nil, errors.New(err)
+	}
+	sep := ""
+	if len(parameters) == 2 {
+		var err error
+		sep, err = stringArg("join", &parameters[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	seq := &parameters[len(parameters)-1]
+	var vals []ListCell
+	if vector, ok := seq.Value.(*Vector); ok {
+		vals = vector.Slice()
+	} else {
+		var err error
+		vals, err = listArg("join", seq)
+		if err != nil {
+			return nil, err
+		}
+	}
+	items := make([]string, len(vals))
+	state := env.evalState()
+	for i, val := range vals {
+		state.poll(i)
+		items[i] = displayCell(val)
+	}
+	returnVal := makeStringCell(strings.Join(items, sep), env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoStringCase implements upper, lower and trim, which use the Unicode
+// definitions of case and white space.
+func GoStringCase(funcType goFuncType, Cell1 *ListCell, env *Environment) ([]*ListCell, error) {
+	str, err := stringArg(goFuncNames[funcType], Cell1)
+	if err != nil {
+		return nil, err
+	}
+	switch funcType {
+	case GoUpperT:
+		str = strings.ToUpper(str)
+	case GoLowerT:
+		str = strings.ToLower(str)
+	case GoTrimT:
+		str = strings.TrimSpace(str)
+	default:
+		err := fmt.Sprintf("Error: attempting to transform a string with unhandled builtin of type number %v.\n", funcType)
+		return nil, errors.New(err)
+	}
+	returnVal := makeStringCell(str, env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoIndexOf returns the position, in characters, of the first occurrence
+// of a substring in a string, or -1 if there is none.
+func GoIndexOf(Cell1 *ListCell, Cell2 *ListCell) ([]*ListCell, error) {
+	str, err := stringArg("index-of", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := stringArg("index-of", Cell2)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := ListCell{TypeName: INT_TYPE_NAME, Value: runeIndex(str, strings.Index(str, sub))}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoReplace returns a string with every occurrence of old replaced by new.
+func GoReplace(Cell1 *ListCell, Cell2 *ListCell, Cell3 *ListCell, env *Environment) ([]*ListCell, error) {
+	str, err := stringArg("replace", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	old, err := stringArg("replace", Cell2)
+	if err != nil {
+		return nil, err
+	}
+	replacement, err := stringArg("replace", Cell3)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := makeStringCell(strings.ReplaceAll(str, old, replacement), env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoFormat formats its arguments printf-style, as Go's fmt package does.
+// ints, floats, bools and strings are passed as they are; other values are
+// passed displayed as print shows them.
+func GoFormat(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if len(parameters) == 0 {
+		return nil, errors.New("Error: builtin format expects a format string.\n")
+	}
+	format, err := stringArg("format", &parameters[0])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, len(parameters)-1)
+	for i, param := range parameters[1:] {
+		switch param.Value.(type) {
+		case int, float64, bool, string:
+			args[i] = param.Value
+		default:
+			args[i] = displayCell(param)
+		}
+	}
+	str := fmt.Sprintf(format, args...)
+	if strings.Contains(str, "%!") && !strings.Contains(format, "%!") {
+		err := fmt.Sprintf("Error: format string %q does not match its %v arguments; got %q.\n", format, len(args), str)
+		return nil, errors.New(err)
+	}
+	returnVal := makeStringCell(str, env)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoStringToNumber parses a string as an int or, failing that, a float. It
+// returns nil if the string is neither.
+func GoStringToNumber(Cell1 *ListCell) ([]*ListCell, error) {
+	str, err := stringArg("string->number", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := makeNilCell()
+	if intval, err := strconv.Atoi(str); err == nil {
+		returnVal = ListCell{TypeName: INT_TYPE_NAME, Value: intval}
+	} else if floatval, err := strconv.ParseFloat(str, 64); err == nil {
+		returnVal = ListCell{TypeName: FLOAT_TYPE_NAME, Value: floatval}
+	}
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoNumberToString formats an int, in the given radix if there is one, or a
+// float. Whole floats keep a trailing .0 so that string->number reads them
+// back as floats.
+func GoNumberToString(parameters []ListCell, env *Environment) ([]*ListCell, error) {
+	if len(parameters) != 1 && len(parameters) != 2 {
+		err := fmt.Sprintf("Error: builtin number->string expects a number and an optional radix but was called with %v arguments.\n", len(parameters))
+		return nil, errors.New(err)
+	}
+	var str string
+	switch val := parameters[0].Value.(type) {
+	case int:
+		radix := 10
+		if len(parameters) == 2 {
+			var ok bool
+			radix, ok = parameters[1].Value.(int)
+			if !ok || radix < 2 || radix > 36 {
+				err := fmt.Sprintf("Error: number->string expects a radix from 2 to 36 but got %v.\n", displayCell(parameters[1]))
+				return nil, errors.New(err)
+			}
+		}
+		str = strconv.FormatInt(int64(val), radix)
+	case float64:
+		if len(parameters) == 2 {
+			return nil, errors.New("Error: number->string only takes a radix for ints.\n")
+		}
+		str = strconv.FormatFloat(val, 'g', -1, 64)
+		if !strings.ContainsAny(str, ".eIN") {
+			str += ".0"
+		}
+	default:
+		err := fmt.Sprintf("Error: number->string expects an int or a float but got %v.\n", parameters[0].TypeName)
+		return nil, errors.New(err)
+	}
+	returnVal := makeStringCell(str, env)
+	return []*ListCell{&returnVal}, nil
+}
@@ -0,0 +1,280 @@
+package Golly
+
+import (
+	"Golly/parser"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"weak"
+)
+
+// SymbolObj is the value of a Golly symbol: a name, as quoted code holds it.
+// Symbols are interned, so two symbols with the same name are the same
+// *SymbolObj and compare equal, except for those made by gensym.
+type SymbolObj struct {
+	name string
+}
+
+// KeywordObj is the value of a Golly keyword, written :name. Keywords evaluate
+// to themselves and are interned like symbols, which makes them cheap map
+// keys.
+type KeywordObj struct {
+	name string
+}
+
+var (
+	symbols    internTable[SymbolObj]
+	keywords   internTable[KeywordObj]
+	lastGensym uint64
+)
+
+// internTable maps names to the symbols or keywords interned under them. It
+// holds them weakly, so that one that no value refers to any more is
+// collected and its entry removed, and a long-running host making symbols
+// from data does not keep every name it has seen.
+type internTable[T any] struct {
+	entries sync.Map
+}
+
+// intern returns the value interned under name, calling create to make it
+// if there is none.
+func (table *internTable[T]) intern(name string, create func() *T) *T {
+	for {
+		entry, ok := table.entries.Load(name)
+		if ok {
+			if val := entry.(weak.Pointer[T]).Value(); val != nil {
+				return val
+			}
+		}
+		val := create()
+		ptr := weak.Make(val)
+		if ok && !table.entries.CompareAndSwap(name, entry, ptr) {
+			continue
+		} else if !ok {
+			if _, loaded := table.entries.LoadOrStore(name, ptr); loaded {
+				continue
+			}
+		}
+		runtime.AddCleanup(val, func(ptr weak.Pointer[T]) {
+			table.entries.CompareAndDelete(name, ptr)
+		}, ptr)
+		return val
+	}
+}
+
+// Intern returns the symbol with a name.
+func Intern(name string) *SymbolObj {
+	return symbols.intern(name, func() *SymbolObj {
+		return &SymbolObj{name: name}
+	})
+}
+
+// InternKeyword returns the keyword with a name, given without its colon.
+func InternKeyword(name string) *KeywordObj {
+	return keywords.intern(name, func() *KeywordObj {
+		return &KeywordObj{name: name}
+	})
+}
+
+func (sym *SymbolObj) Name() string {
+	return sym.name
+}
+
+func (sym *SymbolObj) String() string {
+	return sym.name
+}
+
+func (kw *KeywordObj) Name() string {
+	return kw.name
+}
+
+func (kw *KeywordObj) String() string {
+	return ":" + kw.name
+}
+
+func makeSymbolCell(sym *SymbolObj) ListCell {
+	return ListCell{TypeName: SYMBOL_TYPE_NAME, Value: sym}
+}
+
+func makeKeywordCell(kw *KeywordObj) ListCell {
+	return ListCell{TypeName: KEYWORD_TYPE_NAME, Value: kw}
+}
+
+func isQuoteForm(tok *Parser.Token) bool {
+	return tok.Type == Parser.ListToken && len(tok.ListVals) > 0 && tok.ListVals[0].Type == Parser.FormToken && tok.ListVals[0].Value == "quote"
+}
+
+// quoteToken returns the code of a token as data: identifiers and reserved
+// words become symbols, literals their values, and lists, vectors and maps
+// the same collections of quoted elements. true, false and nil stay the
+// values they name.
+func quoteToken(tok *Parser.Token, env *Environment) ListCell {
+	caller := "quote"
+	switch tok.Type {
+	case Parser.LiteralToken:
+		return evalLitToken(tok, tok.LineNum, &caller)
+	case Parser.IdToken:
+		switch tok.Value {
+		case "true", "false":
+			return makeBoolCell(tok.Value == "true")
+		case "nil":
+			return makeNilCell()
+		}
+		return makeSymbolCell(Intern(tok.Value))
+	case Parser.DefToken, Parser.FormToken, Parser.TypeAnnToken:
+		return makeSymbolCell(Intern(tok.Value))
+	case Parser.ListToken, Parser.VectorToken, Parser.MapToken:
+		vals := make([]ListCell, len(tok.ListVals))
+		for i := range tok.ListVals {
+			vals[i] = quoteToken(&tok.ListVals[i], env)
+		}
+		if tok.Type == Parser.VectorToken {
+			return makeVectorCell(vals, env)
+		} else if tok.Type == Parser.MapToken {
+			hashMap, err := makeMapCell(vals, env)
+			if err != nil {
+				errMsg := fmt.Sprintf("%v at line %v.\n", strings.TrimSuffix(err.Error(), ".\n"), tok.LineNum)
+				panic(errMsg)
+			}
+			return hashMap
+		}
+		return newList(vals, env)
+	default:
+		errMsg := fmt.Sprintf("Error: cannot quote %v at line %v.\n", tok.Value, tok.LineNum)
+		panic(errMsg)
+	}
+}
+
+func evalQuote(list *Parser.Token, env *Environment) ListCell {
+	if len(list.ListVals) != 2 {
+		errMsg := fmt.Sprintf("Error: quote at line %v expects one form to quote.\n", list.ListVals[0].LineNum)
+		panic(errMsg)
+	}
+	return quoteToken(&list.ListVals[1], env)
+}
+
+// nameArg returns the name of a string, symbol or keyword.
+func nameArg(name string, cell *ListCell) (string, error) {
+	switch val := cell.Value.(type) {
+	case string:
+		if cell.TypeName == STRING_TYPE_NAME {
+			return val, nil
+		}
+	case *SymbolObj:
+		return val.name, nil
+	case *KeywordObj:
+		return val.name, nil
+	}
+	err := fmt.Sprintf("Error: %v expects a string, a Symbol or a Keyword but got %v.\n", name, cell.TypeName)
+	return "", errors.New(err)
+}
+
+// readsAsOneForm parses text and returns its form, if it is exactly one.
+func readsAsOneForm(text string) (Parser.Token, bool) {
+	tokens, err := Parse(text)
+	if err != nil || len(tokens.ListVals) != 1 {
+		return Parser.Token{}, false
+	}
+	return tokens.ListVals[0], true
+}
+
+// readableSymbol reports whether a symbol's name, as Print writes it, reads
+// back as quoted code to the same symbol: it must be a single identifier or
+// reserved word, and not true, false or nil, which read as what they name.
+func readableSymbol(name string) bool {
+	tok, ok := readsAsOneForm(name)
+	if !ok || tok.Value != name {
+		return false
+	}
+	switch tok.Type {
+	case Parser.IdToken:
+		return name != "true" && name != "false" && name != "nil"
+	case Parser.DefToken, Parser.FormToken, Parser.TypeAnnToken:
+		return true
+	}
+	return false
+}
+
+// readableKeyword reports whether a keyword's name, written after a colon,
+// reads back as the same keyword.
+func readableKeyword(name string) bool {
+	tok, ok := readsAsOneForm(":" + name)
+	return ok && tok.Type == Parser.LiteralToken && tok.LitType == Parser.Keyword && tok.Value == name
+}
+
+// GoSymbol returns the symbol with a name. Its name counts against the
+// limits as a string would, as symbols made from data can be as large. A
+// name that would not read back as the symbol, such as "1" or "a b", is an
+// error, so that every symbol prints as text that reads back.
+func GoSymbol(Cell1 *ListCell, env *Environment) ([]*ListCell, error) {
+	name, err := nameArg("symbol", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	if !readableSymbol(name) {
+		err := fmt.Sprintf("Error: symbol expects a name that reads back as a Symbol but got %v.\n", Parser.QuoteString(name))
+		return nil, errors.New(err)
+	}
+	env.evalState().allocString(len(name))
+	returnVal := makeSymbolCell(Intern(name))
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoKeyword returns the keyword with a name, counted against the limits
+// and checked as GoSymbol counts and checks a symbol's.
+func GoKeyword(Cell1 *ListCell, env *Environment) ([]*ListCell, error) {
+	name, err := nameArg("keyword", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	if !readableKeyword(name) {
+		err := fmt.Sprintf("Error: keyword expects a name that reads back as a Keyword but got %v.\n", Parser.QuoteString(name))
+		return nil, errors.New(err)
+	}
+	env.evalState().allocString(len(name))
+	returnVal := makeKeywordCell(InternKeyword(name))
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoGensym returns a new symbol, named with an optional prefix and a
+// counter, that is equal to no other symbol, even one with the same name.
+func GoGensym(parameters []ListCell) ([]*ListCell, error) {
+	if len(parameters) > 1 {
+		err := fmt.Sprintf("Error: builtin gensym expects an optional prefix but was called with %v arguments.\n", len(parameters))
+		return nil, errors.New(err)
+	}
+	prefix := "G__"
+	if len(parameters) == 1 {
+		var err error
+		prefix, err = nameArg("gensym", &parameters[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	sym := &SymbolObj{name: prefix + strconv.FormatUint(atomic.AddUint64(&lastGensym, 1), 10)}
+	if !readableSymbol(sym.name) {
+		err := fmt.Sprintf("Error: gensym expects a prefix that makes names that read back as a Symbol but got %v.\n", Parser.QuoteString(prefix))
+		return nil, errors.New(err)
+	}
+	returnVal := makeSymbolCell(sym)
+	return []*ListCell{&returnVal}, nil
+}
+
+// GoSymbolToString returns the name of a symbol, or of a keyword without
+// its colon.
+func GoSymbolToString(Cell1 *ListCell, env *Environment) ([]*ListCell, error) {
+	if Cell1.TypeName == STRING_TYPE_NAME {
+		err := fmt.Sprintf("Error: symbol->string expects a Symbol or a Keyword but got %v.\n", Cell1.TypeName)
+		return nil, errors.New(err)
+	}
+	name, err := nameArg("symbol->string", Cell1)
+	if err != nil {
+		return nil, err
+	}
+	returnVal := makeStringCell(name, env)
+	return []*ListCell{&returnVal}, nil
+}
@@ -0,0 +1,64 @@
+package Golly
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestInternedSymbolsAreShared(t *testing.T) {
+	if Intern("shared") != Intern("shared") {
+		t.Error("interning a name twice returned two symbols")
+	}
+	if InternKeyword("shared") != InternKeyword("shared") {
+		t.Error("interning a keyword twice returned two keywords")
+	}
+	env := NewEnvironment(CreateSystemFuncs())
+	result, err := EvalString(context.Background(), `(= (symbol "a") (symbol (symbol->string 'a)))`, env, EvalOptions{})
+	if err != nil || result.Value != true {
+		t.Errorf("symbols with the same name compared %v, %v", result.Value, err)
+	}
+}
+
+func countEntries(table *internTable[SymbolObj], prefix string) int {
+	count := 0
+	table.entries.Range(func(key, _ interface{}) bool {
+		if len(key.(string)) > len(prefix) && key.(string)[:len(prefix)] == prefix {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+func TestUnreferencedSymbolsAreCollected(t *testing.T) {
+	const n = 1000
+	for i := 0; i < n; i++ {
+		Intern("collected-" + strconv.Itoa(i))
+	}
+	kept := Intern("collected-kept")
+	deadline := time.Now().Add(5 * time.Second)
+	for countEntries(&symbols, "collected-") > 1 && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	if count := countEntries(&symbols, "collected-"); count != 1 {
+		t.Errorf("%v symbols are still interned after collection, want 1", count)
+	}
+	if Intern("collected-kept") != kept {
+		t.Error("a symbol still referred to was interned again")
+	}
+}
+
+func TestSymbolsCountAgainstLimits(t *testing.T) {
+	env := NewEnvironment(CreateSystemFuncs())
+	src := `(map (fn (n) (symbol (concat "s" (number->string n)))) (range 0 1000))`
+	_, err := EvalString(context.Background(), src, env, EvalOptions{Limits: Limits{MaxCells: 4500}})
+	var limitErr *LimitExceeded
+	if !errors.As(err, &limitErr) || limitErr.Limit != CellLimit {
+		t.Errorf("making symbols past the cell limit returned %v", err)
+	}
+}
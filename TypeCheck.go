@@ -0,0 +1,233 @@
+package Golly
+
+import (
+	"Golly/parser"
+	"fmt"
+)
+
+// TypeError reports a type annotation that a binding's value cannot
+// satisfy, or one that names something other than a type.
+type TypeError struct {
+	Name string
+	// Type is the annotated type, and Actual the type of the value, or ""
+	// if Type is not a type at all.
+	Type    string
+	Actual  string
+	LineNum int
+}
+
+func (err *TypeError) Error() string {
+	if err.Actual == "" {
+		return fmt.Sprintf("Error: %v is annotated with %v at line %v, which is not a type.\n", err.Name, err.Type, err.LineNum)
+	}
+	return fmt.Sprintf("Error: %v is annotated as %v at line %v, but its value is of type %v.\n", err.Name, err.Type, err.LineNum, err.Actual)
+}
+
+// StaticType returns the type name the value of a token will have, if that
+// is known without evaluating it: for literals, vectors, maps, fn forms,
+// quoted forms, true, false and nil. Otherwise it returns "".
+func StaticType(tok *Parser.Token) string {
+	switch tok.Type {
+	case Parser.LiteralToken:
+		switch tok.LitType {
+		case Parser.FixNum:
+			return INT_TYPE_NAME
+		case Parser.FloNum:
+			return FLOAT_TYPE_NAME
+		case Parser.String:
+			return STRING_TYPE_NAME
+		case Parser.Char:
+			return CHAR_TYPE_NAME
+		case Parser.Keyword:
+			return KEYWORD_TYPE_NAME
+		case Parser.Regex:
+			return REGEX_TYPE_NAME
+		}
+	case Parser.IdToken:
+		switch tok.Value {
+		case "true", "false":
+			return BOOL_TYPE_NAME
+		case "nil":
+			return NIL_TYPE_NAME
+		}
+	case Parser.VectorToken:
+		return VECTOR_TYPE_NAME
+	case Parser.MapToken:
+		return MAP_TYPE_NAME
+	case Parser.ListToken:
+		if isFnForm(tok) {
+			return FUNCTION_TYPE_NAME
+		}
+		if isQuoteForm(tok) && len(tok.ListVals) == 2 {
+			return quotedType(&tok.ListVals[1])
+		}
+	}
+	return ""
+}
+
+// quotedType returns the type name of the data quoting a token gives, as
+// quoteToken makes it.
+func quotedType(tok *Parser.Token) string {
+	switch tok.Type {
+	case Parser.LiteralToken, Parser.VectorToken, Parser.MapToken:
+		return StaticType(tok)
+	case Parser.IdToken:
+		if typeName := StaticType(tok); typeName != "" {
+			return typeName
+		}
+		return SYMBOL_TYPE_NAME
+	case Parser.DefToken, Parser.FormToken, Parser.TypeAnnToken:
+		return SYMBOL_TYPE_NAME
+	case Parser.ListToken:
+		return LIST_TYPE_NAME
+	}
+	return ""
+}
+
+// ArityError reports a call, found without evaluating it, that passes a
+// builtin or a defn the wrong number of arguments.
+type ArityError struct {
+	Name     string
+	Expected int
+	Actual   int
+	LineNum  int
+}
+
+func (err *ArityError) Error() string {
+	return fmt.Sprintf("Error: %v expects %v arguments but is called with %v at line %v.\n", err.Name, err.Expected, err.Actual, err.LineNum)
+}
+
+// CheckTypes checks the type annotations and calls in tokens without
+// evaluating anything, so that tools can report mistakes before a program
+// runs. An annotation naming a builtin that is not a type is an error, as
+// is one on a binding whose StaticType differs from it. Annotations naming
+// globals, or bindings whose values are only known once evaluated, are
+// left to be checked at run time.
+//
+// A call passing a builtin of fixed arity the wrong number of arguments is
+// an ArityError, as is one passing a function the wrong number if tokens
+// define it once, with defn, defn-memo or def of a fn form, and Resolve has
+// found that the call refers to that global.
+func CheckTypes(tokens *Parser.Token, env *Environment) []error {
+	var errs []error
+	arities := definedArities(tokens, env)
+	checkCall := func(list *Parser.Token) {
+		head := &list.ListVals[0]
+		args := len(list.ListVals) - 1
+		if env != nil && env.System != nil {
+			if binding, ok := env.System.Bindings[head.Value]; ok {
+				funct, isFunc := binding.Binding.Value.(FunctionObj)
+				arity, fixed := goFuncArities[funct.FuncType]
+				if isFunc && funct.GoFunc && fixed && arity != args {
+					errs = append(errs, &ArityError{Name: head.Value, Expected: arity, Actual: args, LineNum: head.LineNum})
+				}
+				return
+			}
+		}
+		if arity, ok := arities[head.Value]; ok && head.Scope == Parser.GlobalScope && arity != args {
+			errs = append(errs, &ArityError{Name: head.Value, Expected: arity, Actual: args, LineNum: head.LineNum})
+		}
+	}
+	var check func(tok *Parser.Token)
+	checkAll := func(toks []Parser.Token) {
+		for i := range toks {
+			check(&toks[i])
+		}
+	}
+	check = func(tok *Parser.Token) {
+		if tok.Type != Parser.ListToken && tok.Type != Parser.VectorToken && tok.Type != Parser.MapToken {
+			return
+		}
+		if isQuoteForm(tok) {
+			return
+		}
+		if tok.Type != Parser.ListToken || len(tok.ListVals) == 0 {
+			checkAll(tok.ListVals)
+			return
+		}
+		head := &tok.ListVals[0]
+		switch {
+		case head.Type == Parser.DefToken && len(tok.ListVals) > 1:
+			forEachBinding(&tok.ListVals[1], func(nameTok, typeTok, valueTok *Parser.Token) {
+				check(valueTok)
+				if typeTok == nil || typeTok.Type != Parser.IdToken || env == nil || env.System == nil {
+					return
+				}
+				typeBinding, ok := env.System.Bindings[typeTok.Value]
+				if !ok {
+					return
+				}
+				if typeBinding.Binding.TypeName != TYPE_TYPE_NAME {
+					errs = append(errs, &TypeError{Name: nameTok.Value, Type: typeTok.Value, LineNum: typeTok.LineNum})
+				} else if actual := StaticType(valueTok); actual != "" && actual != typeTok.Value {
+					errs = append(errs, &TypeError{Name: nameTok.Value, Type: typeTok.Value, Actual: actual, LineNum: nameTok.LineNum})
+				}
+			})
+			checkAll(tok.ListVals[2:])
+		case head.Type == Parser.FormToken && head.Value == "fn" && len(tok.ListVals) > 1:
+			checkAll(tok.ListVals[2:])
+		case head.Type == Parser.FormToken && (head.Value == "defn" || head.Value == "defn-memo") && len(tok.ListVals) > 2:
+			checkAll(tok.ListVals[3:])
+		case head.Type == Parser.FormToken && head.Value == "select":
+			//The heads of select clauses are its own keywords rather than calls.
+			for i := 1; i < len(tok.ListVals); i++ {
+				if clause := &tok.ListVals[i]; clause.Type == Parser.ListToken && len(clause.ListVals) > 0 {
+					checkAll(clause.ListVals[1:])
+				}
+			}
+		case head.Type == Parser.IdToken:
+			checkCall(tok)
+			checkAll(tok.ListVals)
+		default:
+			checkAll(tok.ListVals)
+		}
+	}
+	checkAll(tokens.ListVals)
+	return errs
+}
+
+// definedArities returns the number of parameters of each function tokens
+// define exactly once, with defn, defn-memo or def of a fn form, and that
+// is not already a global of env. A name defined more than once, or also
+// with defm, could be bound to something else when a call runs.
+func definedArities(tokens *Parser.Token, env *Environment) map[string]int {
+	definitions := make(map[string]int)
+	arities := make(map[string]int)
+	var collect func(tok *Parser.Token)
+	collect = func(tok *Parser.Token) {
+		if tok.Type != Parser.ListToken || len(tok.ListVals) == 0 || isQuoteForm(tok) {
+			return
+		}
+		head := &tok.ListVals[0]
+		if head.Type == Parser.DefToken && (head.Value == "def" || head.Value == "defm") && len(tok.ListVals) > 1 {
+			forEachBinding(&tok.ListVals[1], func(nameTok, typeTok, valueTok *Parser.Token) {
+				definitions[nameTok.Value]++
+				if head.Value == "def" && isFnForm(valueTok) && len(valueTok.ListVals) > 1 && valueTok.ListVals[1].Type == Parser.ListToken {
+					arities[nameTok.Value] = len(valueTok.ListVals[1].ListVals)
+				}
+			})
+		} else if head.Type == Parser.FormToken && (head.Value == "defn" || head.Value == "defn-memo") && len(tok.ListVals) > 2 {
+			definitions[tok.ListVals[1].Value]++
+			if tok.ListVals[2].Type == Parser.ListToken {
+				arities[tok.ListVals[1].Value] = len(tok.ListVals[2].ListVals)
+			}
+		}
+		for i := range tok.ListVals {
+			collect(&tok.ListVals[i])
+		}
+	}
+	for i := range tokens.ListVals {
+		collect(&tokens.ListVals[i])
+	}
+	if env != nil {
+		for _, binding := range env.Globals() {
+			definitions[binding.Name]++
+		}
+	}
+	for name := range arities {
+		if definitions[name] != 1 {
+			delete(arities, name)
+		}
+	}
+	return arities
+}
@@ -0,0 +1,62 @@
+package Golly
+
+import (
+	"Golly/parser"
+	"testing"
+)
+
+// checkSource parses and resolves src, as the language server does, and
+// returns what CheckTypes finds in it.
+func checkSource(t *testing.T, src string) []error {
+	t.Helper()
+	tree, parseErrs := Parser.ParseRecovering(src)
+	if len(parseErrs) > 0 {
+		t.Fatalf("parsing %q: %v", src, parseErrs[0])
+	}
+	env := NewEnvironment(CreateSystemFuncs())
+	Resolve(&tree, env)
+	return CheckTypes(&tree, env)
+}
+
+func TestCheckTypesAnnotations(t *testing.T) {
+	errs := checkSource(t, "(def (s : int \"no\"))\n(def (n : car 1))\n(def (ok : int 1))")
+	if len(errs) != 2 {
+		t.Fatalf("CheckTypes returned %v, want 2 errors", errs)
+	}
+	if err, ok := errs[0].(*TypeError); !ok || err.Name != "s" || err.Actual != STRING_TYPE_NAME {
+		t.Errorf("the first error is %v, want s annotated as int", errs[0])
+	}
+	if err, ok := errs[1].(*TypeError); !ok || err.Name != "n" || err.Actual != "" {
+		t.Errorf("the second error is %v, want car not being a type", errs[1])
+	}
+}
+
+func TestCheckTypesArities(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []ArityError
+	}{
+		{"(car 1 2)", []ArityError{{Name: "car", Expected: 1, Actual: 2, LineNum: 1}}},
+		{"(list 1 2 3)\n(+ 1 2)", nil},
+		{"(defn f (a b) (+ a b))\n(f 1)", []ArityError{{Name: "f", Expected: 2, Actual: 1, LineNum: 2}}},
+		{"(def (g (fn (a) a)))\n(g)\n(g 1)", []ArityError{{Name: "g", Expected: 1, Actual: 0, LineNum: 2}}},
+		{"(defn f (a) a)\n(defn f (a b) a)\n(f 1)", nil},
+		{"(defn f (a) a)\n(let (f (fn (a b) a)) (f 1 2))", nil},
+		{"(defn f (car) car)\n(let (cdr 1) cdr)", nil},
+		{"(def (ch (chan 1)))\n(select (recv ch x x) (send ch 1 2) (default (cdr 1 2)))", []ArityError{{Name: "cdr", Expected: 1, Actual: 2, LineNum: 2}}},
+		{"'(car 1 2)", nil},
+		{"[(car)]", []ArityError{{Name: "car", Expected: 1, Actual: 0, LineNum: 1}}},
+	}
+	for _, test := range tests {
+		errs := checkSource(t, test.src)
+		if len(errs) != len(test.want) {
+			t.Errorf("CheckTypes of %q returned %v, want %v", test.src, errs, test.want)
+			continue
+		}
+		for i, err := range errs {
+			if arityErr, ok := err.(*ArityError); !ok || *arityErr != test.want[i] {
+				t.Errorf("CheckTypes of %q returned %v, want %v", test.src, err, test.want[i])
+			}
+		}
+	}
+}
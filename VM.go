@@ -0,0 +1,346 @@
+package Golly
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// vmScope holds the slots of one let scope or function call. Closures keep
+// a pointer to the scope they were created in, so scopes live on the heap
+// rather than the value stack.
+type vmScope struct {
+	slots  []ListCell
+	parent *vmScope
+}
+
+type vmFrame struct {
+	proto *funcProto
+	ip    int
+	scope *vmScope
+	env   *Environment
+}
+
+func (frame *vmFrame) readOperand() int {
+	operand := int(frame.proto.code[frame.ip])<<8 | int(frame.proto.code[frame.ip+1])
+	frame.ip += 2
+	return operand
+}
+
+// line returns the source line of the instruction being executed.
+func (frame *vmFrame) line() int {
+	if frame.ip > 0 && frame.ip <= len(frame.proto.lines) {
+		return frame.proto.lines[frame.ip-1]
+	}
+	return 0
+}
+
+// errorf reports an error in the words the tree-walker uses for the same
+// failure, so that both back ends give the same messages.
+func (frame *vmFrame) errorf(format string, args ...interface{}) error {
+	return errors.New(fmt.Sprintf(format, args...))
+}
+
+// vmScopeFromEnv copies the locals of a non-root starting environment into
+// scopes laid out the way the resolver numbered them.
+func vmScopeFromEnv(env *Environment) *vmScope {
+	if env == nil || env.Parent == nil {
+		return nil
+	}
+	slots := make([]ListCell, len(env.Bindings))
+	for i, binding := range env.Bindings {
+		slots[i] = binding.Binding
+	}
+	return &vmScope{slots: slots, parent: vmScopeFromEnv(env.Parent)}
+}
+
+func runClosure(aFunc *FunctionObj, params []ListCell, state *evalState) (ListCell, error) {
+	if len(params) != len(aFunc.proto.params) {
+		err := fmt.Sprintf("Error: function %v expects %v arguments but was called with %v.\n", aFunc.Name, len(aFunc.proto.params), len(params))
+		return ListCell{}, errors.New(err)
+	}
+	state.enter()
+	defer state.leave()
+	state.alloc(len(params))
+	slots := make([]ListCell, len(params))
+	copy(slots, params)
+	return runProto(aFunc.proto, &vmScope{slots: slots, parent: aFunc.scope}, aFunc.Env, state)
+}
+
+// runProto executes compiled code until its outermost frame returns. Calls
+// between compiled closures push frames onto the same loop instead of
+// recursing through Go. Every instruction counts as a step of state.
+func runProto(proto *funcProto, scope *vmScope, env *Environment, state *evalState) (result ListCell, err error) {
+	defer recoverEvalError(&err)
+	stack := make([]ListCell, 0, 32)
+	frames := make([]vmFrame, 1, 8)
+	frames[0] = vmFrame{proto: proto, scope: scope, env: env}
+	//Each frame after the first entered a call, which an error must leave
+	//as opReturn would have.
+	defer func() {
+		for i := 1; i < len(frames); i++ {
+			state.leave()
+		}
+	}()
+	for {
+		state.step()
+		frame := &frames[len(frames)-1]
+		op := opCode(frame.proto.code[frame.ip])
+		frame.ip++
+		switch op {
+		case opConst:
+			stack = append(stack, frame.proto.consts[frame.readOperand()])
+		case opPop:
+			stack = stack[:len(stack)-1]
+		case opLoadLocal:
+			depth := frame.readOperand()
+			slot := frame.readOperand()
+			target := frame.scope
+			for ; depth > 0; depth-- {
+				target = target.parent
+			}
+			if target.slots[slot].TypeName == "" {
+				return ListCell{}, frame.errorf("Error: attempting to evaluate a var before it is bound at line %v.\n", frame.line())
+			}
+			stack = append(stack, target.slots[slot])
+		case opStoreLocal:
+			depth := frame.readOperand()
+			slot := frame.readOperand()
+			target := frame.scope
+			for ; depth > 0; depth-- {
+				target = target.parent
+			}
+			mut := frame.readOperand() == 1
+			target.slots[slot] = stack[len(stack)-1]
+			target.slots[slot].Mutable = mut
+			stack = stack[:len(stack)-1]
+		case opLoadGlobal:
+			name := frame.proto.consts[frame.readOperand()].Value.(string)
+			binding, ok := frame.env.loadGlobal(name)
+			if !ok {
+				return ListCell{}, frame.errorf("Error: attempting to evalute var %v in eval at line %v, but that var is unbound.\n", name, frame.line())
+			}
+			stack = append(stack, binding.Binding)
+		case opDefGlobal:
+			name := frame.proto.consts[frame.readOperand()].Value.(string)
+			mut := frame.readOperand() == 1
+			newValue := stack[len(stack)-1]
+			newValue.Mutable = mut
+			if aFunc, ok := newValue.Value.(FunctionObj); ok && aFunc.Name == "" {
+				aFunc.Name = name
+				newValue.Value = aFunc
+			}
+			if !frame.env.setGlobal(name, newValue) {
+				defKind := "def"
+				if mut {
+					defKind = "defm"
+				}
+				return ListCell{}, frame.errorf("Error: attempting to assign to an immutable identifier %v in %v at line %v.\n", name, defKind, frame.line())
+			}
+			stack[len(stack)-1] = newValue
+		case opCheckType:
+			typeName := frame.proto.consts[frame.readOperand()].Value.(string)
+			name := frame.proto.consts[frame.readOperand()].Value.(string)
+			defKind := frame.proto.consts[frame.readOperand()].Value.(string)
+			if stack[len(stack)-1].TypeName != typeName {
+				return ListCell{}, frame.errorf("Error: attempting to assign type %v to %v in %v at line %v, but it is already of type %v.\n", typeName, name, defKind, frame.line(), stack[len(stack)-1].TypeName)
+			}
+		case opJump:
+			frame.ip = frame.readOperand()
+		case opJumpIfFalse:
+			target := frame.readOperand()
+			cond, ok := stack[len(stack)-1].Value.(bool)
+			if !ok {
+				return ListCell{}, frame.errorf("Error: expected bool as condition to if at line %v but got %v.\n", frame.line(), stack[len(stack)-1].TypeName)
+			}
+			stack = stack[:len(stack)-1]
+			if !cond {
+				frame.ip = target
+			}
+		case opPushScope:
+			slotCount := frame.readOperand()
+			state.alloc(slotCount)
+			frame.scope = &vmScope{slots: make([]ListCell, slotCount), parent: frame.scope}
+		case opPopScope:
+			frame.scope = frame.scope.parent
+		case opClosure:
+			fnProto := frame.proto.protos[frame.readOperand()]
+			newFunc := FunctionObj{Name: fnProto.name, Parems: fnProto.params, Pure: fnProto.pure, Env: frame.env, proto: fnProto, scope: frame.scope}
+			if fnProto.memo {
+				newFunc.memo = newMemoTable()
+			}
+			stack = append(stack, ListCell{TypeName: FUNCTION_TYPE_NAME, Value: newFunc})
+		case opCall:
+			argc := frame.readOperand()
+			calleeName := frame.proto.consts[frame.readOperand()].Value.(string)
+			fnCell := &stack[len(stack)-argc-1]
+			funct, ok := fnCell.Value.(FunctionObj)
+			if !ok {
+				return ListCell{}, frame.errorf("Error: attempting to call %v at line %v, but it is a %v rather than a function.\n", calleeName, frame.line(), fnCell.TypeName)
+			}
+			if funct.proto != nil && funct.memo == nil {
+				if argc != len(funct.proto.params) {
+					return ListCell{}, frame.errorf("Error: function %v expects %v arguments but was called with %v.\n", funct.Name, len(funct.proto.params), argc)
+				}
+				state.enter()
+				state.alloc(argc)
+				slots := make([]ListCell, argc)
+				copy(slots, stack[len(stack)-argc:])
+				stack = stack[:len(stack)-argc-1]
+				frames = append(frames, vmFrame{proto: funct.proto, scope: &vmScope{slots: slots, parent: funct.scope}, env: funct.Env})
+			} else {
+				params := make([]ListCell, argc)
+				copy(params, stack[len(stack)-argc:])
+				stack = stack[:len(stack)-argc-1]
+				returnedVals, err := funct.Call(params, frame.env.withState(state))
+				if err != nil {
+					return ListCell{}, err
+				}
+				stack = append(stack, firstReturnVal(returnedVals))
+			}
+		case opReturn:
+			result := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			frames = frames[:len(frames)-1]
+			if len(frames) == 0 {
+				return result, nil
+			}
+			state.leave()
+			stack = append(stack, result)
+		case opAdd, opSubtract, opMultiply, opDivide, opEqual, opLess, opGreater, opLessEq, opGreaterEq:
+			result, err := vmBinaryOp(op, &stack[len(stack)-2], &stack[len(stack)-1])
+			if err != nil {
+				return ListCell{}, err
+			}
+			stack = stack[:len(stack)-1]
+			stack[len(stack)-1] = result
+		case opSelect:
+			clauses := make([]selectClause, frame.readOperand())
+			pushed := 0
+			for i := range clauses {
+				clauses[i].kind = selectKind(frame.readOperand())
+				if clauses[i].kind == selectRecv {
+					pushed++
+				} else if clauses[i].kind == selectSend {
+					pushed += 2
+				}
+			}
+			targets := make([]int, len(clauses))
+			for i := range targets {
+				targets[i] = frame.readOperand()
+			}
+			args := stack[len(stack)-pushed:]
+			for i := range clauses {
+				if clauses[i].kind == selectDefault {
+					continue
+				}
+				ch, err := channelArg("select", &args[0])
+				if err != nil {
+					return ListCell{}, err
+				}
+				clauses[i].ch = ch
+				if clauses[i].kind == selectSend {
+					clauses[i].value = args[1]
+					args = args[1:]
+				}
+				args = args[1:]
+			}
+			stack = stack[:len(stack)-pushed]
+			chosen, received, err := runSelect(clauses, frame.line(), frame.env.withState(state))
+			if err != nil {
+				return ListCell{}, err
+			}
+			if clauses[chosen].kind == selectRecv {
+				received.Mutable = false
+				stack = append(stack, received)
+			}
+			frame.ip = targets[chosen]
+		case opVector, opMap:
+			count := frame.readOperand()
+			vals := make([]ListCell, count)
+			copy(vals, stack[len(stack)-count:])
+			stack = stack[:len(stack)-count]
+			for i := range vals {
+				vals[i].Mutable = false
+			}
+			collEnv := frame.env.withState(state)
+			if op == opVector {
+				stack = append(stack, makeVectorCell(vals, collEnv))
+				break
+			}
+			hashMap, err := makeMapCell(vals, collEnv)
+			if err != nil {
+				return ListCell{}, frame.errorf("%v at line %v.\n", strings.TrimSuffix(err.Error(), ".\n"), frame.line())
+			}
+			stack = append(stack, hashMap)
+		case opDosync:
+			funct := stack[len(stack)-1].Value.(FunctionObj)
+			stack = stack[:len(stack)-1]
+			txEnv := frame.env.withState(state)
+			result, err := runTransaction(txEnv, func() (ListCell, error) {
+				returnedVals, err := funct.Call(nil, txEnv)
+				return firstReturnVal(returnedVals), err
+			})
+			if err != nil {
+				return ListCell{}, err
+			}
+			stack = append(stack, result)
+		default:
+			return ListCell{}, frame.errorf("Error: unhandled opcode %v at line %v.\n", op, frame.line())
+		}
+	}
+}
+
+// vmBinaryOp handles the arithmetic and comparison opcodes. Two ints of
+// the same type name, the common case, are handled without going through
+// the builtin's chain of type assertions.
+func vmBinaryOp(op opCode, Cell1 *ListCell, Cell2 *ListCell) (ListCell, error) {
+	if val1, ok := Cell1.Value.(int); ok && Cell1.TypeName == Cell2.TypeName {
+		if val2, ok := Cell2.Value.(int); ok {
+			switch op {
+			case opAdd:
+				return ListCell{TypeName: Cell1.TypeName, Value: val1 + val2, Mutable: Cell1.Mutable}, nil
+			case opSubtract:
+				return ListCell{TypeName: Cell1.TypeName, Value: val1 - val2, Mutable: Cell1.Mutable}, nil
+			case opMultiply:
+				return ListCell{TypeName: Cell1.TypeName, Value: val1 * val2, Mutable: Cell1.Mutable}, nil
+			case opEqual:
+				return makeBoolCell(val1 == val2), nil
+			case opLess:
+				return makeBoolCell(val1 < val2), nil
+			case opGreater:
+				return makeBoolCell(val1 > val2), nil
+			case opLessEq:
+				return makeBoolCell(val1 <= val2), nil
+			case opGreaterEq:
+				return makeBoolCell(val1 >= val2), nil
+			}
+		}
+	}
+	var returnedVals []*ListCell
+	var err error
+	switch op {
+	case opAdd:
+		returnedVals, err = GoAdd(Cell1, Cell2)
+	case opSubtract:
+		returnedVals, err = GoSubtract(Cell1, Cell2)
+	case opMultiply:
+		returnedVals, err = GoMultiply(Cell1, Cell2)
+	case opDivide:
+		returnedVals, err = GoDivide(Cell1, Cell2)
+	case opEqual:
+		returnedVals, err = GoCompare(GoEqualT, Cell1, Cell2)
+	case opLess:
+		returnedVals, err = GoCompare(GoLessT, Cell1, Cell2)
+	case opGreater:
+		returnedVals, err = GoCompare(GoGreaterT, Cell1, Cell2)
+	case opLessEq:
+		returnedVals, err = GoCompare(GoLessEqT, Cell1, Cell2)
+	case opGreaterEq:
+		returnedVals, err = GoCompare(GoGreaterEqT, Cell1, Cell2)
+	}
+	if err != nil {
+		return ListCell{}, err
+	}
+	return firstReturnVal(returnedVals), nil
+}
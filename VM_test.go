@@ -0,0 +1,159 @@
+package Golly
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// backEnds are the two ways EvalString can run a program.
+var backEnds = []struct {
+	name string
+	opts EvalOptions
+}{
+	{"tree", EvalOptions{}},
+	{"bytecode", EvalOptions{Bytecode: true}},
+}
+
+const fibSource = "(defn fib (n) (if (< n 2) n (+ (fib (- n 1)) (fib (- n 2)))))"
+
+const loopSource = "(defn sum-to (i acc) (if (= i 0) acc (sum-to (- i 1) (+ acc i))))"
+
+// agreementPrograms are run on both back ends, which must give the same
+// results, or fail with the same message.
+var agreementPrograms = []string{
+	"(+ 1 (* 2 3))",
+	"(/ 7.0 2.0)",
+	"(- 3 2.0)",
+	fibSource + "\n(fib 15)",
+	loopSource + "\n(sum-to 1000 0)",
+	"(let (a 1 b 2) (let (a 10) (+ a b)))",
+	"(let (x : int 4) (* x x))",
+	"(def (adder (fn (n) (fn (m) (+ n m)))))\n((adder 3) 4)",
+	"(defn twice (f x) (f (f x)))\n(twice (fn (n) (* n 3)) 2)",
+	"(if (< 1 2) 1.5 2.5)",
+	"(do (def (x 5)) (def (y (+ x 1))) (* x y))",
+	"(letm (x 1 x 2) x)",
+	"(letm (a 1) (let (b a) b))",
+	"(defm (m 1))\n(def (m 2))",
+	"(if (< 1 2) \"yes\" \"no\")",
+	"(do (def (x 5)) (def (y (+ x 1))) (list x y))",
+	"(map (fn (n) (* n n)) (range 0 5))",
+	"(filter (fn (n) (= (mod n 2) 0)) (range 0 10))",
+	"(reduce (fn (acc n) (+ acc n)) 0 (range 0 100))",
+	"(fold (fn (acc s) (concat acc s)) \"\" (list \"a\" \"b\" \"c\"))",
+	"(sort (list 3 1 2))",
+	"(str \"n=\" 5 :k \\c)",
+	"(split \"a,b,c\" \",\")",
+	"(conj [1 2] (get {:a 3} :a))",
+	"(assoc {:a 1} :b [1 2])",
+	"'(a (b c) [d])",
+	"(car (cdr (list 1 2 3)))",
+	"(symbol->string (symbol \"abc\"))",
+	"(deref (atom 3))",
+	"(await (future (fn () (+ 1 2))))",
+	"(pmap (fn (n) (* n 2)) (list 1 2 3))",
+	"(let (ch (chan 1)) (do (send ch 4) (recv ch)))",
+	"(let (r (ref 1)) (do (dosync (alter r (fn (n) (+ n 1)))) (deref r)))",
+	"(undefined-thing 1)",
+	"(+ 1 true)",
+	"(+ 1 \"a\")",
+	"(/ 1 0)",
+	"(car 1 2)",
+	"(nth (list 1 2) 5)",
+	"(if 1 2 3)",
+	"(let (x 1) (x 2))",
+	"((+ 1 2) 3)",
+	"(defn f (a) a)\n(f 1 2)",
+	"(def (x 1))\n(def (x 2))",
+	"(def (x 1))\n(defm (x 2))",
+	"(let (x : float\n  1) x)",
+	"(def (x : float 1))",
+	"(defn f () g)\n(f)\n(def (g 1))",
+	"(defn f (n)\n  (if\n    n\n    1\n    2))\n(f 3)",
+	"(defn g (n) n)\n(g\n  1\n  2)",
+}
+
+func TestBackEndsAgree(t *testing.T) {
+	for _, src := range agreementPrograms {
+		var results [2]ListCell
+		var errs [2]error
+		for i, backEnd := range backEnds {
+			env := NewEnvironment(CreateSystemFuncs())
+			results[i], errs[i] = EvalString(context.Background(), src, env, backEnd.opts)
+		}
+		if (errs[0] == nil) != (errs[1] == nil) || errs[0] != nil && errs[0].Error() != errs[1].Error() {
+			t.Errorf("%v: the tree-walker returned %q and the VM %q", src, errs[0], errs[1])
+		} else if errs[0] == nil && !reflect.DeepEqual(results[0], results[1]) {
+			t.Errorf("%v: the tree-walker returned %+v and the VM %+v", src, results[0], results[1])
+		}
+	}
+}
+
+func TestClosuresKeepTheirOwnBindings(t *testing.T) {
+	src := `(defn adder (n) (fn (m) (+ n m)))
+(def (a (adder 1)))
+(def (b (adder 10)))
+(+ (a 1) (+ (b 1) (a 2)))`
+	for _, backEnd := range backEnds {
+		env := NewEnvironment(CreateSystemFuncs())
+		result, err := EvalString(context.Background(), src, env, backEnd.opts)
+		if err != nil || result.Value != 16 {
+			t.Errorf("%v: the adders returned %v, %v, want 16", backEnd.name, result.Value, err)
+		}
+	}
+}
+
+// TestErrorsLeaveCallDepth checks that an error in nested calls, including
+// going over MaxDepth, leaves the call depth where it was before them.
+func TestErrorsLeaveCallDepth(t *testing.T) {
+	src := "(defn f (n) (if (= n 0) (/ 1 0) (f (- n 1))))\n"
+	for _, backEnd := range backEnds {
+		for _, call := range []string{"(f 10)", "(f 100)"} {
+			opts := backEnd.opts
+			opts.Limits = Limits{MaxDepth: 50}
+			state := newEvalState(context.Background(), opts)
+			tokens, err := Parse(src + call)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := evalTokens(&tokens, NewEnvironment(CreateSystemFuncs()).withState(state), opts); err == nil {
+				t.Errorf("%v: %v returned no error", backEnd.name, call)
+			}
+			if state.depth != 0 {
+				t.Errorf("%v: after the error in %v the call depth is %v, want 0", backEnd.name, call, state.depth)
+			}
+		}
+	}
+}
+
+// benchmarkBackEnds defines the functions in setup and then times calls
+// of call on each back end.
+func benchmarkBackEnds(b *testing.B, setup, call string) {
+	for _, backEnd := range backEnds {
+		b.Run(backEnd.name, func(b *testing.B) {
+			env := NewEnvironment(CreateSystemFuncs())
+			if _, err := EvalString(context.Background(), setup, env, backEnd.opts); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := EvalString(context.Background(), call, env, backEnd.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFib(b *testing.B) {
+	benchmarkBackEnds(b, fibSource, "(fib 20)")
+}
+
+func BenchmarkLoop(b *testing.B) {
+	benchmarkBackEnds(b, loopSource, "(sum-to 10000 0)")
+}
+
+func BenchmarkReduce(b *testing.B) {
+	benchmarkBackEnds(b, "(def (nums (range 0 10000)))", "(reduce (fn (acc n) (+ acc n)) 0 nums)")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"Golly/formatter"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// runFmt formats Golly source files, or standard input if there are none.
+// It prints the formatted source unless asked to rewrite the files, or, with
+// -check, only to report those that aren't formatted.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("golly fmt", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: golly fmt [-check] [-w] [-width n] [file...]")
+		flags.PrintDefaults()
+	}
+	check := flags.Bool("check", false, "list files that aren't formatted and exit 1 if there are any, without changing them")
+	write := flags.Bool("w", false, "write the formatted source back to each file instead of printing it")
+	width := flags.Int("width", Formatter.DefaultConfig.Width, "the line width to format to")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	config := Formatter.DefaultConfig
+	config.Width = *width
+	if flags.NArg() == 0 {
+		source, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "golly: fmt: %v\n", err)
+			return 1
+		}
+		return formatSource("-", string(source), config, *check, false)
+	}
+	status := 0
+	for _, path := range flags.Args() {
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "golly: fmt: %v\n", err)
+			status = 1
+			continue
+		}
+		if result := formatSource(path, string(source), config, *check, *write); result > status {
+			status = result
+		}
+	}
+	return status
+}
+
+func formatSource(path, source string, config Formatter.Config, check, write bool) int {
+	formatted, err := Formatter.Format(source, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "golly: fmt: %v: %v\n", scriptName(path), strings.TrimRight(err.Error(), "\n"))
+		return 1
+	}
+	switch {
+	case check:
+		if formatted != source {
+			fmt.Println(scriptName(path))
+			return 1
+		}
+	case write:
+		if formatted != source {
+			if err := ioutil.WriteFile(path, []byte(formatted), 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "golly: fmt: %v\n", err)
+				return 1
+			}
+		}
+	default:
+		fmt.Print(formatted)
+	}
+	return 0
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+const maxHistory = 1000
+
+// history holds previously entered forms as they were entered, and appends
+// new ones to its file as they are added. The file holds an entry a line,
+// with the backslashes and line breaks in it escaped, so that a form
+// entered over several lines is recalled unchanged.
+type history struct {
+	path  string
+	lines []string
+}
+
+func loadHistory(path string) *history {
+	hist := history{path: path}
+	if path == "" {
+		return &hist
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return &hist
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			hist.lines = append(hist.lines, unescapeEntry(line))
+		}
+	}
+	if len(hist.lines) > maxHistory {
+		hist.lines = hist.lines[len(hist.lines)-maxHistory:]
+	}
+	return &hist
+}
+
+func (hist *history) add(entry string) {
+	if strings.TrimSpace(entry) == "" || (len(hist.lines) > 0 && hist.lines[len(hist.lines)-1] == entry) {
+		return
+	}
+	hist.lines = append(hist.lines, entry)
+	if len(hist.lines) > maxHistory {
+		hist.lines = hist.lines[1:]
+	}
+	if hist.path == "" {
+		return
+	}
+	file, err := os.OpenFile(hist.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.WriteString(escapeEntry(entry) + "\n")
+}
+
+var entryEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+func escapeEntry(entry string) string {
+	return entryEscaper.Replace(entry)
+}
+
+// unescapeEntry undoes escapeEntry. A backslash before anything else is
+// kept, as it is in lines written before entries were escaped.
+func unescapeEntry(line string) string {
+	if !strings.Contains(line, `\`) {
+		return line
+	}
+	var entry strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\\' || i+1 == len(line) {
+			entry.WriteByte(line[i])
+			continue
+		}
+		switch line[i+1] {
+		case '\\':
+			entry.WriteByte('\\')
+		case 'n':
+			entry.WriteByte('\n')
+		case 'r':
+			entry.WriteByte('\r')
+		default:
+			entry.WriteByte('\\')
+			continue
+		}
+		i++
+	}
+	return entry.String()
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryKeepsEntriesVerbatim(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	entries := []string{
+		"(+ 1 2)",
+		"(println \"multi\n(line\")",
+		"(let (x 1) ; comment\n  x)",
+		"(str \\a \"back\\\\slash\")",
+	}
+	hist := loadHistory(path)
+	for _, entry := range entries {
+		hist.add(entry)
+	}
+	loaded := loadHistory(path)
+	if len(loaded.lines) != len(entries) {
+		t.Fatalf("loaded %v entries, want %v: %q", len(loaded.lines), len(entries), loaded.lines)
+	}
+	for i, entry := range entries {
+		if hist.lines[i] != entry {
+			t.Errorf("entry %v was added as %q, want %q", i, hist.lines[i], entry)
+		}
+		if loaded.lines[i] != entry {
+			t.Errorf("entry %v was loaded as %q, want %q", i, loaded.lines[i], entry)
+		}
+	}
+}
+
+func TestUnescapeUnescapedEntry(t *testing.T) {
+	if got := unescapeEntry(`(char->int \a)`); got != `(char->int \a)` {
+		t.Errorf("unescapeEntry of an entry with a lone backslash gave %q", got)
+	}
+}
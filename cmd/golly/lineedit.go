@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// newLineReader returns an editing reader when in is a terminal that can be
+// put into raw mode, and a plain line scanner otherwise.
+func newLineReader(in *os.File, out io.Writer, hist *history) lineReader {
+	if isTerminal(int(in.Fd())) {
+		return &lineEditor{fd: int(in.Fd()), in: bufio.NewReader(in), out: out, hist: hist}
+	}
+	return &plainReader{in: bufio.NewReader(in), out: out}
+}
+
+type plainReader struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (reader *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(reader.out, prompt)
+	line, err := reader.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		line = line[:len(line)-1]
+	}
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line, nil
+}
+
+// lineEditor is a small emacs-style line editor: arrow keys and ^B/^F move,
+// ^A/^E jump to the ends, ^K/^U kill, and up/down or ^P/^N walk the
+// history.
+type lineEditor struct {
+	fd   int
+	in   *bufio.Reader
+	out  io.Writer
+	hist *history
+}
+
+func (editor *lineEditor) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(editor.fd)
+	if err != nil {
+		return (&plainReader{in: editor.in, out: editor.out}).ReadLine(prompt)
+	}
+	defer restore()
+	state := editState{prompt: prompt, histIdx: len(editor.hist.lines)}
+	fmt.Fprint(editor.out, prompt)
+	for {
+		r, _, err := editor.in.ReadRune()
+		if err != nil {
+			fmt.Fprint(editor.out, "\r\n")
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(editor.out, "\r\n")
+			return string(state.buf), nil
+		case 3:
+			fmt.Fprint(editor.out, "^C\r\n")
+			return "", errInterrupted
+		case 4:
+			if len(state.buf) == 0 {
+				fmt.Fprint(editor.out, "\r\n")
+				return "", io.EOF
+			}
+			state.deleteForward()
+		case 127, 8:
+			state.deleteBackward()
+		case 1:
+			state.pos = 0
+		case 5:
+			state.pos = len(state.buf)
+		case 2:
+			state.move(-1)
+		case 6:
+			state.move(1)
+		case 11:
+			state.buf = state.buf[:state.pos]
+		case 21:
+			state.buf = append([]rune{}, state.buf[state.pos:]...)
+			state.pos = 0
+		case 16:
+			state.walkHistory(editor.hist, -1)
+		case 14:
+			state.walkHistory(editor.hist, 1)
+		case 27:
+			editor.readEscape(&state)
+		default:
+			if unicode.IsPrint(r) || r == '\t' {
+				state.insert(r)
+			}
+		}
+		state.refresh(editor.out)
+	}
+}
+
+func (editor *lineEditor) readEscape(state *editState) {
+	next, _, err := editor.in.ReadRune()
+	if err != nil || (next != '[' && next != 'O') {
+		return
+	}
+	final, _, err := editor.in.ReadRune()
+	if err != nil {
+		return
+	}
+	switch final {
+	case 'A':
+		state.walkHistory(editor.hist, -1)
+	case 'B':
+		state.walkHistory(editor.hist, 1)
+	case 'C':
+		state.move(1)
+	case 'D':
+		state.move(-1)
+	case 'H':
+		state.pos = 0
+	case 'F':
+		state.pos = len(state.buf)
+	case '3':
+		if tilde, _, err := editor.in.ReadRune(); err == nil && tilde == '~' {
+			state.deleteForward()
+		}
+	}
+}
+
+type editState struct {
+	prompt  string
+	buf     []rune
+	pos     int
+	histIdx int
+	pending []rune
+}
+
+func (state *editState) insert(r rune) {
+	state.buf = append(state.buf, 0)
+	copy(state.buf[state.pos+1:], state.buf[state.pos:])
+	state.buf[state.pos] = r
+	state.pos++
+}
+
+func (state *editState) deleteBackward() {
+	if state.pos == 0 {
+		return
+	}
+	state.buf = append(state.buf[:state.pos-1], state.buf[state.pos:]...)
+	state.pos--
+}
+
+func (state *editState) deleteForward() {
+	if state.pos >= len(state.buf) {
+		return
+	}
+	state.buf = append(state.buf[:state.pos], state.buf[state.pos+1:]...)
+}
+
+func (state *editState) move(delta int) {
+	state.pos += delta
+	if state.pos < 0 {
+		state.pos = 0
+	} else if state.pos > len(state.buf) {
+		state.pos = len(state.buf)
+	}
+}
+
+func (state *editState) walkHistory(hist *history, delta int) {
+	newIdx := state.histIdx + delta
+	if newIdx < 0 || newIdx > len(hist.lines) {
+		return
+	}
+	if state.histIdx == len(hist.lines) {
+		//Keep what was being typed so walking back down restores it.
+		state.pending = append([]rune{}, state.buf...)
+	}
+	state.histIdx = newIdx
+	if newIdx == len(hist.lines) {
+		state.buf = append([]rune{}, state.pending...)
+	} else {
+		state.buf = []rune(hist.lines[newIdx])
+	}
+	state.pos = len(state.buf)
+}
+
+// lineBreakMarks shows the line breaks of a form recalled from the history
+// on the one line being edited; the form itself keeps them.
+var lineBreakMarks = strings.NewReplacer("\n", "↵")
+
+func (state *editState) refresh(out io.Writer) {
+	fmt.Fprintf(out, "\r%v%v\x1b[K", state.prompt, lineBreakMarks.Replace(string(state.buf)))
+	if back := len(state.buf) - state.pos; back > 0 {
+		fmt.Fprintf(out, "\x1b[%vD", back)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"Golly"
+	"Golly/lsp"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runLsp serves the Language Server Protocol on standard input and output,
+// for an editor to start golly lsp as its language server.
+func runLsp(sys *Golly.SysEnvironment) int {
+	if err := LSP.NewServer(sys).Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "golly: lsp: %v\n", strings.TrimRight(err.Error(), "\n"))
+		return 1
+	}
+	return 0
+}
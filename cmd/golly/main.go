@@ -0,0 +1,105 @@
+// Command golly runs Golly code. With no arguments it starts an
+// interactive REPL; golly run evaluates a script file, golly fmt formats
+// source files, and golly lsp serves the Language Server Protocol to
+// editors.
+package main
+
+import (
+	"Golly"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const usage = `Usage: golly [flags] [command]
+
+Commands:
+  repl                      start an interactive session (the default)
+  run <file|-> [args...]    evaluate a script, binding args to *args*
+  fmt [-check] [-w] [files] format Golly source; golly fmt -h for more
+  lsp                       serve the Language Server Protocol on stdin and stdout
+
+Flags:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	flags := flag.NewFlagSet("golly", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	bytecode := flags.Bool("bytecode", false, "compile to bytecode and run on the stack VM")
+	optimise := flags.Bool("O", false, "fold constants and drop unused pure let bindings")
+	timeout := flags.Duration("timeout", 0, "stop a script run with golly run after this long; 0 for no limit")
+	caps := flags.String("caps", "", "comma-separated capabilities to grant builtins from (core, math, io, os, net); empty for all")
+	pool := flags.Int("pool", 0, "worker goroutines for pmap, par and future; 0 for GOMAXPROCS")
+	historyPath := flags.String("history", defaultHistoryPath(), "file to keep REPL history in; empty to disable")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	opts := evalOptions(*bytecode, *optimise, *pool)
+	sys, err := newSysEnvironment(*caps)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "golly: %v\n", strings.TrimRight(err.Error(), "\n"))
+		return 2
+	}
+	switch flags.Arg(0) {
+	case "fmt":
+		return runFmt(flags.Args()[1:])
+	case "lsp":
+		return runLsp(sys)
+	case "", "repl":
+		return runRepl(opts, sys, *historyPath)
+	case "run":
+		if flags.NArg() < 2 {
+			fmt.Fprintln(os.Stderr, "golly: run needs a file to evaluate, or - for standard input")
+			flags.Usage()
+			return 2
+		}
+		interpOpts := []Golly.Option{Golly.WithSystem(sys), Golly.WithPoolSize(*pool)}
+		if *bytecode {
+			interpOpts = append(interpOpts, Golly.WithBytecode())
+		}
+		if *optimise {
+			interpOpts = append(interpOpts, Golly.WithOptimise())
+		}
+		return runScript(interpOpts, *timeout, flags.Arg(1), flags.Args()[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "golly: unknown command %q\n", flags.Arg(0))
+		flags.Usage()
+		return 2
+	}
+}
+
+// evalOptions returns the options the REPL evaluates with. As in an
+// Interpreter, the depth limit keeps runaway recursion from overflowing the
+// Go stack.
+func evalOptions(bytecode, optimise bool, pool int) Golly.EvalOptions {
+	limits := Golly.Limits{MaxDepth: Golly.DefaultMaxDepth}
+	return Golly.EvalOptions{Bytecode: bytecode, Optimise: optimise, Limits: limits, PoolSize: pool}
+}
+
+func newSysEnvironment(caps string) (*Golly.SysEnvironment, error) {
+	if caps == "" {
+		return Golly.CreateSystemFuncs(), nil
+	}
+	var granted []Golly.Capability
+	for _, name := range strings.Split(caps, ",") {
+		granted = append(granted, Golly.Capability(strings.TrimSpace(name)))
+	}
+	return Golly.NewSysEnvironment(granted...)
+}
+
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".golly_history")
+}
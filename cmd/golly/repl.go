@@ -0,0 +1,180 @@
+package main
+
+import (
+	"Golly"
+	"Golly/parser"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+)
+
+const (
+	prompt             = "golly> "
+	continuationPrompt = "  ...> "
+)
+
+const replHelp = `Enter Golly forms to evaluate them; a form may span several lines.
+
+Commands:
+  :help         show this message
+  :env          list the bindings defined in this session
+  :type <expr>  evaluate expr and show the type of its value
+  :quit         leave the REPL (so does ^D on an empty line)
+`
+
+type repl struct {
+	env    *Golly.Environment
+	opts   Golly.EvalOptions
+	in     lineReader
+	out    io.Writer
+	errOut io.Writer
+	hist   *history
+}
+
+func runRepl(opts Golly.EvalOptions, sys *Golly.SysEnvironment, historyPath string) int {
+	hist := loadHistory(historyPath)
+	session := repl{
+		env:    Golly.NewEnvironment(sys),
+		opts:   opts,
+		in:     newLineReader(os.Stdin, os.Stdout, hist),
+		out:    os.Stdout,
+		errOut: os.Stderr,
+		hist:   hist,
+	}
+	fmt.Fprintln(session.out, "Golly REPL. Type :help for commands.")
+	session.loop()
+	return 0
+}
+
+func (session *repl) loop() {
+	for {
+		form, err := session.readForm()
+		if err == errInterrupted {
+			continue
+		} else if err != nil {
+			return
+		}
+		trimmed := strings.TrimSpace(form)
+		if trimmed == "" {
+			continue
+		}
+		session.hist.add(form)
+		if isCommand(trimmed) {
+			if !session.command(trimmed) {
+				return
+			}
+			continue
+		}
+		session.eval(form)
+	}
+}
+
+// readForm reads lines until the parentheses they contain balance, so a
+// form can be split across several lines.
+func (session *repl) readForm() (string, error) {
+	var lines []string
+	linePrompt := prompt
+	for {
+		line, err := session.in.ReadLine(linePrompt)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+		form := strings.Join(lines, "\n")
+		if netParens(form) <= 0 {
+			return form, nil
+		}
+		linePrompt = continuationPrompt
+	}
+}
+
+func netParens(input string) int {
+	return Parser.NetParens(Parser.Lex(&input))
+}
+
+// evalForm evaluates form in the session's environment. ^C while it runs
+// cancels the evaluation rather than ending the session.
+func (session *repl) evalForm(form string) (Golly.ListCell, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return Golly.EvalString(ctx, form, session.env, session.opts)
+}
+
+func (session *repl) eval(form string) {
+	result, err := session.evalForm(form)
+	if err != nil {
+		session.printError(err)
+		return
+	}
+	fmt.Fprintln(session.out, Golly.Print(result))
+}
+
+func (session *repl) printError(err error) {
+	fmt.Fprintln(session.errOut, strings.TrimRight(err.Error(), "\n"))
+}
+
+var replCommands = map[string]bool{
+	":help": true, ":h": true, ":?": true,
+	":env":  true,
+	":type": true, ":t": true,
+	":quit": true, ":q": true,
+}
+
+func splitCommand(line string) (string, string) {
+	if i := strings.IndexAny(line, " \t\n"); i >= 0 {
+		return line[:i], strings.TrimSpace(line[i+1:])
+	}
+	return line, ""
+}
+
+// isCommand reports whether a line is a REPL command rather than a form.
+// Other lines starting with a colon are keywords to evaluate.
+func isCommand(line string) bool {
+	name, _ := splitCommand(line)
+	return replCommands[name]
+}
+
+// command runs a REPL command and reports whether the session should go on.
+func (session *repl) command(line string) bool {
+	name, arg := splitCommand(line)
+	switch name {
+	case ":help", ":h", ":?":
+		fmt.Fprint(session.out, replHelp)
+	case ":env":
+		session.showEnv()
+	case ":type", ":t":
+		if arg == "" {
+			fmt.Fprintln(session.errOut, "Usage: :type <expr>")
+			break
+		}
+		result, err := session.evalForm(arg)
+		if err != nil {
+			session.printError(err)
+			break
+		}
+		fmt.Fprintln(session.out, result.TypeName)
+	case ":quit", ":q":
+		return false
+	}
+	return true
+}
+
+func (session *repl) showEnv() {
+	for _, binding := range session.env.Globals() {
+		mutability := ""
+		if binding.Binding.Mutable {
+			mutability = " (mutable)"
+		}
+		fmt.Fprintf(session.out, "%v : %v%v\n", binding.Name, binding.Binding.TypeName, mutability)
+	}
+	builtins := make([]string, 0, len(session.env.System.Bindings))
+	for name := range session.env.System.Bindings {
+		builtins = append(builtins, name)
+	}
+	sort.Strings(builtins)
+	fmt.Fprintf(session.out, "builtins: %v\n", strings.Join(builtins, " "))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"Golly"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// scriptReader is a lineReader that returns the lines of a script.
+type scriptReader struct {
+	lines []string
+}
+
+func (reader *scriptReader) ReadLine(prompt string) (string, error) {
+	if len(reader.lines) == 0 {
+		return "", io.EOF
+	}
+	line := reader.lines[0]
+	reader.lines = reader.lines[1:]
+	return line, nil
+}
+
+// runSession runs a REPL session over the lines of script, returning what
+// it wrote to its output and to its error output.
+func runSession(opts Golly.EvalOptions, script ...string) (string, string) {
+	var out, errOut bytes.Buffer
+	session := repl{
+		env:    Golly.NewEnvironment(Golly.CreateSystemFuncs()),
+		opts:   opts,
+		in:     &scriptReader{lines: script},
+		out:    &out,
+		errOut: &errOut,
+		hist:   &history{},
+	}
+	session.loop()
+	return out.String(), errOut.String()
+}
+
+func TestReplDepthLimit(t *testing.T) {
+	for _, bytecode := range []bool{false, true} {
+		out, errOut := runSession(evalOptions(bytecode, false, 0), "(defn f (n) (+ 1 (f n)))", "(f 1)", "(+ 1 2)")
+		if !strings.Contains(errOut, "call depth limit") {
+			t.Errorf("runaway recursion at the REPL reported %q, want a call depth error", errOut)
+		}
+		if !strings.HasSuffix(out, "3\n") {
+			t.Errorf("the REPL went on to print %q, want it to end with 3", out)
+		}
+	}
+}
+
+func TestReplMultilineForm(t *testing.T) {
+	out, errOut := runSession(evalOptions(false, false, 0), "(let (x 1", "      y 2)", "  (+ x y))")
+	if errOut != "" || out != "3\n" {
+		t.Errorf("a form over three lines printed %q and %q, want 3", out, errOut)
+	}
+}
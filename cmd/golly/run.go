@@ -0,0 +1,55 @@
+package main
+
+import (
+	"Golly"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// argsName is the global a script's command-line arguments are bound to.
+const argsName = "*args*"
+
+// runScript evaluates the whole of the file at path, or standard input if
+// path is "-", with scriptArgs bound to *args* as a list of strings. A
+// non-zero timeout cancels the script once it has run that long. Standard
+// input is evaluated a form at a time as it is read, so it may be a pipe
+// that is never closed, but each form can use only what the forms before
+// it define.
+func runScript(opts []Golly.Option, timeout time.Duration, path string, scriptArgs []string) int {
+	interp := Golly.New(opts...)
+	args := make([]Golly.Value, len(scriptArgs))
+	for i, arg := range scriptArgs {
+		args[i] = Golly.StringValue(arg)
+	}
+	if err := interp.Define(argsName, Golly.ListValue(args...)); err != nil {
+		fmt.Fprintf(os.Stderr, "golly: %v\n", strings.TrimRight(err.Error(), "\n"))
+		return 1
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var err error
+	if path == "-" {
+		_, err = interp.EvalReader(ctx, os.Stdin)
+	} else {
+		_, err = interp.EvalFile(ctx, path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "golly: %v: %v\n", scriptName(path), strings.TrimRight(err.Error(), "\n"))
+		return 1
+	}
+	return 0
+}
+
+func scriptName(path string) string {
+	if path == "-" {
+		return "<stdin>"
+	}
+	return path
+}
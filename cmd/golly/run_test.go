@@ -0,0 +1,126 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runGolly runs the golly command in process with args, feeding it stdin,
+// and returns its exit status and what it wrote to standard output and
+// standard error.
+func runGolly(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "stdin")
+	if err := ioutil.WriteFile(inPath, []byte(stdin), 0644); err != nil {
+		t.Fatal(err)
+	}
+	in, err := os.Open(inPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	out, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	errOut, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer errOut.Close()
+	oldIn, oldOut, oldErr := os.Stdin, os.Stdout, os.Stderr
+	os.Stdin, os.Stdout, os.Stderr = in, out, errOut
+	code := run(args)
+	os.Stdin, os.Stdout, os.Stderr = oldIn, oldOut, oldErr
+	written, err := ioutil.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	errWritten, err := ioutil.ReadFile(errOut.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, string(written), string(errWritten)
+}
+
+// writeScript writes src to a file in a temporary directory and returns its
+// path.
+func writeScript(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "script.golly")
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunSkipsShebang(t *testing.T) {
+	path := writeScript(t, "#!/usr/bin/env golly run\n(+ 1 2)\n")
+	if code, _, errOut := runGolly(t, "", "run", path); code != 0 || errOut != "" {
+		t.Errorf("a script with a #! line exited with %v and %q", code, errOut)
+	}
+	path = writeScript(t, "#!/usr/bin/env golly run\n(+ 1\n   missing)\n")
+	code, _, errOut := runGolly(t, "", "run", path)
+	if code != 1 || !strings.Contains(errOut, "missing at line 3") {
+		t.Errorf("an error after a #! line exited with %v and %q, want it reported at line 3", code, errOut)
+	}
+}
+
+func TestRunBindsArgs(t *testing.T) {
+	path := writeScript(t, "(let (a : List *args*) a)\n")
+	if code, _, errOut := runGolly(t, "", "run", path, "x", "y"); code != 0 || errOut != "" {
+		t.Errorf("*args* as a list exited with %v and %q", code, errOut)
+	}
+	path = writeScript(t, "(let (a : int *args*) a)\n")
+	if code, _, errOut := runGolly(t, "", "run", path); code != 1 || !strings.Contains(errOut, "of type List") {
+		t.Errorf("*args* as an int exited with %v and %q, want a list type error", code, errOut)
+	}
+}
+
+func TestRunReadsStdin(t *testing.T) {
+	if code, _, errOut := runGolly(t, "(+ 1 2)\n", "run", "-"); code != 0 || errOut != "" {
+		t.Errorf("a script on standard input exited with %v and %q", code, errOut)
+	}
+	code, _, errOut := runGolly(t, "(+ 1 2)\n(+ 1 missing)\n", "run", "-")
+	if code != 1 || !strings.HasPrefix(errOut, "golly: <stdin>: Error: ") || !strings.Contains(errOut, "missing at line 2") {
+		t.Errorf("an error on standard input exited with %v and %q", code, errOut)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	path := writeScript(t, "(+ 1 2")
+	code, _, errOut := runGolly(t, "", "run", path)
+	if code != 1 || !strings.HasPrefix(errOut, "golly: "+path+": Error: ") || strings.Count(errOut, "\n") != 1 {
+		t.Errorf("a script that does not parse exited with %v and %q", code, errOut)
+	}
+	missing := filepath.Join(t.TempDir(), "missing.golly")
+	if code, _, errOut := runGolly(t, "", "run", missing); code != 1 || !strings.Contains(errOut, "missing.golly") {
+		t.Errorf("a missing script exited with %v and %q", code, errOut)
+	}
+	if code, _, errOut := runGolly(t, "", "run"); code != 2 || !strings.Contains(errOut, "run needs a file") {
+		t.Errorf("run without a file exited with %v and %q", code, errOut)
+	}
+}
+
+func TestRunPrintsArgs(t *testing.T) {
+	path := writeScript(t, "(println *args*)\n")
+	if code, out, errOut := runGolly(t, "", "run", path, "x", "y"); code != 0 || out != "(x y)\n" || errOut != "" {
+		t.Errorf("printing *args* exited with %v, %q and %q", code, out, errOut)
+	}
+}
+
+func TestRunCapabilities(t *testing.T) {
+	path := writeScript(t, "(println 1)\n")
+	code, out, errOut := runGolly(t, "", "-caps", "core", "run", path)
+	if code != 1 || out != "" || !strings.Contains(errOut, "println at line 1 needs the io capability") {
+		t.Errorf("println without io exited with %v, %q and %q", code, out, errOut)
+	}
+	if code, _, errOut := runGolly(t, "", "-caps", "core,disk", "run", path); code != 2 || !strings.Contains(errOut, "unknown capability disk") {
+		t.Errorf("an unknown capability exited with %v and %q", code, errOut)
+	}
+}
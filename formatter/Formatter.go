@@ -0,0 +1,486 @@
+// Package Formatter lays Golly source out in a canonical style, as golly fmt
+// does.
+package Formatter
+
+import (
+	"Golly/parser"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Config controls the layout Format produces.
+type Config struct {
+	// Width is the column lines are kept within where possible. A form
+	// too long to fit even when broken over lines can overrun it.
+	Width int
+	// Indent is the number of spaces the bodies of let, def, fn, defn, do
+	// and similar forms are indented by.
+	Indent int
+}
+
+// DefaultConfig is the layout golly fmt uses.
+var DefaultConfig = Config{Width: 80, Indent: 2}
+
+// Format parses source and writes it out again in canonical layout. A form
+// that fits in the rest of its line is written on one line; one that
+// doesn't is broken with its arguments aligned, or its body indented for
+// the binding and function forms. defn bodies always start on a new line.
+// Top-level forms are separated by a blank line where the source had one.
+//
+// Comments and forms discarded with #_ are kept. A comment on the line
+// where a form ends stays after it, and any other comment goes on a line of
+// its own before the form that follows it, unless it was beside that form.
+func Format(source string, config Config) (formatted string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	if config.Width <= 0 {
+		config.Width = DefaultConfig.Width
+	}
+	if config.Indent <= 0 {
+		config.Indent = DefaultConfig.Indent
+	}
+	tokens := Parser.ParseListComments(Parser.Lex(&source), 1)
+	var out strings.Builder
+	lastLine := 0
+	separate := func(line int) {
+		if out.Len() > 0 {
+			out.WriteString("\n")
+			if line > lastLine+1 {
+				out.WriteString("\n")
+			}
+		}
+	}
+	for i := range tokens.ListVals {
+		tok := &tokens.ListVals[i]
+		first := tok.LineNum
+		if lead := leading(&tokens, i); len(lead) > 0 {
+			first = lead[0].LineNum
+		}
+		separate(first)
+		out.WriteString(config.element(&tokens, i, 0))
+		lastLine = endLine(tok)
+		for _, comment := range trailing(&tokens, i) {
+			lastLine = commentEnd(comment)
+		}
+	}
+	for _, comment := range endComments(&tokens) {
+		separate(comment.LineNum)
+		out.WriteString(config.comment(comment, 0))
+		lastLine = commentEnd(comment)
+	}
+	if out.Len() > 0 {
+		out.WriteString("\n")
+	}
+	return out.String(), nil
+}
+
+// endLine returns the last line a token's source was seen on.
+func endLine(tok *Parser.Token) int {
+	line := tok.LineNum
+	for i := range tok.ListVals {
+		if end := endLine(&tok.ListVals[i]); end > line {
+			line = end
+		}
+	}
+	for _, comment := range tok.EndComments {
+		if end := commentEnd(comment); end > line {
+			line = end
+		}
+	}
+	if tok.Type == Parser.LiteralToken && tok.LitType == Parser.String {
+		line += strings.Count(tok.Value, "\n")
+	}
+	return line
+}
+
+// commentEnd returns the last line of a comment or discarded form.
+func commentEnd(comment Parser.Comment) int {
+	if comment.Form != nil {
+		return endLine(comment.Form)
+	}
+	return comment.LineNum + strings.Count(comment.Text, "\n")
+}
+
+// trailing returns the comments after element i of tok on the line it ends
+// on, which are kept beside it.
+func trailing(tok *Parser.Token, i int) []Parser.Comment {
+	after := tok.EndComments
+	if i+1 < len(tok.ListVals) {
+		after = tok.ListVals[i+1].Comments
+	}
+	end := endLine(&tok.ListVals[i])
+	n := 0
+	for n < len(after) && after[n].LineNum == end {
+		n++
+	}
+	return after[:n]
+}
+
+// leading returns the comments before element i of tok that don't trail
+// the element before it.
+func leading(tok *Parser.Token, i int) []Parser.Comment {
+	if i == 0 {
+		return tok.ListVals[0].Comments
+	}
+	return tok.ListVals[i].Comments[len(trailing(tok, i-1)):]
+}
+
+// endComments returns the comments after the last element of tok that
+// don't trail it.
+func endComments(tok *Parser.Token) []Parser.Comment {
+	if len(tok.ListVals) == 0 {
+		return tok.EndComments
+	}
+	return tok.EndComments[len(trailing(tok, len(tok.ListVals)-1)):]
+}
+
+// endsOpen reports whether element i of tok is followed by a ; comment, so
+// nothing more can go on its line.
+func endsOpen(tok *Parser.Token, i int) bool {
+	after := trailing(tok, i)
+	return len(after) > 0 && after[len(after)-1].IsLineComment()
+}
+
+// ownLine reports whether a comment before a token must stay on a line of
+// its own: it runs to the end of its line, or was written above the token.
+func ownLine(comment Parser.Comment, tok *Parser.Token) bool {
+	return comment.IsLineComment() || commentEnd(comment) < tok.LineNum
+}
+
+// bodyForms are the special forms whose arguments after the first few are
+// a body, with how many come before it.
+var bodyForms = map[string]int{
+	"let":       1,
+	"letm":      1,
+	"def":       1,
+	"defm":      1,
+	"fn":        1,
+	"defn":      2,
+	"defn-memo": 2,
+	"do":        0,
+	"dosync":    0,
+	"select":    0,
+}
+
+func isQuote(tok *Parser.Token) bool {
+	return tok.Type == Parser.ListToken && len(tok.ListVals) == 2 && tok.ListVals[0].Type == Parser.FormToken && tok.ListVals[0].Value == "quote"
+}
+
+func headName(tok *Parser.Token) string {
+	if tok.Type != Parser.ListToken || len(tok.ListVals) == 0 {
+		return ""
+	}
+	head := &tok.ListVals[0]
+	if head.Type == Parser.DefToken || head.Type == Parser.FormToken {
+		return head.Value
+	}
+	return ""
+}
+
+// mustBreak reports whether a token is, or contains, a form that is never
+// written on one line, or holds a comment that can't share its line.
+func mustBreak(tok *Parser.Token) bool {
+	if name := headName(tok); (name == "defn" || name == "defn-memo") && len(tok.ListVals) > 3 {
+		return true
+	}
+	for i := range tok.ListVals {
+		elem := &tok.ListVals[i]
+		for _, comment := range elem.Comments {
+			if ownLine(comment, elem) || strings.Contains(comment.Text, "\n") || (comment.Form != nil && mustBreak(comment.Form)) {
+				return true
+			}
+		}
+		if mustBreak(elem) {
+			return true
+		}
+	}
+	for _, comment := range tok.EndComments {
+		if comment.IsLineComment() || strings.Contains(comment.Text, "\n") || (comment.Form != nil && mustBreak(comment.Form)) {
+			return true
+		}
+	}
+	return false
+}
+
+func width(text string) int {
+	return utf8.RuneCountInString(text)
+}
+
+func indent(col int) string {
+	return "\n" + strings.Repeat(" ", col)
+}
+
+// flat writes a token on one line.
+func flat(tok *Parser.Token) string {
+	switch tok.Type {
+	case Parser.LiteralToken:
+		switch tok.LitType {
+		case Parser.String:
+			return Parser.QuoteString(tok.Value)
+		case Parser.Regex:
+			return Parser.RegexLiteral(tok.Value)
+		case Parser.Char:
+			char, _ := utf8.DecodeRuneInString(tok.Value)
+			return Parser.CharLiteral(char)
+		case Parser.Keyword:
+			return ":" + tok.Value
+		}
+		return tok.Value
+	case Parser.ListToken:
+		if isQuote(tok) {
+			return "'" + flat(&tok.ListVals[1])
+		}
+		return flatSequence("(", tok, ")")
+	case Parser.VectorToken:
+		return flatSequence("[", tok, "]")
+	case Parser.MapToken:
+		return flatSequence("{", tok, "}")
+	default:
+		return tok.Value
+	}
+}
+
+func flatSequence(open string, tok *Parser.Token, close string) string {
+	items := make([]string, 0, len(tok.ListVals))
+	for i := range tok.ListVals {
+		for _, comment := range tok.ListVals[i].Comments {
+			items = append(items, flatComment(comment))
+		}
+		items = append(items, flat(&tok.ListVals[i]))
+	}
+	for _, comment := range tok.EndComments {
+		items = append(items, flatComment(comment))
+	}
+	return open + strings.Join(items, " ") + close
+}
+
+func flatComment(comment Parser.Comment) string {
+	if comment.Form != nil {
+		return "#_" + flat(comment.Form)
+	}
+	return comment.Text
+}
+
+// comment writes a comment, or a discarded form, starting at column col.
+func (config Config) comment(comment Parser.Comment, col int) string {
+	if comment.Form != nil {
+		return "#_" + config.form(comment.Form, col+2)
+	}
+	return comment.Text
+}
+
+// element writes element i of tok starting at column col, with the
+// comments before it and those trailing it on its line.
+func (config Config) element(tok *Parser.Token, i int, col int) string {
+	return config.elementWith(tok, i, col, config.form)
+}
+
+// elementWith writes element i of tok as element does, writing the element
+// itself with render.
+func (config Config) elementWith(tok *Parser.Token, i int, col int, render func(*Parser.Token, int) string) string {
+	elem := &tok.ListVals[i]
+	var out strings.Builder
+	lead := leading(tok, i)
+	for j, comment := range lead {
+		out.WriteString(config.comment(comment, lastCol(out.String(), col)))
+		next := elem.LineNum
+		if j+1 < len(lead) {
+			next = lead[j+1].LineNum
+		}
+		if !comment.IsLineComment() && next <= commentEnd(comment) {
+			out.WriteString(" ")
+			continue
+		}
+		//A blank line after a comment is kept.
+		if next > commentEnd(comment)+1 {
+			out.WriteString("\n")
+		}
+		out.WriteString(indent(col))
+	}
+	out.WriteString(render(elem, lastCol(out.String(), col)))
+	for _, comment := range trailing(tok, i) {
+		out.WriteString(" " + config.comment(comment, lastCol(out.String(), col)+1))
+	}
+	return out.String()
+}
+
+// beside writes element i of tok after element i-1, which ended at column
+// lineCol: on the same line, or on a new line at column wrap if a ; comment
+// trails element i-1.
+func (config Config) beside(tok *Parser.Token, i int, lineCol int, wrap int) string {
+	if endsOpen(tok, i-1) {
+		return indent(wrap) + config.element(tok, i, wrap)
+	}
+	return " " + config.element(tok, i, lineCol+1)
+}
+
+// closing writes the comments after the last element of tok that are on
+// lines of their own, each at column col, then close.
+func (config Config) closing(tok *Parser.Token, close string, col int) string {
+	var out strings.Builder
+	open := len(tok.ListVals) > 0 && endsOpen(tok, len(tok.ListVals)-1)
+	for _, comment := range endComments(tok) {
+		out.WriteString(indent(col) + config.comment(comment, col))
+		open = comment.IsLineComment()
+	}
+	if open {
+		out.WriteString(indent(col))
+	}
+	out.WriteString(close)
+	return out.String()
+}
+
+// form writes a token starting at column col, breaking it over lines if it
+// doesn't fit in what is left of the line.
+func (config Config) form(tok *Parser.Token, col int) string {
+	text := flat(tok)
+	if !mustBreak(tok) && col+width(text) <= config.Width {
+		return text
+	}
+	switch tok.Type {
+	case Parser.ListToken:
+		return config.list(tok, col)
+	case Parser.VectorToken:
+		return config.aligned(tok, 0, "[", "]", col)
+	case Parser.MapToken:
+		return config.mapForm(tok, col)
+	default:
+		return text
+	}
+}
+
+// aligned writes open and the elements of tok from the one at from on, one
+// to a line and lined up under the first, then close.
+func (config Config) aligned(tok *Parser.Token, from int, open string, close string, col int) string {
+	var out strings.Builder
+	out.WriteString(open)
+	col += width(open)
+	for i := from; i < len(tok.ListVals); i++ {
+		if i > from {
+			out.WriteString(indent(col))
+		}
+		out.WriteString(config.element(tok, i, col))
+	}
+	out.WriteString(config.closing(tok, close, col))
+	return out.String()
+}
+
+// mapForm writes a map one key and value to a line.
+func (config Config) mapForm(tok *Parser.Token, col int) string {
+	var out strings.Builder
+	out.WriteString("{")
+	for i := 0; i < len(tok.ListVals); i += 2 {
+		if i > 0 {
+			out.WriteString(indent(col + 1))
+		}
+		out.WriteString(config.element(tok, i, col+1))
+		if i+1 < len(tok.ListVals) {
+			out.WriteString(config.beside(tok, i+1, lastCol(out.String(), col), col+1))
+		}
+	}
+	out.WriteString(config.closing(tok, "}", col+1))
+	return out.String()
+}
+
+// lastCol returns the column text ends at, when it starts at column col.
+func lastCol(text string, col int) int {
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		return width(text[i+1:])
+	}
+	return col + width(text)
+}
+
+func (config Config) list(tok *Parser.Token, col int) string {
+	if isQuote(tok) {
+		return "'" + config.form(&tok.ListVals[1], col+1)
+	}
+	vals := tok.ListVals
+	name := headName(tok)
+	if header, ok := bodyForms[name]; ok && len(vals) > header {
+		return config.bodyForm(tok, header, col)
+	}
+	if name == "if" && len(vals) > 2 {
+		//The condition goes beside if and the branches under it.
+		head := "(" + config.element(tok, 0, col+1)
+		sep := " "
+		if endsOpen(tok, 0) {
+			sep = indent(col + 4)
+		}
+		return head + sep + config.aligned(tok, 1, "", ")", col+4)
+	}
+	if vals[0].Type == Parser.IdToken && len(vals) > 1 {
+		//Arguments go beside the function and under each other, unless
+		//that would leave too little room for them.
+		head := "(" + config.element(tok, 0, col+1)
+		argCol := lastCol(head, col) + 1
+		if argCol <= col+config.Width/3 && !endsOpen(tok, 0) {
+			return head + " " + config.aligned(tok, 1, "", ")", argCol)
+		}
+		return head + indent(col+config.Indent) + config.aligned(tok, 1, "", ")", col+config.Indent)
+	}
+	return config.aligned(tok, 0, "(", ")", col)
+}
+
+// bodyForm writes a special form with its head and first header arguments
+// on the first line and the rest of its arguments indented under it.
+func (config Config) bodyForm(tok *Parser.Token, header int, col int) string {
+	vals := tok.ListVals
+	var out strings.Builder
+	out.WriteString("(" + config.element(tok, 0, col+1))
+	for i := 1; i <= header; i++ {
+		render := config.form
+		if vals[0].Type == Parser.DefToken && i == 1 {
+			render = config.bindings
+		}
+		argCol := lastCol(out.String(), col) + 1
+		if endsOpen(tok, i-1) {
+			argCol = col + 2*config.Indent
+			out.WriteString(indent(argCol))
+		} else {
+			out.WriteString(" ")
+		}
+		out.WriteString(config.elementWith(tok, i, argCol, render))
+	}
+	for i := header + 1; i < len(vals); i++ {
+		out.WriteString(indent(col + config.Indent))
+		out.WriteString(config.element(tok, i, col+config.Indent))
+	}
+	out.WriteString(config.closing(tok, ")", col+config.Indent))
+	return out.String()
+}
+
+// bindings writes the binding list of a let or def, one binding to a line
+// when they don't all fit on one. A binding is a name and a value, with a
+// type annotation between them if it has one.
+func (config Config) bindings(tok *Parser.Token, col int) string {
+	text := flat(tok)
+	if tok.Type != Parser.ListToken || (!mustBreak(tok) && col+width(text) <= config.Width) {
+		return config.form(tok, col)
+	}
+	vals := tok.ListVals
+	var out strings.Builder
+	out.WriteString("(")
+	for i := 0; i < len(vals); {
+		if i > 0 {
+			out.WriteString(indent(col + 1))
+		}
+		size := 2
+		if i+3 < len(vals) && vals[i+1].Type == Parser.TypeAnnToken {
+			size = 4
+		}
+		if i+size > len(vals) {
+			size = len(vals) - i
+		}
+		out.WriteString(config.element(tok, i, col+1))
+		for j := i + 1; j < i+size; j++ {
+			out.WriteString(config.beside(tok, j, lastCol(out.String(), col), col+1+config.Indent))
+		}
+		i += size
+	}
+	out.WriteString(config.closing(tok, ")", col+1))
+	return out.String()
+}
@@ -0,0 +1,116 @@
+package Formatter
+
+import (
+	"Golly/parser"
+	"testing"
+)
+
+// formatTwice formats src, checks that formatting the result changes
+// nothing, and returns it.
+func formatTwice(t *testing.T, src string) string {
+	t.Helper()
+	formatted, err := Format(src, DefaultConfig)
+	if err != nil {
+		t.Fatalf("Format(%q): %v", src, err)
+	}
+	again, err := Format(formatted, DefaultConfig)
+	if err != nil {
+		t.Fatalf("Format(%q): %v", formatted, err)
+	}
+	if again != formatted {
+		t.Errorf("formatting is not idempotent:\n%s\nthen\n%s", formatted, again)
+	}
+	return formatted
+}
+
+func TestCommentsBeforeLists(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"; header\n(def (x 1))\n", "; header\n(def (x 1))\n"},
+		{"(def (x 1)) ; trailing\n(def (y 2))\n", "(def (x 1)) ; trailing\n(def (y 2))\n"},
+		{"#_ (dropped)\n(def (x 1))\n", "#_(dropped)\n(def (x 1))\n"},
+		{"(let (a 1)\n  ; lead\n  (+ a 1))\n", "(let (a 1)\n  ; lead\n  (+ a 1))\n"},
+		{"[1\n ; before list\n (f 2)]\n", "[1\n ; before list\n (f 2)]\n"},
+	}
+	for _, test := range tests {
+		if got := formatTwice(t, test.src); got != test.want {
+			t.Errorf("Format(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestSpecialFloatsKeepTheirSpelling(t *testing.T) {
+	src := "(def (bounds [##-Inf ##Inf ##NaN]))\n"
+	if got := formatTwice(t, src); got != src {
+		t.Errorf("Format(%q) = %q", src, got)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"(+   1\n 2)", "(+ 1 2)\n"},
+		{"(defn f (n) n)", "(defn f (n)\n  n)\n"},
+		{"(def (x 1))\n\n\n\n(def (y 2))", "(def (x 1))\n\n(def (y 2))\n"},
+		{"(def (x 1))\n(def (y 2))", "(def (x 1))\n(def (y 2))\n"},
+		{"(some-function-with-a-long-name argument-number-one argument-number-two argument-number-three)",
+			"(some-function-with-a-long-name\n  argument-number-one\n  argument-number-two\n  argument-number-three)\n"},
+		{"(let (a 1) (do (println a) (println \"a much longer string to push this past the width\")))",
+			"(let (a 1)\n  (do (println a) (println \"a much longer string to push this past the width\")))\n"},
+		{"'(quoted   list)  {:a 1 :b [1 2   3]}", "'(quoted list)\n{:a 1 :b [1 2 3]}\n"},
+		{"(f \"str\\n\" \\c #\"re+\" 1.5 \\space)", "(f \"str\\n\" \\c #\"re+\" 1.5 \\space)\n"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := formatTwice(t, test.src); got != test.want {
+			t.Errorf("Format(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestWidthAndIndent(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"(list alpha beta gamma delta)", "(list alpha\n      beta\n      gamma\n      delta)\n"},
+		{"(let (a 1) (println a a a))", "(let (a 1)\n    (println a a a))\n"},
+	}
+	for _, test := range tests {
+		got, err := Format(test.src, Config{Width: 20, Indent: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("Format(%q) at width 20 = %q, want %q", test.src, got, test.want)
+		}
+		if wide, _ := Format(test.src, Config{}); wide != test.src+"\n" {
+			t.Errorf("Format(%q) with the zero Config = %q, want the default layout", test.src, wide)
+		}
+	}
+}
+
+// TestFormatKeepsForms checks that formatting changes only layout: the
+// forms of the formatted source print flat as the original's do.
+func TestFormatKeepsForms(t *testing.T) {
+	src := `(defn fib (n) (if (< n 2) n (+ (fib (- n 1)) (fib (- n 2)))))
+(def (table {:one 1 :two [2 2] :three '(3 3 3)}))
+(let (a 1 b 2 c "a long string that needs room") (do (println a b c) (fold (fn (acc n) (+ acc n)) 0 (range 0 10))))`
+	formatted, err := Format(src, Config{Width: 30, Indent: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := Parser.ParseList(Parser.Lex(&src), 1)
+	after := Parser.ParseList(Parser.Lex(&formatted), 1)
+	if len(before.ListVals) != len(after.ListVals) {
+		t.Fatalf("formatting made %v forms of %v", len(after.ListVals), len(before.ListVals))
+	}
+	for i := range before.ListVals {
+		if flat(&after.ListVals[i]) != flat(&before.ListVals[i]) {
+			t.Errorf("formatting changed\n%v\nto\n%v", flat(&before.ListVals[i]), flat(&after.ListVals[i]))
+		}
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	for _, src := range []string{"(a", "(a))", "\"open"} {
+		if _, err := Format(src, DefaultConfig); err == nil {
+			t.Errorf("Format(%q) returned no error", src)
+		}
+	}
+}
@@ -0,0 +1,516 @@
+package LSP
+
+import (
+	"Golly"
+	"Golly/parser"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is an open text document, parsed, with what the server has
+// worked out about the names in it.
+type document struct {
+	uri       string
+	text      string
+	lines     []string
+	tree      Parser.Token
+	parseErrs []*Parser.ParseError
+	// globals are the bindings def, defm, defn and defn-memo forms make, by
+	// name; a name defined twice keeps its first definition.
+	globals map[string]*binding
+	// refs maps identifiers, and the names at binding sites, to the bindings
+	// in the document they refer to.
+	refs map[*Parser.Token]*binding
+}
+
+// binding is a name bound in a document.
+type binding struct {
+	name *Parser.Token
+	// kind is the form that binds the name: def, defm, let, letm, defn,
+	// defn-memo, or param for the parameters of a function and the name a
+	// select recv clause binds.
+	kind    string
+	typeTok *Parser.Token
+	value   *Parser.Token
+	params  *Parser.Token
+	doc     string
+	// function is the name of the defn a param belongs to, if any.
+	function string
+}
+
+// newDocument parses text and works out the bindings its identifiers refer
+// to, as the resolver would, with the builtins of sys shadowing them.
+func newDocument(uri, text string, sys *Golly.SysEnvironment) *document {
+	doc := document{uri: uri, text: text, lines: splitLines(text), globals: make(map[string]*binding), refs: make(map[*Parser.Token]*binding)}
+	doc.tree, doc.parseErrs = Parser.ParseRecovering(text)
+	for i := range doc.tree.ListVals {
+		doc.collectGlobals(&doc.tree.ListVals[i], &doc.tree.ListVals[i])
+	}
+	w := walker{doc: &doc, sys: sys}
+	w.walkBody(doc.tree.ListVals)
+	return &doc
+}
+
+// splitLines splits text into lines at the line breaks the lexer counts.
+func splitLines(text string) []string {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	return strings.Split(text, "\n")
+}
+
+// collectGlobals finds the globals tok defines; top is the top-level form it
+// is in, whose comments document the globals when their own forms have none.
+func (doc *document) collectGlobals(tok, top *Parser.Token) {
+	if tok.Type != Parser.ListToken || len(tok.ListVals) < 2 || isForm(tok, "quote") {
+		for i := range tok.ListVals {
+			doc.collectGlobals(&tok.ListVals[i], top)
+		}
+		return
+	}
+	first := &tok.ListVals[0]
+	docText := docComment(tok)
+	if docText == "" {
+		docText = docComment(top)
+	}
+	if first.Type == Parser.DefToken && (first.Value == "def" || first.Value == "defm") {
+		forEachBinding(&tok.ListVals[1], func(nameTok, typeTok, valueTok *Parser.Token) {
+			b := newBinding(first.Value, nameTok, typeTok, valueTok)
+			if b.doc == "" {
+				b.doc = docText
+			}
+			doc.global(b)
+		})
+	} else if first.Type == Parser.FormToken && (first.Value == "defn" || first.Value == "defn-memo") && tok.ListVals[1].Type == Parser.IdToken {
+		b := &binding{name: &tok.ListVals[1], kind: first.Value, doc: docText}
+		if len(tok.ListVals) > 2 && tok.ListVals[2].Type == Parser.ListToken {
+			b.params = &tok.ListVals[2]
+		}
+		doc.global(b)
+	}
+	for i := range tok.ListVals {
+		doc.collectGlobals(&tok.ListVals[i], top)
+	}
+}
+
+func (doc *document) global(b *binding) {
+	doc.refs[b.name] = b
+	if _, ok := doc.globals[b.name.Value]; !ok {
+		doc.globals[b.name.Value] = b
+	}
+}
+
+// newBinding makes the binding of one name/value pair of a let or def.
+func newBinding(kind string, nameTok, typeTok, valueTok *Parser.Token) *binding {
+	b := &binding{name: nameTok, kind: kind, typeTok: typeTok, value: valueTok, doc: docComment(nameTok)}
+	if isForm(valueTok, "fn") && len(valueTok.ListVals) > 1 && valueTok.ListVals[1].Type == Parser.ListToken {
+		b.params = &valueTok.ListVals[1]
+	}
+	return b
+}
+
+// docComment returns the text of the ; comments on the lines just before
+// tok, without their semicolons.
+func docComment(tok *Parser.Token) string {
+	var lines []string
+	nextLine := tok.LineNum
+	for i := len(tok.Comments) - 1; i >= 0; i-- {
+		comment := tok.Comments[i]
+		if !comment.IsLineComment() || comment.LineNum != nextLine-1 {
+			break
+		}
+		lines = append([]string{strings.TrimSpace(strings.TrimLeft(comment.Text, ";"))}, lines...)
+		nextLine = comment.LineNum
+	}
+	return strings.Join(lines, "\n")
+}
+
+// forEachBinding walks the name/value pairs of a let or def binding list,
+// skipping over type annotations, as the resolver does.
+func forEachBinding(list *Parser.Token, visit func(nameTok, typeTok, valueTok *Parser.Token)) {
+	if list.Type != Parser.ListToken {
+		return
+	}
+	bindings := list.ListVals
+	for i := 0; i+1 < len(bindings); i += 2 {
+		if bindings[i+1].Type == Parser.TypeAnnToken {
+			if i+3 >= len(bindings) {
+				return
+			}
+			visit(&bindings[i], &bindings[i+2], &bindings[i+3])
+			i += 2
+		} else {
+			visit(&bindings[i], nil, &bindings[i+1])
+		}
+	}
+}
+
+// isForm reports whether tok is a list headed by the special form name.
+func isForm(tok *Parser.Token, name string) bool {
+	return tok.Type == Parser.ListToken && len(tok.ListVals) > 0 &&
+		tok.ListVals[0].Type == Parser.FormToken && tok.ListVals[0].Value == name
+}
+
+// walker follows the scopes of a document the way the resolver does,
+// recording the binding each identifier refers to.
+type walker struct {
+	doc    *document
+	sys    *Golly.SysEnvironment
+	scopes [][]*binding
+	// target, if set, is a position whose visible bindings are recorded in
+	// visible as the walk passes it.
+	target  *Position
+	visible []*binding
+}
+
+func (w *walker) lookup(name string) *binding {
+	if _, ok := w.sys.Bindings[name]; ok {
+		return nil
+	}
+	for i := len(w.scopes) - 1; i >= 0; i-- {
+		scope := w.scopes[i]
+		for j := len(scope) - 1; j >= 0; j-- {
+			if scope[j].name.Value == name {
+				return scope[j]
+			}
+		}
+	}
+	return w.doc.globals[name]
+}
+
+func (w *walker) push(bindings ...*binding) {
+	w.scopes = append(w.scopes, bindings)
+}
+
+func (w *walker) declare(b *binding) {
+	w.doc.refs[b.name] = b
+	w.scopes[len(w.scopes)-1] = append(w.scopes[len(w.scopes)-1], b)
+}
+
+func (w *walker) pop() {
+	w.scopes = w.scopes[:len(w.scopes)-1]
+}
+
+// see records the bindings in scope if tok holds the target position.
+func (w *walker) see(tok *Parser.Token) {
+	if w.target == nil || !contains(tok, *w.target) {
+		return
+	}
+	w.visible = w.visible[:0]
+	for _, scope := range w.scopes {
+		w.visible = append(w.visible, scope...)
+	}
+}
+
+// seeAfter records the bindings in scope if form holds the target position
+// somewhere other than in its binding list, so that the names a form binds
+// are visible in the whitespace of its body as well as on its tokens.
+func (w *walker) seeAfter(form, bindings *Parser.Token) {
+	if w.target != nil && !contains(bindings, *w.target) {
+		w.see(form)
+	}
+}
+
+func (w *walker) walk(tok *Parser.Token) {
+	w.see(tok)
+	switch tok.Type {
+	case Parser.IdToken:
+		if b := w.lookup(tok.Value); b != nil {
+			w.doc.refs[tok] = b
+		}
+	case Parser.ListToken:
+		w.walkList(tok)
+	case Parser.VectorToken, Parser.MapToken:
+		w.walkBody(tok.ListVals)
+	}
+}
+
+func (w *walker) walkBody(body []Parser.Token) {
+	for i := range body {
+		w.walk(&body[i])
+	}
+}
+
+func (w *walker) walkList(list *Parser.Token) {
+	if len(list.ListVals) == 0 {
+		return
+	}
+	first := &list.ListVals[0]
+	switch first.Type {
+	case Parser.DefToken:
+		if len(list.ListVals) < 2 {
+			return
+		}
+		if first.Value == "def" || first.Value == "defm" {
+			forEachBinding(&list.ListVals[1], func(nameTok, typeTok, valueTok *Parser.Token) {
+				if typeTok != nil {
+					w.walk(typeTok)
+				}
+				w.walk(valueTok)
+			})
+			w.walkBody(list.ListVals[2:])
+			return
+		}
+		w.push()
+		forEachBinding(&list.ListVals[1], func(nameTok, typeTok, valueTok *Parser.Token) {
+			b := newBinding(first.Value, nameTok, typeTok, valueTok)
+			if typeTok != nil {
+				w.walk(typeTok)
+			}
+			if b.params != nil {
+				w.declare(b)
+				w.walk(valueTok)
+				return
+			}
+			w.walk(valueTok)
+			w.declare(b)
+		})
+		w.seeAfter(list, &list.ListVals[1])
+		w.walkBody(list.ListVals[2:])
+		w.pop()
+	case Parser.FormToken:
+		switch first.Value {
+		case "fn":
+			if len(list.ListVals) > 1 {
+				w.walkFn("", list, &list.ListVals[1], list.ListVals[2:])
+			}
+		case "defn", "defn-memo":
+			if len(list.ListVals) > 2 {
+				w.walkFn(list.ListVals[1].Value, list, &list.ListVals[2], list.ListVals[3:])
+			}
+		case "select":
+			for i := 1; i < len(list.ListVals); i++ {
+				w.walkSelectClause(&list.ListVals[i])
+			}
+		case "quote":
+		default:
+			w.walkBody(list.ListVals[1:])
+		}
+	default:
+		w.walkBody(list.ListVals)
+	}
+}
+
+// walkFn walks the body of the fn, defn or defn-memo form list, with its
+// params in scope; name is "" for an fn.
+func (w *walker) walkFn(name string, list, params *Parser.Token, body []Parser.Token) {
+	w.push()
+	for i := range params.ListVals {
+		w.declare(&binding{name: &params.ListVals[i], kind: "param", function: name})
+	}
+	w.seeAfter(list, params)
+	w.walkBody(body)
+	w.pop()
+}
+
+func (w *walker) walkSelectClause(clause *Parser.Token) {
+	if clause.Type != Parser.ListToken || len(clause.ListVals) < 3 || clause.ListVals[0].Type != Parser.IdToken || clause.ListVals[0].Value != "recv" {
+		w.walk(clause)
+		return
+	}
+	w.walk(&clause.ListVals[1])
+	w.push()
+	w.declare(&binding{name: &clause.ListVals[2], kind: "param"})
+	w.seeAfter(clause, &clause.ListVals[2])
+	w.walkBody(clause.ListVals[3:])
+	w.pop()
+}
+
+// The positions below are lines counted from 0, as the protocol counts
+// them, but with columns counted in runes, as tokens count them.
+
+// tokenEnd returns the line and column just past the end of tok.
+func tokenEnd(tok *Parser.Token) (int, int) {
+	switch {
+	case tok.EndLine > 0:
+		return tok.EndLine - 1, tok.EndCol + 1
+	case len(tok.ListVals) > 0:
+		return tokenEnd(&tok.ListVals[len(tok.ListVals)-1])
+	}
+	return tok.LineNum - 1, tok.Col + utf8.RuneCountInString(tok.Value)
+}
+
+// contains reports whether pos is on tok, counting the position just past
+// its end.
+func contains(tok *Parser.Token, pos Position) bool {
+	if pos.Line < tok.LineNum-1 || pos.Line == tok.LineNum-1 && pos.Character < tok.Col {
+		return false
+	}
+	endLine, endCol := tokenEnd(tok)
+	return pos.Line < endLine || pos.Line == endLine && pos.Character <= endCol
+}
+
+// identAt returns the identifier at pos, or nil if there is none.
+func (doc *document) identAt(pos Position) *Parser.Token {
+	var find func(toks []Parser.Token) *Parser.Token
+	find = func(toks []Parser.Token) *Parser.Token {
+		for i := range toks {
+			tok := &toks[i]
+			if !contains(tok, pos) {
+				continue
+			}
+			if tok.Type == Parser.IdToken {
+				return tok
+			}
+			if found := find(tok.ListVals); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return find(doc.tree.ListVals)
+}
+
+// visibleAt returns the local bindings in scope at pos, outermost first.
+func (doc *document) visibleAt(pos Position, sys *Golly.SysEnvironment) []*binding {
+	scratch := *doc
+	scratch.refs = make(map[*Parser.Token]*binding)
+	w := walker{doc: &scratch, sys: sys, target: &pos}
+	w.walkBody(doc.tree.ListVals)
+	return w.visible
+}
+
+// position converts a protocol position to one with a rune column.
+func (doc *document) position(pos Position) Position {
+	if pos.Line < 0 || pos.Line >= len(doc.lines) {
+		return pos
+	}
+	return Position{Line: pos.Line, Character: runeColumn(doc.lines[pos.Line], pos.Character)}
+}
+
+// protocolPosition converts a position with a rune column to a protocol one.
+func (doc *document) protocolPosition(line, col int) Position {
+	if line < 0 || line >= len(doc.lines) {
+		return Position{Line: line, Character: col}
+	}
+	return Position{Line: line, Character: utf16Column(doc.lines[line], col)}
+}
+
+// tokenRange returns the range of an atom such as an identifier.
+func (doc *document) tokenRange(tok *Parser.Token) Range {
+	endLine, endCol := tokenEnd(tok)
+	return Range{Start: doc.protocolPosition(tok.LineNum-1, tok.Col), End: doc.protocolPosition(endLine, endCol)}
+}
+
+// lineRange returns the range of the whole of a line counted from 1.
+func (doc *document) lineRange(lineNum int) Range {
+	line := lineNum - 1
+	if line < 0 || line >= len(doc.lines) {
+		return Range{Start: Position{Line: line}, End: Position{Line: line}}
+	}
+	return Range{Start: Position{Line: line}, End: Position{Line: line, Character: utf16Len(doc.lines[line])}}
+}
+
+// signature returns the code a binding would be shown as: a call of it for
+// a function, and otherwise its name with its type, where that is known.
+func (b *binding) signature() string {
+	if b.params != nil {
+		names := []string{b.name.Value}
+		for _, param := range b.params.ListVals {
+			names = append(names, param.Value)
+		}
+		return "(" + strings.Join(names, " ") + ")"
+	}
+	typeName := ""
+	if b.typeTok != nil && b.typeTok.Type == Parser.IdToken {
+		typeName = b.typeTok.Value
+	} else if b.value != nil {
+		typeName = Golly.StaticType(b.value)
+	}
+	if typeName == "" {
+		return b.name.Value
+	}
+	return b.name.Value + " : " + typeName
+}
+
+// describe returns the Markdown a hover over a binding shows.
+func (b *binding) describe() string {
+	var where string
+	switch b.kind {
+	case "def", "defm":
+		where = fmt.Sprintf("Global defined with %v at line %v.", b.kind, b.name.LineNum)
+	case "defn", "defn-memo":
+		where = fmt.Sprintf("Function defined with %v at line %v.", b.kind, b.name.LineNum)
+	case "let", "letm":
+		where = fmt.Sprintf("Local bound with %v at line %v.", b.kind, b.name.LineNum)
+	default:
+		if b.function != "" {
+			where = fmt.Sprintf("Parameter of %v, at line %v.", b.function, b.name.LineNum)
+		} else {
+			where = fmt.Sprintf("Parameter bound at line %v.", b.name.LineNum)
+		}
+	}
+	text := "```golly\n" + b.signature() + "\n```\n" + where
+	if b.doc != "" {
+		text += "\n\n" + b.doc
+	}
+	return text
+}
+
+// specialForms are the reserved words completion offers.
+var specialForms = []string{"let", "letm", "def", "defm", "if", "fn", "do", "defn", "defn-memo", "select", "dosync", "quote"}
+
+// identPrefix returns the part of an identifier that ends at the rune column
+// col of line.
+func identPrefix(line string, col int) string {
+	runes := []rune(line)
+	if col > len(runes) {
+		col = len(runes)
+	}
+	start := col
+	for start > 0 && !strings.ContainsRune("()[]{}\"';` \t\f\v", runes[start-1]) {
+		start--
+	}
+	return string(runes[start:col])
+}
+
+// complete returns the names in scope at pos that start with the identifier
+// being typed there: locals, the document's globals, the builtins of sys and
+// the special forms.
+func (doc *document) complete(pos Position, sys *Golly.SysEnvironment) []CompletionItem {
+	prefix := ""
+	if pos.Line >= 0 && pos.Line < len(doc.lines) {
+		prefix = identPrefix(doc.lines[pos.Line], pos.Character)
+	}
+	seen := make(map[string]bool)
+	items := []CompletionItem{}
+	add := func(item CompletionItem) {
+		if seen[item.Label] || !strings.HasPrefix(item.Label, prefix) {
+			return
+		}
+		seen[item.Label] = true
+		items = append(items, item)
+	}
+	addBinding := func(b *binding) {
+		kind := variableKind
+		if b.params != nil {
+			kind = functionKind
+		}
+		add(CompletionItem{Label: b.name.Value, Kind: kind, Detail: b.signature()})
+	}
+	visible := doc.visibleAt(pos, sys)
+	for i := len(visible) - 1; i >= 0; i-- {
+		addBinding(visible[i])
+	}
+	for _, b := range doc.globals {
+		addBinding(b)
+	}
+	for name, b := range sys.Bindings {
+		kind := variableKind
+		if b.Binding.TypeName == Golly.TYPE_TYPE_NAME {
+			kind = classKind
+		} else if b.Binding.TypeName == Golly.FUNCTION_TYPE_NAME {
+			kind = functionKind
+		}
+		detail, _ := sys.Describe(name)
+		add(CompletionItem{Label: name, Kind: kind, Detail: detail})
+	}
+	for _, form := range specialForms {
+		add(CompletionItem{Label: form, Kind: keywordKind})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Label < items[j].Label
+	})
+	return items
+}
@@ -0,0 +1,193 @@
+package LSP
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// The JSON-RPC error codes the server replies with.
+const (
+	parseErrorCode     = -32700
+	invalidParamsCode  = -32602
+	methodNotFoundCode = -32601
+	requestFailedCode  = -32803
+)
+
+// message is a JSON-RPC request or notification from the client; ID is nil
+// for a notification.
+type message struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+// responseError is the error of a request that failed.
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *responseError) Error() string {
+	return err.Message
+}
+
+// readMessage reads one message framed with a Content-Length header.
+func readMessage(in *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(in).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("bad Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(in, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// writeMessage writes a message framed with a Content-Length header.
+func writeMessage(out io.Writer, msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = out.Write(body)
+	return err
+}
+
+// Position, Range and the other types below are those of the protocol, with
+// only the fields the server uses.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+type CompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// The completion item kinds the server uses.
+const (
+	functionKind = 3
+	variableKind = 6
+	classKind    = 7
+	keywordKind  = 14
+)
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type positionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type didOpenParams struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+}
+
+type didChangeParams struct {
+	TextDocument   textDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Range *Range `json:"range"`
+		Text  string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type documentParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+}
+
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// errIncrementalChange is returned for a change to part of a document,
+// which a client should not send, as the server asks for whole documents.
+var errIncrementalChange = errors.New("only changes of the whole document are supported")
+
+// The protocol counts characters in UTF-16 code units, and tokens in runes,
+// so columns are converted between them on each line.
+
+// runeColumn returns the rune column of a UTF-16 offset into line.
+func runeColumn(line string, offset int) int {
+	col := 0
+	for _, char := range line {
+		offset -= utf16.RuneLen(char)
+		if offset < 0 {
+			break
+		}
+		col++
+	}
+	return col
+}
+
+// utf16Column returns the UTF-16 offset of a rune column of line.
+func utf16Column(line string, col int) int {
+	offset := 0
+	for _, char := range line {
+		if col <= 0 {
+			break
+		}
+		offset += utf16.RuneLen(char)
+		col--
+	}
+	return offset
+}
+
+// utf16Len returns the length of text in UTF-16 code units.
+func utf16Len(text string) int {
+	return utf16Column(text, utf8.RuneCountInString(text))
+}
@@ -0,0 +1,301 @@
+// Package LSP serves the Language Server Protocol for Golly source, as golly
+// lsp does, so that editors can show errors, types and documentation as
+// code is written.
+package LSP
+
+import (
+	"Golly"
+	"Golly/formatter"
+	"Golly/parser"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Server is a language server for the documents a client opens. Names are
+// resolved against the builtins of its SysEnvironment, as they would be by
+// an interpreter using it.
+type Server struct {
+	sys      *Golly.SysEnvironment
+	docs     map[string]*document
+	out      io.Writer
+	shutdown bool
+}
+
+// NewServer returns a Server that resolves names against sys.
+func NewServer(sys *Golly.SysEnvironment) *Server {
+	return &Server{sys: sys, docs: make(map[string]*document)}
+}
+
+// Serve answers the messages read from in, writing responses and
+// diagnostics to out, until the client sends exit. It returns nil if the
+// client asked the server to shut down first, and otherwise an error, as an
+// exit without shutdown should end the server with a failure. Since in and
+// out can be any reader and writer, a client can drive a Server in process
+// over a pair of pipes.
+func (server *Server) Serve(in io.Reader, out io.Writer) error {
+	server.out = out
+	reader := bufio.NewReader(in)
+	for {
+		body, err := readMessage(reader)
+		if err == io.EOF && server.shutdown {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := server.reply(nil, nil, &responseError{Code: parseErrorCode, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if !server.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		}
+		result, err := server.handle(&msg)
+		if msg.ID == nil {
+			continue
+		}
+		if err := server.reply(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (server *Server) reply(id *json.RawMessage, result interface{}, err error) error {
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	if err != nil {
+		respErr, ok := err.(*responseError)
+		if !ok {
+			respErr = &responseError{Code: requestFailedCode, Message: err.Error()}
+		}
+		response["error"] = respErr
+	} else {
+		response["result"] = result
+	}
+	return writeMessage(server.out, response)
+}
+
+func (server *Server) notify(method string, params interface{}) error {
+	return writeMessage(server.out, map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// handle runs the method of a request or notification, returning the
+// result to reply to a request with.
+func (server *Server) handle(msg *message) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":           1,
+				"hoverProvider":              true,
+				"definitionProvider":         true,
+				"completionProvider":         map[string]interface{}{},
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "golly"},
+		}, nil
+	case "shutdown":
+		server.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return nil, server.open(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		change := params.ContentChanges[len(params.ContentChanges)-1]
+		if change.Range != nil {
+			return nil, errIncrementalChange
+		}
+		return nil, server.open(params.TextDocument.URI, change.Text)
+	case "textDocument/didClose":
+		var params documentParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(server.docs, params.TextDocument.URI)
+		return nil, server.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	case "textDocument/hover":
+		var params positionParams
+		doc, err := server.document(msg, &params)
+		if err != nil {
+			return nil, err
+		}
+		return server.hover(doc, doc.position(params.Position)), nil
+	case "textDocument/definition":
+		var params positionParams
+		doc, err := server.document(msg, &params)
+		if err != nil {
+			return nil, err
+		}
+		return server.definition(doc, doc.position(params.Position)), nil
+	case "textDocument/completion":
+		var params positionParams
+		doc, err := server.document(msg, &params)
+		if err != nil {
+			return nil, err
+		}
+		return doc.complete(doc.position(params.Position), server.sys), nil
+	case "textDocument/formatting":
+		var params positionParams
+		doc, err := server.document(msg, &params)
+		if err != nil {
+			return nil, err
+		}
+		return server.format(doc)
+	}
+	if msg.ID == nil {
+		return nil, nil
+	}
+	return nil, &responseError{Code: methodNotFoundCode, Message: fmt.Sprintf("unknown method %v", msg.Method)}
+}
+
+func decodeParams(msg *message, params interface{}) error {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &responseError{Code: invalidParamsCode, Message: err.Error()}
+	}
+	return nil
+}
+
+// document decodes params that name a document, and returns the document
+// if it is open.
+func (server *Server) document(msg *message, params *positionParams) (*document, error) {
+	if err := decodeParams(msg, params); err != nil {
+		return nil, err
+	}
+	doc, ok := server.docs[params.TextDocument.URI]
+	if !ok {
+		return nil, &responseError{Code: invalidParamsCode, Message: fmt.Sprintf("%v is not open", params.TextDocument.URI)}
+	}
+	return doc, nil
+}
+
+// open parses the text of a document that has been opened or changed, and
+// publishes its diagnostics.
+func (server *Server) open(uri, text string) error {
+	doc := newDocument(uri, text, server.sys)
+	server.docs[uri] = doc
+	return server.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: server.diagnose(doc)})
+}
+
+// diagnose returns the errors in a document: those ParseRecovering finds,
+// or if it parses, those of the resolver and CheckTypes.
+func (server *Server) diagnose(doc *document) []Diagnostic {
+	diags := []Diagnostic{}
+	for _, err := range doc.parseErrs {
+		diags = append(diags, newDiagnostic(doc.lineRange(err.LineNum), err.Message))
+	}
+	if len(doc.parseErrs) > 0 {
+		return diags
+	}
+	env := Golly.NewEnvironment(server.sys)
+	errs := Golly.Resolve(&doc.tree, env)
+	errs = append(errs, Golly.CheckTypes(&doc.tree, env)...)
+	for _, err := range errs {
+		var name string
+		var lineNum int
+		switch err := err.(type) {
+		case *Golly.UnboundVarError:
+			name, lineNum = err.Name, err.LineNum
+		case *Golly.PermissionError:
+			name, lineNum = err.Name, err.LineNum
+		case *Golly.TypeError:
+			name, lineNum = err.Name, err.LineNum
+		case *Golly.ArityError:
+			name, lineNum = err.Name, err.LineNum
+		}
+		diags = append(diags, newDiagnostic(doc.nameRange(name, lineNum), err.Error()))
+	}
+	return diags
+}
+
+func newDiagnostic(where Range, message string) Diagnostic {
+	message = strings.TrimSpace(strings.TrimPrefix(message, "Error: "))
+	return Diagnostic{Range: where, Severity: 1, Source: "golly", Message: message}
+}
+
+// nameRange returns the range of the first identifier called name on a line
+// counted from 1, or of the whole line if there is none.
+func (doc *document) nameRange(name string, lineNum int) Range {
+	var find func(toks []Parser.Token) *Parser.Token
+	find = func(toks []Parser.Token) *Parser.Token {
+		for i := range toks {
+			tok := &toks[i]
+			if tok.Type == Parser.IdToken && tok.Value == name && tok.LineNum == lineNum {
+				return tok
+			}
+			if found := find(tok.ListVals); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	if tok := find(doc.tree.ListVals); tok != nil {
+		return doc.tokenRange(tok)
+	}
+	return doc.lineRange(lineNum)
+}
+
+// hover describes the identifier at pos: the binding it refers to, or the
+// builtin it names.
+func (server *Server) hover(doc *document, pos Position) *Hover {
+	tok := doc.identAt(pos)
+	if tok == nil {
+		return nil
+	}
+	var text string
+	if b, ok := doc.refs[tok]; ok {
+		text = b.describe()
+	} else if description, ok := server.sys.Describe(tok.Value); ok {
+		text = description
+	} else {
+		return nil
+	}
+	where := doc.tokenRange(tok)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &where}
+}
+
+// definition returns where the binding the identifier at pos refers to is
+// made, if that is in the document.
+func (server *Server) definition(doc *document, pos Position) *Location {
+	tok := doc.identAt(pos)
+	if tok == nil {
+		return nil
+	}
+	b, ok := doc.refs[tok]
+	if !ok {
+		return nil
+	}
+	return &Location{URI: doc.uri, Range: doc.tokenRange(b.name)}
+}
+
+// format returns the edit that lays a document out as golly fmt would: one
+// replacing the whole of it, or none if it is laid out already.
+func (server *Server) format(doc *document) ([]TextEdit, error) {
+	formatted, err := Formatter.Format(doc.text, Formatter.DefaultConfig)
+	if err != nil {
+		return nil, &responseError{Code: requestFailedCode, Message: strings.TrimSpace(strings.TrimPrefix(err.Error(), "Error: "))}
+	}
+	if formatted == doc.text {
+		return []TextEdit{}, nil
+	}
+	last := len(doc.lines) - 1
+	whole := Range{End: Position{Line: last, Character: utf16Len(doc.lines[last])}}
+	return []TextEdit{{Range: whole, NewText: formatted}}, nil
+}
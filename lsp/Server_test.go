@@ -0,0 +1,276 @@
+package LSP
+
+import (
+	"Golly"
+	"Golly/formatter"
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+// client drives a Server in process over a pair of pipes, as an editor
+// would over stdin and stdout.
+type client struct {
+	t      *testing.T
+	out    *io.PipeWriter
+	in     *bufio.Reader
+	nextID int
+	done   chan error
+}
+
+// response is a reply or notification from the server.
+type response struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+func newClient(t *testing.T) *client {
+	sys, err := Golly.NewSysEnvironment(Golly.AllCapabilities...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	toServer, fromClient := io.Pipe()
+	toClient, fromServer := io.Pipe()
+	c := &client{t: t, out: fromClient, in: bufio.NewReader(toClient), done: make(chan error, 1)}
+	go func() {
+		err := NewServer(sys).Serve(toServer, fromServer)
+		fromServer.Close()
+		c.done <- err
+	}()
+	t.Cleanup(func() {
+		fromClient.Close()
+		toClient.Close()
+	})
+	return c
+}
+
+func (c *client) send(msg map[string]interface{}) {
+	c.t.Helper()
+	msg["jsonrpc"] = "2.0"
+	if err := writeMessage(c.out, msg); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) receive() response {
+	c.t.Helper()
+	body, err := readMessage(c.in)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		c.t.Fatalf("%v in %s", err, body)
+	}
+	return resp
+}
+
+// request sends a request and decodes the result of its reply into result.
+func (c *client) request(method string, params, result interface{}) {
+	c.t.Helper()
+	c.nextID++
+	c.send(map[string]interface{}{"id": c.nextID, "method": method, "params": params})
+	resp := c.receive()
+	if resp.ID == nil || *resp.ID != c.nextID {
+		c.t.Fatalf("%v: got a reply to %v rather than %v", method, resp.ID, c.nextID)
+	}
+	if resp.Error != nil {
+		c.t.Fatalf("%v: %v", method, resp.Error.Message)
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		c.t.Fatalf("%v: %v in %s", method, err, resp.Result)
+	}
+}
+
+// open opens a document and returns the diagnostics published for it.
+func (c *client) open(uri, text string) []Diagnostic {
+	c.t.Helper()
+	c.send(map[string]interface{}{"method": "textDocument/didOpen", "params": map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "golly", "version": 1, "text": text},
+	}})
+	resp := c.receive()
+	if resp.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("got %v rather than diagnostics", resp.Method)
+	}
+	var params publishDiagnosticsParams
+	if err := json.Unmarshal(resp.Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	if params.URI != uri {
+		c.t.Fatalf("got diagnostics for %v rather than %v", params.URI, uri)
+	}
+	return params.Diagnostics
+}
+
+func at(uri string, line, character int) positionParams {
+	return positionParams{TextDocument: textDocumentIdentifier{URI: uri}, Position: Position{Line: line, Character: character}}
+}
+
+// exit shuts the server down and checks that Serve returns nil.
+func (c *client) exit() {
+	c.t.Helper()
+	var result interface{}
+	c.request("shutdown", nil, &result)
+	c.send(map[string]interface{}{"method": "exit"})
+	if err := <-c.done; err != nil {
+		c.t.Fatalf("Serve returned %v after shutdown", err)
+	}
+}
+
+const serverSource = `(defn add (a b) (+ a b))
+(let (x 1) (add x 2))
+`
+
+func TestInitialize(t *testing.T) {
+	c := newClient(t)
+	var result struct {
+		Capabilities struct {
+			TextDocumentSync           int             `json:"textDocumentSync"`
+			HoverProvider              bool            `json:"hoverProvider"`
+			DefinitionProvider         bool            `json:"definitionProvider"`
+			CompletionProvider         json.RawMessage `json:"completionProvider"`
+			DocumentFormattingProvider bool            `json:"documentFormattingProvider"`
+		} `json:"capabilities"`
+	}
+	c.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &result)
+	caps := result.Capabilities
+	if caps.TextDocumentSync != 1 || !caps.HoverProvider || !caps.DefinitionProvider || caps.CompletionProvider == nil || !caps.DocumentFormattingProvider {
+		t.Errorf("got capabilities %+v", caps)
+	}
+	c.exit()
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	c := newClient(t)
+	c.send(map[string]interface{}{"method": "exit"})
+	if err := <-c.done; err == nil {
+		t.Error("Serve returned nil for an exit without shutdown")
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	if diags := c.open("file:///ok.golly", serverSource); len(diags) != 0 {
+		t.Errorf("got %+v for a document without errors", diags)
+	}
+	tests := []struct {
+		src     string
+		where   Range
+		message string
+	}{
+		{"(+ 1 missing)", Range{Start: Position{0, 5}, End: Position{0, 12}}, "var missing at line 1 is unbound"},
+		{serverSource + "(add 1)", Range{Start: Position{2, 1}, End: Position{2, 4}}, "add expects 2 arguments but is called with 1"},
+		{`(let (x : int "one") x)`, Range{Start: Position{0, 6}, End: Position{0, 7}}, "x is annotated as int"},
+		{"(+ 1 2", Range{Start: Position{0, 0}, End: Position{0, 6}}, "could not find matching )"},
+	}
+	for _, test := range tests {
+		diags := c.open("file:///bad.golly", test.src)
+		if len(diags) != 1 {
+			t.Errorf("%q: got %+v, want one diagnostic", test.src, diags)
+			continue
+		}
+		diag := diags[0]
+		if diag.Range != test.where || diag.Severity != 1 || diag.Source != "golly" || !strings.Contains(diag.Message, test.message) {
+			t.Errorf("%q: got %+v, want %q at %+v", test.src, diag, test.message, test.where)
+		}
+		if strings.HasPrefix(diag.Message, "Error:") || strings.HasSuffix(diag.Message, "\n") {
+			t.Errorf("%q: message %q keeps the interpreter's framing", test.src, diag.Message)
+		}
+	}
+	c.exit()
+}
+
+func TestHoverAndDefinition(t *testing.T) {
+	c := newClient(t)
+	c.open("file:///add.golly", serverSource)
+
+	var hover Hover
+	c.request("textDocument/hover", at("file:///add.golly", 0, 17), &hover)
+	if !strings.HasPrefix(hover.Contents.Value, "+ is a pure builtin") {
+		t.Errorf("hover on +: got %q", hover.Contents.Value)
+	}
+	c.request("textDocument/hover", at("file:///add.golly", 0, 19), &hover)
+	if !strings.Contains(hover.Contents.Value, "Parameter of add") {
+		t.Errorf("hover on a: got %q", hover.Contents.Value)
+	}
+	if want := (Range{Start: Position{0, 19}, End: Position{0, 20}}); hover.Range == nil || *hover.Range != want {
+		t.Errorf("hover on a: got range %+v, want %+v", hover.Range, want)
+	}
+	var none *Hover
+	c.request("textDocument/hover", at("file:///add.golly", 0, 0), &none)
+	if none != nil {
+		t.Errorf("hover on a paren: got %+v", none)
+	}
+
+	var loc Location
+	c.request("textDocument/definition", at("file:///add.golly", 1, 12), &loc)
+	want := Location{URI: "file:///add.golly", Range: Range{Start: Position{0, 6}, End: Position{0, 9}}}
+	if loc != want {
+		t.Errorf("definition of add: got %+v, want %+v", loc, want)
+	}
+	c.request("textDocument/definition", at("file:///add.golly", 1, 16), &loc)
+	want.Range = Range{Start: Position{1, 6}, End: Position{1, 7}}
+	if loc != want {
+		t.Errorf("definition of x: got %+v, want %+v", loc, want)
+	}
+	c.exit()
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	c.open("file:///add.golly", serverSource)
+	var items []CompletionItem
+	c.request("textDocument/completion", at("file:///add.golly", 1, 14), &items)
+	found := false
+	for _, item := range items {
+		if !strings.HasPrefix(item.Label, "ad") {
+			t.Errorf("completing ad offered %q", item.Label)
+		}
+		if item.Label == "add" {
+			found = true
+			if item.Kind != functionKind || item.Detail != "(add a b)" {
+				t.Errorf("got %+v for add", item)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("completing ad did not offer add: %+v", items)
+	}
+	c.request("textDocument/completion", at("file:///add.golly", 1, 3), &items)
+	labels := make(map[string]int)
+	for _, item := range items {
+		labels[item.Label] = item.Kind
+	}
+	if labels["let"] != keywordKind || labels["letm"] != keywordKind {
+		t.Errorf("completing le: got %+v", items)
+	}
+	c.exit()
+}
+
+func TestFormatting(t *testing.T) {
+	c := newClient(t)
+	src := "(defn add (a b)\n(+ a b))"
+	c.open("file:///fmt.golly", src)
+	var edits []TextEdit
+	c.request("textDocument/formatting", at("file:///fmt.golly", 0, 0), &edits)
+	formatted, err := Formatter.Format(src, Formatter.DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TextEdit{Range: Range{End: Position{1, 8}}, NewText: formatted}
+	if len(edits) != 1 || edits[0] != want {
+		t.Errorf("got edits %+v, want %+v", edits, want)
+	}
+
+	c.open("file:///fmt.golly", formatted)
+	c.request("textDocument/formatting", at("file:///fmt.golly", 0, 0), &edits)
+	if len(edits) != 0 {
+		t.Errorf("got edits %+v for a formatted document", edits)
+	}
+	c.exit()
+}
@@ -3,8 +3,12 @@ package Parser
 import( 
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 const NEW_LINE = "\n"
 
@@ -16,6 +20,11 @@ const(
 	DefToken
 	LiteralToken
 	TypeAnnToken
+	FormToken
+	VectorToken
+	MapToken
+	//ErrorToken stands in for a form ParseRecovering could not parse; its Value is the error.
+	ErrorToken
 )
 
 type litType int
@@ -23,6 +32,17 @@ const(
 	FixNum litType = iota
 	FloNum
 	String
+	Regex
+	Char
+	Keyword
+)
+
+type scopeKind int
+const(
+	Unresolved scopeKind = iota
+	LocalScope
+	GlobalScope
+	SystemScope
 )
 
 type Token struct{
@@ -31,35 +51,410 @@ type Token struct{
 	Value string
 	ListVals []Token
 	LineNum int
+	//Lexical address filled in by the resolver; Depth and Index are only meaningful for LocalScope.
+	Scope scopeKind
+	Depth int
+	Index int
+	//Set on fn and defn lists whose bodies only call pure functions.
+	Pure bool
+	//Trivia kept by ParseListComments: the comments before the token, and on lists, vectors and maps those after
+	//their last element.
+	Comments []Comment
+	EndComments []Comment
+	//Where the token starts, as a column counted in runes from 0, and where the closer of a list, vector or map is.
+	//Only Reader and ParseRecovering, which read text rather than lexemes, know columns, so the others leave these 0.
+	Col int
+	EndLine int
+	EndCol int
+}
+
+//Comment is a ; or #| |# comment, or a form discarded with #_, as trivia on a token.
+type Comment struct{
+	//Text is the comment as written, or #_ for a discarded form.
+	Text string
+	LineNum int
+	//Form is the form a #_ discarded.
+	Form *Token
 }
 
+//IsLineComment reports whether a comment is a ; comment, which runs to the end of its line.
+func (comment Comment) IsLineComment()bool{
+	return strings.HasPrefix(comment.Text, ";")
+}
+
+//Lex splits input into lexemes: brackets, quote marks, string, regex and character literals (quotes and escapes
+//included), comments, #_ marks, NEW_LINE for each line break, and the runs of other characters between whitespace.
+//A ; comment runs to the end of its line and a #| |# comment to its matching |#, as they nest.
 func Lex(input *string) []string{
-	*input = strings.Replace(*input, "(", " ( ", -1)
-	*input = strings.Replace(*input, ")", " ) ",-1)
-	*input = strings.Replace(*input, "\n\r", "\n",-1)
-	*input = strings.Replace(*input, "\r", "\n",-1)
-	*input = strings.Replace(*input, "\n", " " + NEW_LINE + " ",-1)
-	return (strings.Split(*input, " "))
-}
-
-func findMatchingParenDist(lexemes []string)(int,error){
-	netParens := 1
-	for i, lexeme := range lexemes{
-		if lexeme == "("{
+	text := *input
+	lexemes := make([]string, 0, len(text)/4)
+	start := -1
+	flush := func(end int){
+		if start >= 0{
+			lexemes = append(lexemes, text[start:end])
+			start = -1
+		}
+	}
+	for i := 0; i < len(text); i++{
+		switch c := text[i]; c{
+		case '(', ')', '[', ']', '{', '}':
+			flush(i)
+			lexemes = append(lexemes, text[i:i+1])
+		case '"':
+			flush(i)
+			end, _ := stringLitEnd(text, i)
+			lexemes = append(lexemes, text[i:end])
+			i = end-1
+		case '\'':
+			if start < 0{
+				lexemes = append(lexemes, "'")
+			}
+		case ';':
+			flush(i)
+			end := strings.IndexAny(text[i:], "\r\n")
+			if end < 0{
+				end = len(text)-i
+			}
+			lexemes = append(lexemes, text[i:i+end])
+			i += end-1
+		case '\\':
+			if start < 0{
+				end := charLitEnd(text, i)
+				lexemes = append(lexemes, text[i:end])
+				i = end-1
+			}
+		case '#':
+			if start < 0 && i+1 < len(text) && text[i+1] == '"'{
+				end, _ := stringLitEnd(text, i+1)
+				lexemes = append(lexemes, text[i:end])
+				i = end-1
+			}else if start < 0 && i+1 < len(text) && text[i+1] == '|'{
+				end, _ := blockCommentEnd(text, i)
+				lexemes = append(lexemes, text[i:end])
+				i = end-1
+			}else if start < 0 && i+1 < len(text) && text[i+1] == '_'{
+				lexemes = append(lexemes, "#_")
+				i++
+			}else if start < 0{
+				start = i
+			}
+		case '\n':
+			flush(i)
+			lexemes = append(lexemes, NEW_LINE)
+		case '\r':
+			flush(i)
+			if i+1 >= len(text) || text[i+1] != '\n'{
+				lexemes = append(lexemes, NEW_LINE)
+			}
+		case ' ', '\t', '\f', '\v':
+			flush(i)
+		default:
+			if start < 0{
+				start = i
+			}
+		}
+	}
+	flush(len(text))
+	return lexemes
+}
+
+//stringLitEnd returns the index just past the string literal starting at text[start], and whether it is
+//terminated. An unterminated literal runs to the end of text.
+func stringLitEnd(text string, start int)(int,bool){
+	for i := start+1; i < len(text); i++{
+		if text[i] == '\\'{
+			i++
+		}else if text[i] == '"'{
+			return i+1, true
+		}
+	}
+	return len(text), false
+}
+
+//blockCommentEnd returns the index just past the #| |# comment starting at text[start], and whether it is
+//terminated. Comments nest, so each #| inside needs its own |#.
+func blockCommentEnd(text string, start int)(int,bool){
+	depth := 0
+	for i := start; i+1 < len(text); i++{
+		if text[i] == '#' && text[i+1] == '|'{
+			depth++
+			i++
+		}else if text[i] == '|' && text[i+1] == '#'{
+			depth--
+			i++
+			if depth == 0{
+				return i+1, true
+			}
+		}
+	}
+	return len(text), false
+}
+
+func isComment(lexeme string)bool{
+	return strings.HasPrefix(lexeme, ";") || strings.HasPrefix(lexeme, "#|")
+}
+
+func isUnterminatedComment(lexeme string)bool{
+	if !strings.HasPrefix(lexeme, "#|"){
+		return false
+	}
+	_, terminated := blockCommentEnd(lexeme, 0)
+	return !terminated
+}
+
+//charLitEnd returns the index just past the character literal starting with the backslash at text[start]: the
+//character after the backslash and anything up to the next delimiter, or a \u{hex} escape up to its }.
+func charLitEnd(text string, start int)int{
+	i := start+1
+	if i >= len(text){
+		return i
+	}
+	_, size := utf8.DecodeRuneInString(text[i:])
+	i += size
+	if text[start+1] == 'u' && i < len(text) && text[i] == '{'{
+		for i++; i < len(text) && !strings.ContainsRune(charLitDelimiters, rune(text[i])); i++{
+		}
+		if i < len(text) && text[i] == '}'{
+			i++
+		}
+		return i
+	}
+	for i < len(text) && !strings.ContainsRune(charLitDelimiters, rune(text[i])){
+		i++
+	}
+	return i
+}
+
+//charLitDelimiters are the characters that end a character literal.
+const charLitDelimiters = " \t\f\v\r\n()[]{}\";"
+
+//charNames are the characters with names in character literals, such as \space.
+var charNames = map[string]rune{
+	"space": ' ',
+	"newline": '\n',
+	"tab": '\t',
+	"return": '\r',
+	"nul": 0,
+}
+
+//ParseChar returns the character a character literal lexeme stands for: \x for the character x, \name for a named
+//character, or \u{hex} for a code point.
+func ParseChar(lexeme string)(rune,error){
+	body := strings.TrimPrefix(lexeme, "\\")
+	if body == ""{
+		return 0, errors.New("Backslash without a character!")
+	}
+	if char, size := utf8.DecodeRuneInString(body); size == len(body){
+		return char, nil
+	}
+	if char, ok := charNames[body]; ok{
+		return char, nil
+	}
+	if strings.HasPrefix(body, "u{") && strings.HasSuffix(body, "}"){
+		code, err := strconv.ParseUint(body[2:len(body)-1], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)){
+			return 0, errors.New(fmt.Sprintf("\\%v is not a valid code point!", body))
+		}
+		return rune(code), nil
+	}
+	return 0, errors.New(fmt.Sprintf("Unknown character name \\%v!", body))
+}
+
+//CharLiteral writes a character as a character literal, the inverse of ParseChar.
+func CharLiteral(char rune)string{
+	for name, named := range charNames{
+		if named == char{
+			return "\\" + name
+		}
+	}
+	if !unicode.IsPrint(char){
+		return fmt.Sprintf("\\u{%x}", char)
+	}
+	return "\\" + string(char)
+}
+
+//specialFloats are the float literals for the floats that have no digits to write them with.
+var specialFloats = map[string]float64{
+	"##Inf": math.Inf(1),
+	"##-Inf": math.Inf(-1),
+	"##NaN": math.NaN(),
+}
+
+//ParseFloat returns the float a float literal lexeme stands for: digits with one decimal point, or ##Inf, ##-Inf or
+//##NaN.
+func ParseFloat(lexeme string)(float64,error){
+	if val, ok := specialFloats[lexeme]; ok{
+		return val, nil
+	}
+	return strconv.ParseFloat(lexeme, 64)
+}
+
+//FloatLiteral writes a float as a float literal, the inverse of ParseFloat. Whole numbers keep a .0, and no exponent
+//is used, since the reader has none.
+func FloatLiteral(val float64)string{
+	if math.IsInf(val, 1){
+		return "##Inf"
+	}else if math.IsInf(val, -1){
+		return "##-Inf"
+	}else if math.IsNaN(val){
+		return "##NaN"
+	}
+	text := strconv.FormatFloat(val, 'f', -1, 64)
+	if !strings.Contains(text, "."){
+		text += ".0"
+	}
+	return text
+}
+
+//lexemeLines returns the number of line breaks a lexeme spans.
+func lexemeLines(lexeme string)int{
+	if lexeme == NEW_LINE{
+		return 1
+	}else if strings.HasPrefix(lexeme, "#|"){
+		return strings.Count(lexeme, "\n")
+	}else if lexeme = strings.TrimPrefix(lexeme, "#"); len(lexeme) > 0 && lexeme[0] == '"'{
+		return strings.Count(lexeme, "\n")
+	}
+	return 0
+}
+
+//isUnterminatedString reports whether a lexeme is a string or regex literal missing its closing quote.
+func isUnterminatedString(lexeme string)bool{
+	lexeme = strings.TrimPrefix(lexeme, "#")
+	if len(lexeme) == 0 || lexeme[0] != '"'{
+		return false
+	}
+	_, terminated := stringLitEnd(lexeme, 0)
+	return !terminated
+}
+
+//UnquoteRegex returns the pattern of a regex literal lexeme, #"..." The pattern is taken as written, backslashes
+//and all, except that \" stands for a quote.
+func UnquoteRegex(lexeme string)(string,error){
+	if !strings.HasPrefix(lexeme, "#\"") || len(lexeme) < 3 || isUnterminatedString(lexeme){
+		return "", errors.New("Unterminated regex!")
+	}
+	pattern := strings.Replace(lexeme[2:len(lexeme)-1], "\\\"", "\"", -1)
+	if _, err := regexp.Compile(pattern); err != nil{
+		return "", err
+	}
+	return pattern, nil
+}
+
+//QuoteString writes a string as a string literal, the inverse of UnquoteString. Quotes, backslashes and characters
+//that don't print are escaped.
+func QuoteString(str string)string{
+	var text strings.Builder
+	text.WriteByte('"')
+	for _, char := range str{
+		switch char{
+		case '"':
+			text.WriteString(`\"`)
+		case '\\':
+			text.WriteString(`\\`)
+		case '\n':
+			text.WriteString(`\n`)
+		case '\t':
+			text.WriteString(`\t`)
+		case '\r':
+			text.WriteString(`\r`)
+		case 0:
+			text.WriteString(`\0`)
+		default:
+			if unicode.IsPrint(char){
+				text.WriteRune(char)
+			}else{
+				text.WriteString(fmt.Sprintf(`\u{%x}`, char))
+			}
+		}
+	}
+	text.WriteByte('"')
+	return text.String()
+}
+
+//RegexLiteral writes a pattern as a regex literal, the inverse of UnquoteRegex.
+func RegexLiteral(pattern string)string{
+	return `#"` + strings.Replace(pattern, `"`, `\"`, -1) + `"`
+}
+
+//UnquoteString decodes the text of a string literal lexeme, quotes included. It understands the escapes \n, \t,
+//\r, \0, \\, \" and \u{hex}.
+func UnquoteString(lexeme string)(string,error){
+	if len(lexeme) < 2 || lexeme[0] != '"' || lexeme[len(lexeme)-1] != '"' || isUnterminatedString(lexeme){
+		return "", errors.New("Unterminated string!")
+	}
+	body := lexeme[1:len(lexeme)-1]
+	if !strings.Contains(body, "\\"){
+		return body, nil
+	}
+	var str strings.Builder
+	for i := 0; i < len(body); i++{
+		if body[i] != '\\'{
+			str.WriteByte(body[i])
+			continue
+		}
+		i++
+		if i >= len(body){
+			return "", errors.New("String ends in an escape!")
+		}
+		switch body[i]{
+		case 'n':
+			str.WriteByte('\n')
+		case 't':
+			str.WriteByte('\t')
+		case 'r':
+			str.WriteByte('\r')
+		case '0':
+			str.WriteByte(0)
+		case '\\', '"':
+			str.WriteByte(body[i])
+		case 'u':
+			end := strings.IndexByte(body[i:], '}')
+			if i+1 >= len(body) || body[i+1] != '{' || end < 0{
+				return "", errors.New("Unicode escape should be written \\u{hex}!")
+			}
+			code, err := strconv.ParseUint(body[i+2:i+end], 16, 32)
+			if err != nil || code > unicode.MaxRune{
+				return "", errors.New(fmt.Sprintf("Unicode escape \\u{%v} is not a valid code point!", body[i+2:i+end]))
+			}
+			str.WriteRune(rune(code))
+			i += end
+		default:
+			return "", errors.New(fmt.Sprintf("Unknown escape \\%c!", body[i]))
+		}
+	}
+	return str.String(), nil
+}
+
+//closers maps each kind of opening bracket to the one that closes it.
+var closers = map[string]string{"(": ")", "[": "]", "{": "}"}
+
+func isCloser(lexeme string)bool{
+	return lexeme == ")" || lexeme == "]" || lexeme == "}"
+}
+
+//NetParens returns how many more left parentheses than right ones the lexemes contain, so callers reading
+//input a line at a time can tell whether a form is complete.
+func NetParens(lexemes []string)int{
+	netParens := 0
+	for _, lexeme := range lexemes{
+		if _, ok := closers[lexeme]; ok{
 			netParens += 1
-		}else if lexeme == ")"{
+		}else if isCloser(lexeme){
 			netParens -= 1
-		}
-		if netParens == 0{
-			return i, nil
+		}else if isUnterminatedString(lexeme) || isUnterminatedComment(lexeme){
+			netParens += 1
 		}
 	}
-	return -1,errors.New("Failed to find matching parenthesis!")
+	return netParens
 }
 
 func numToToken(number string)(Token,error){
 	numDots := 0
-	for _, dig := range number{
+	for i, dig := range number{
+		if i == 0 && dig == '-'{
+			continue
+		}
 		if !(unicode.IsDigit(dig)) && dig != '.'{
 			return Token{Type: NullToken}, errors.New("Number is malformed; contains a non-digit!")
 		} 
@@ -70,7 +465,7 @@ func numToToken(number string)(Token,error){
 			}
 		}
 	}
-	if numDots > 1{
+	if numDots == 1{
 		return Token{Type: LiteralToken, LitType: FloNum, Value: number}, nil
 	}else{
 		return Token{Type: LiteralToken, LitType: FixNum, Value: number}, nil
@@ -83,6 +478,8 @@ func strToToken(id string)(Token,error){
 	strings.TrimSpace(id)
 	if id == "let" || id == "letm" || id == "def" || id == "defm"{
 		return Token{Type: DefToken, Value: id}, nil
+	}else if id == "if" || id == "fn" || id == "do" || id == "defn" || id == "defn-memo" || id == "select" || id == "dosync" || id == "quote"{
+		return Token{Type: FormToken, Value: id}, nil
 	}else if id == ":"{
 		return Token{Type: TypeAnnToken, Value: id}, nil
 	}else {
@@ -90,53 +487,339 @@ func strToToken(id string)(Token,error){
 	}
 }
 
-func ParseList(lexemes []string, initLine int)Token{
-	fmt.Printf("Parsing list: %v\n", lexemes)
-	list := Token{Type: ListToken, ListVals: make([]Token,0,100)}
-	lineNum := initLine
-	for i := 0; i < len(lexemes); i++{
-		lexeme := lexemes[i]
-	//	fmt.Println(lexeme)
+//prefix is a ' or #_ waiting for the form it applies to.
+type prefix struct{
+	mark string
+	lineNum int
+}
+
+//ParseError is an error in Golly source, found on LineNum.
+type ParseError struct{
+	Message string
+	LineNum int
+}
+
+func (err *ParseError) Error()string{
+	return err.Message
+}
+
+func parseError(lineNum int, format string, args ...interface{})*ParseError{
+	return &ParseError{Message: fmt.Sprintf(format, args...), LineNum: lineNum}
+}
+
+//lexemeSource yields lexemes one at a time, and "" at the end of its input.
+type lexemeSource interface{
+	next()(string,error)
+	//column returns the column the last lexeme started at, or -1 if it isn't known.
+	column()int
+}
+
+//sliceSource yields the lexemes of a slice, as Lex made them.
+type sliceSource struct{
+	lexemes []string
+}
+
+func (src *sliceSource) next()(string,error){
+	if len(src.lexemes) == 0{
+		return "", nil
+	}
+	lexeme := src.lexemes[0]
+	src.lexemes = src.lexemes[1:]
+	return lexeme, nil
+}
+
+func (src *sliceSource) column()int{
+	return -1
+}
+
+//formParser builds tokens from lexemes a form at a time, reading each lexeme once.
+type formParser struct{
+	src lexemeSource
+	lineNum int
+	keepComments bool
+	//comments holds the trivia read since the last form.
+	comments []Comment
+	//end is the closer, or "" for the end of the input, that stopped the last readForm finding a form.
+	end string
+	//open holds the openers of the sequences being read, innermost last.
+	open []string
+	//recovering makes the parser record errors in errs and carry on, putting error tokens in place of what it
+	//can't parse, rather than stop at the first.
+	recovering bool
+	errs []*ParseError
+	//pushed is a lexeme read but left for the next read, when recovering.
+	pushed string
+}
+
+func (parser *formParser) next()(string,error){
+	if parser.pushed != ""{
+		lexeme := parser.pushed
+		parser.pushed = ""
+		return lexeme, nil
+	}
+	return parser.src.next()
+}
+
+//fail returns err, unless the parser is recovering, when it records err and returns nil to carry on.
+func (parser *formParser) fail(err *ParseError)error{
+	if !parser.recovering{
+		return err
+	}
+	parser.errs = append(parser.errs, err)
+	return nil
+}
+
+//errorToken returns a token standing for the form the last recorded error broke.
+func (parser *formParser) errorToken(lineNum int)Token{
+	return Token{Type: ErrorToken, Value: strings.TrimSpace(parser.errs[len(parser.errs)-1].Message), LineNum: lineNum}
+}
+
+func (parser *formParser) isOpen(closer string)bool{
+	for _, open := range parser.open{
+		if closers[open] == closer{
+			return true
+		}
+	}
+	return false
+}
+
+//readForm returns the next form, with the quotes before it applied and the comments before it as its trivia.
+//Forms discarded with #_ are skipped. At a closer or the end of the input it returns a NullToken and sets end.
+func (parser *formParser) readForm()(Token,error){
+	prefixes := []prefix{}
+	for{
+		lexeme, err := parser.next()
+		if err != nil{
+			return Token{Type: NullToken}, err
+		}
 		newToken := Token{Type: NullToken}
-		if lexeme == NEW_LINE {
-			lineNum++
-		}else if lexeme == "("{
-			nextParemDist, err := findMatchingParenDist(lexemes[i+1:])
+		lineNum := parser.lineNum
+		col := parser.src.column()
+		if parser.recovering && lexeme == "(" && len(parser.open) > 0 && parser.src.column() == 0{
+			//A ( at the start of a line is taken to begin the next top-level form, so the forms left open end
+			//here.
+			parser.pushed = lexeme
+			lexeme = ""
+		}
+		if parser.recovering && isCloser(lexeme) && !parser.isOpen(lexeme){
+			parser.fail(parseError(lineNum, "Error: unexpected %v at line %v.\n", lexeme, lineNum))
+			newToken = parser.errorToken(lineNum)
+		}else if lexeme == "" || isCloser(lexeme){
+			parser.end = lexeme
+			if len(prefixes) > 0 && prefixes[len(prefixes)-1].mark == "'"{
+				last := prefixes[len(prefixes)-1]
+				err = parser.fail(parseError(last.lineNum, "Error: ' at line %v is not followed by anything to quote.\n", last.lineNum))
+			}else if len(prefixes) > 0{
+				last := prefixes[len(prefixes)-1]
+				err = parser.fail(parseError(last.lineNum, "Error: #_ at line %v is not followed by a form to discard.\n", last.lineNum))
+			}
+			return newToken, err
+		}else if lexeme == NEW_LINE{
+			parser.lineNum++
+		}else if lexeme == "'" || lexeme == "#_"{
+			prefixes = append(prefixes, prefix{lexeme, lineNum})
+		}else if isComment(lexeme){
+			if isUnterminatedComment(lexeme){
+				if err := parser.fail(parseError(lineNum, "Error: unterminated comment starting at line %v.\n", lineNum)); err != nil{
+					return newToken, err
+				}
+			}
+			if parser.keepComments{
+				parser.comments = append(parser.comments, Comment{Text: lexeme, LineNum: lineNum})
+			}
+			parser.lineNum += lexemeLines(lexeme)
+		}else if _, ok := closers[lexeme]; ok{
+			//The comments before the opener belong to the sequence, not to its first element.
+			comments := parser.comments
+			parser.comments = nil
+			newToken, err = parser.readSeq(lexeme)
 			if err != nil{
-				fmt.Printf("Could not find matching right parenthesis for left parenthesis at line %v\n",
-					len(strings.Split(strings.Join(lexemes,""),"\n") )) 
-				panic("Unable to continue.\n")
+				return newToken, err
 			}
-			newToken = ParseList(lexemes[i+1:nextParemDist+i+1], lineNum)
-			list.ListVals = append(list.ListVals, newToken)
-			i += nextParemDist+1
-			continue
+			parser.comments = comments
 		}else{
-			runes := []rune(lexeme)
-			if len(runes) > 0{
-				if unicode.IsDigit(runes[0]){
-					token, err := numToToken(lexeme)
-					if err != nil{
-						fmt.Printf("Error: malformed literal at line %v; %v\n",
-							len(strings.Split(strings.Join(lexemes,""),"\n")),err) 
-						panic("Unable to continue.\n")
-					}
-					newToken = token
-				}else{
-					token, err := strToToken(lexeme)
-					if err != nil{
-						fmt.Printf("Error: malformed identifier at line %v; %v\n",
-							len(strings.Split(strings.Join(lexemes,""),"\n")),err) 
-						panic("Unable to continue.\n")
-					}
-					newToken = token
+			var lexErr *ParseError
+			newToken, lexErr = lexemeToken(lexeme, lineNum)
+			if lexErr != nil{
+				if err := parser.fail(lexErr); err != nil{
+					return newToken, err
+				}
+				newToken = parser.errorToken(lineNum)
+			}
+			parser.lineNum += lexemeLines(lexeme)
+		}
+		if newToken.Type == NullToken{
+			continue
+		}
+		newToken.LineNum = lineNum
+		if col >= 0{
+			newToken.Col = col
+		}
+		//Prefixes apply innermost first, so in '#_ x y the x is discarded and the y quoted.
+		for len(prefixes) > 0 && newToken.Type != NullToken{
+			last := prefixes[len(prefixes)-1]
+			prefixes = prefixes[:len(prefixes)-1]
+			if last.mark == "'"{
+				quoteTok := Token{Type: FormToken, Value: "quote", LineNum: lineNum}
+				newToken = Token{Type: ListToken, ListVals: []Token{quoteTok, newToken}, LineNum: lineNum, Col: newToken.Col}
+			}else{
+				if parser.keepComments{
+					discarded := newToken
+					parser.comments = append(parser.comments, Comment{Text: "#_", LineNum: last.lineNum, Form: &discarded})
 				}
+				newToken = Token{Type: NullToken}
 			}
 		}
 		if newToken.Type != NullToken{
-			newToken.LineNum = lineNum
-			list.ListVals = append(list.ListVals, newToken)
+			newToken.Comments = parser.comments
+			parser.comments = nil
+			return newToken, nil
 		}
 	}
+}
+
+//readSeq reads the forms after the opener open up to its closer, as a list, vector or map. When recovering, a
+//sequence left open ends with an error token, and the closer of an enclosing one is left for it.
+func (parser *formParser) readSeq(open string)(Token,error){
+	lineNum := parser.lineNum
+	seq := Token{Type: ListToken, ListVals: make([]Token,0,8), LineNum: lineNum}
+	parser.open = append(parser.open, open)
+	defer func(){
+		parser.open = parser.open[:len(parser.open)-1]
+	}()
+	for{
+		tok, err := parser.readForm()
+		if err != nil{
+			return seq, err
+		}
+		if tok.Type == NullToken{
+			break
+		}
+		seq.ListVals = append(seq.ListVals, tok)
+	}
+	seq.EndComments = parser.comments
+	parser.comments = nil
+	if col := parser.src.column(); col >= 0{
+		seq.EndLine, seq.EndCol = parser.lineNum, col
+	}
+	if open == "["{
+		seq.Type = VectorToken
+	}else if open == "{"{
+		seq.Type = MapToken
+	}
+	var seqErr *ParseError
+	if parser.end == ""{
+		seqErr = parseError(lineNum, "Error: could not find matching %v for %v at line %v; Failed to find matching parenthesis!\n",
+			closers[open], open, lineNum)
+	}else if parser.end != closers[open]{
+		seqErr = parseError(lineNum, "Error: could not find matching %v for %v at line %v; Found %v where %v was expected!\n",
+			closers[open], open, lineNum, parser.end, closers[open])
+		parser.pushed = parser.end
+	}else if seq.Type == MapToken && len(seq.ListVals) % 2 != 0{
+		seqErr = parseError(lineNum, "Error: map literal at line %v has a key without a value.\n", lineNum)
+	}
+	if seqErr != nil{
+		if err := parser.fail(seqErr); err != nil{
+			return seq, err
+		}
+		seq.ListVals = append(seq.ListVals, parser.errorToken(parser.lineNum))
+	}
+	return seq, nil
+}
+
+//readAll reads forms to the end of the input, as the elements of a list.
+func (parser *formParser) readAll()(Token,error){
+	list := Token{Type: ListToken, ListVals: make([]Token,0,100)}
+	for{
+		tok, err := parser.readForm()
+		if err != nil{
+			return list, err
+		}
+		if tok.Type == NullToken && parser.end != ""{
+			return list, parseError(parser.lineNum, "Error: unexpected %v at line %v.\n", parser.end, parser.lineNum)
+		}else if tok.Type == NullToken{
+			break
+		}
+		list.ListVals = append(list.ListVals, tok)
+	}
+	list.EndComments = parser.comments
+	parser.comments = nil
+	return list, nil
+}
+
+//lexemeToken makes the token for a lexeme that is neither a bracket, a quote mark nor a comment.
+func lexemeToken(lexeme string, lineNum int)(Token,*ParseError){
+	runes := []rune(lexeme)
+	if isUnterminatedString(lexeme){
+		return Token{Type: NullToken}, parseError(lineNum, "Error: unterminated string starting at line %v.\n", lineNum)
+	}else if strings.HasPrefix(lexeme, "\\"){
+		char, err := ParseChar(lexeme)
+		if err != nil{
+			return Token{Type: NullToken}, parseError(lineNum, "Error: malformed character literal at line %v; %v\n", lineNum, err)
+		}
+		return Token{Type: LiteralToken, LitType: Char, Value: string(char)}, nil
+	}else if strings.HasPrefix(lexeme, "#\""){
+		pattern, err := UnquoteRegex(lexeme)
+		if err != nil{
+			return Token{Type: NullToken}, parseError(lineNum, "Error: malformed regex literal at line %v; %v\n", lineNum, err)
+		}
+		return Token{Type: LiteralToken, LitType: Regex, Value: pattern}, nil
+	}else if len(runes) > 0 && runes[0] == '"'{
+		str, err := UnquoteString(lexeme)
+		if err != nil{
+			return Token{Type: NullToken}, parseError(lineNum, "Error: malformed string literal at line %v; %v\n", lineNum, err)
+		}
+		return Token{Type: LiteralToken, LitType: String, Value: str}, nil
+	}else if _, ok := specialFloats[lexeme]; ok{
+		return Token{Type: LiteralToken, LitType: FloNum, Value: lexeme}, nil
+	}else if len(runes) > 1 && runes[0] == ':'{
+		return Token{Type: LiteralToken, LitType: Keyword, Value: lexeme[1:]}, nil
+	}else if len(runes) > 0 && (unicode.IsDigit(runes[0]) || (runes[0] == '-' && len(runes) > 1 && unicode.IsDigit(runes[1]))){
+		token, err := numToToken(lexeme)
+		if err != nil{
+			return Token{Type: NullToken}, parseError(lineNum, "Error: malformed literal at line %v; %v\n", lineNum, err)
+		}
+		return token, nil
+	}
+	token, err := strToToken(lexeme)
+	if err != nil{
+		return Token{Type: NullToken}, parseError(lineNum, "Error: malformed identifier at line %v; %v\n", lineNum, err)
+	}
+	return token, nil
+}
+
+//ParseList parses lexemes into a list of the top-level forms they hold. Comments and forms discarded with #_ are
+//dropped.
+func ParseList(lexemes []string, initLine int)Token{
+	return parseList(lexemes, initLine, false)
+}
+
+//ParseListComments parses lexemes as ParseList does, but keeps comments and discarded forms as trivia on the tokens
+//that follow them, for tools such as the formatter.
+func ParseListComments(lexemes []string, initLine int)Token{
+	return parseList(lexemes, initLine, true)
+}
+
+func parseList(lexemes []string, initLine int, keepComments bool)Token{
+	parser := formParser{src: &sliceSource{lexemes}, lineNum: initLine, keepComments: keepComments}
+	list, err := parser.readAll()
+	if err != nil{
+		panic(err.Error())
+	}
 	return list
 }
+
+//ParseRecovering parses input as ParseListComments does, for tools such as editors, which need a tree of source
+//that is still being written. Rather than stop at the first error it returns every error it finds, with an
+//ErrorToken in the tree for each form it could not parse and at the end of each list left open. Since an open list
+//would otherwise swallow the rest of the input, a ( at the start of a line is taken to begin a new top-level form
+//once an error has been found. Source without errors gives the same tree ParseListComments does.
+func ParseRecovering(input string)(Token,[]*ParseError){
+	parser := formParser{src: newStreamLexer(strings.NewReader(input)), lineNum: 1, keepComments: true}
+	if list, err := parser.readAll(); err == nil{
+		return list, nil
+	}
+	parser = formParser{src: newStreamLexer(strings.NewReader(input)), lineNum: 1, keepComments: true, recovering: true}
+	list, _ := parser.readAll()
+	return list, parser.errs
+}
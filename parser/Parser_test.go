@@ -0,0 +1,133 @@
+package Parser
+
+import(
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLexComments(t *testing.T){
+	tests := []struct{
+		src string
+		want []string
+	}{
+		{"a ; b (c)\nd", []string{"a", "; b (c)", NEW_LINE, "d"}},
+		{`"a ; b" ; c`, []string{`"a ; b"`, "; c"}},
+		{`"a #| b |#" c`, []string{`"a #| b |#"`, "c"}},
+		{"x #| a #| b |# c |# y", []string{"x", "#| a #| b |# c |#", "y"}},
+		{"#| a\n|# b", []string{"#| a\n|#", "b"}},
+		{"#| a #| b |# c", []string{"#| a #| b |# c"}},
+		{"(f #_ (g) x)", []string{"(", "f", "#_", "(", "g", ")", "x", ")"}},
+		{"(a) #_", []string{"(", "a", ")", "#_"}},
+		{"a#_b", []string{"a#_b"}},
+		{"\\; x", []string{"\\;", "x"}},
+	}
+	for _, test := range tests{
+		if got := Lex(&test.src); !reflect.DeepEqual(got, test.want){
+			t.Errorf("Lex(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+//parseListError returns the message ParseList panics with for src, or "" if it parses.
+func parseListError(src string)(msg string){
+	defer func(){
+		if r := recover(); r != nil{
+			msg = r.(string)
+		}
+	}()
+	ParseList(Lex(&src), 1)
+	return ""
+}
+
+func TestParseCommentErrors(t *testing.T){
+	tests := []struct{ src, want string }{
+		{"(a)\n#| open\n#| nested |#\n", "unterminated comment starting at line 2"},
+		{"(a\n  #| open)", "unterminated comment"},
+		{"(a)\n(b) #_", "#_ at line 2 is not followed by a form to discard"},
+		{"(a #_)", "#_ at line 1 is not followed by a form to discard"},
+		{"#| a #| b |# c |# (a) ; end", ""},
+		{`(str "; not a comment")`, ""},
+	}
+	for _, test := range tests{
+		msg := parseListError(test.src)
+		if test.want == "" && msg != "" || !strings.Contains(msg, test.want){
+			t.Errorf("ParseList of %q failed with %q, want %q", test.src, msg, test.want)
+		}
+	}
+}
+
+func TestParseDropsComments(t *testing.T){
+	src := "; lead\n(f #| a #| b |# |# x #_ (g y) ; tail\n z)"
+	tree := ParseList(Lex(&src), 1)
+	if len(tree.ListVals) != 1 || len(tree.ListVals[0].ListVals) != 3{
+		t.Fatalf("ParseList read %+v", tree)
+	}
+	for i, want := range []string{"f", "x", "z"}{
+		if got := tree.ListVals[0].ListVals[i]; got.Value != want{
+			t.Errorf("element %v is %q, want %q", i, got.Value, want)
+		}
+	}
+	if z := tree.ListVals[0].ListVals[2]; z.LineNum != 3{
+		t.Errorf("z is at line %v, want 3", z.LineNum)
+	}
+}
+
+func TestParseRecoveringValidSource(t *testing.T){
+	src := "; lead\n(def (x 1))\n(defn f (a)\n  #_ (old a)\n  [a {:k 'x}])\n; end\n"
+	tree, errs := ParseRecovering(src)
+	if len(errs) > 0{
+		t.Fatalf("ParseRecovering of valid source returned %v", errs)
+	}
+	want := ParseListComments(Lex(&src), 1)
+	if got := withoutColumns(tree); !reflect.DeepEqual(got, want){
+		t.Errorf("ParseRecovering read\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseRecoveringErrors(t *testing.T){
+	tests := []struct{
+		src string
+		//The lines of the errors found, and the number of top-level forms in the tree.
+		errLines []int
+		forms int
+	}{
+		{"(a ))\n(b #_)\n(c", []int{1, 2, 3}, 4},
+		{"(def (x 1)\n(defn f (y)\n  (+ y 1))\n(g \"open", []int{1, 4, 4}, 3},
+		{"(a 1)\n[b\n(c 2)", []int{2}, 3},
+		{"(x \\bad-char) (y)", []int{1}, 2},
+	}
+	for _, test := range tests{
+		tree, errs := ParseRecovering(test.src)
+		var lines []int
+		for _, err := range errs{
+			lines = append(lines, err.LineNum)
+		}
+		if !reflect.DeepEqual(lines, test.errLines){
+			t.Errorf("ParseRecovering of %q found errors at lines %v, want %v", test.src, lines, test.errLines)
+		}
+		if len(tree.ListVals) != test.forms{
+			t.Errorf("ParseRecovering of %q read %v forms, want %v", test.src, len(tree.ListVals), test.forms)
+		}
+	}
+}
+
+//TestParseRecoveringKeepsLaterForms checks that a list left open stops at the next ( at the start of a line, so
+//the forms after it still parse, and that an ErrorToken marks where the open list ends.
+func TestParseRecoveringKeepsLaterForms(t *testing.T){
+	tree, errs := ParseRecovering("(defn f (y)\n  (+ y 1)\n(defn g (z) z)\n")
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "could not find matching )"){
+		t.Fatalf("ParseRecovering returned errors %v", errs)
+	}
+	if len(tree.ListVals) != 2{
+		t.Fatalf("ParseRecovering read %v forms, want 2", len(tree.ListVals))
+	}
+	f := tree.ListVals[0].ListVals
+	if last := f[len(f)-1]; last.Type != ErrorToken || last.Value != strings.TrimSpace(errs[0].Message){
+		t.Errorf("the open defn ends with %+v, want an ErrorToken", last)
+	}
+	g := tree.ListVals[1]
+	if g.LineNum != 3 || len(g.ListVals) != 4 || g.ListVals[1].Value != "g"{
+		t.Errorf("the defn after the open one read as %+v", g)
+	}
+}
@@ -0,0 +1,237 @@
+package Parser
+
+import(
+	"bufio"
+	"io"
+	"strings"
+)
+
+//Reader parses Golly source from an io.Reader a top-level form at a time. It reads the input a rune at a time,
+//and only as far as the end of each form, so large inputs are never held whole and an interactive input is not
+//waited on past the form being read.
+type Reader struct{
+	//KeepComments makes Read keep comments and discarded forms as trivia, as ParseListComments does.
+	KeepComments bool
+	parser formParser
+}
+
+//NewReader returns a Reader of the source in input, which starts at line 1.
+func NewReader(input io.Reader)*Reader{
+	return &Reader{parser: formParser{src: newStreamLexer(input), lineNum: 1}}
+}
+
+//Read returns the next top-level form, or io.EOF once the input holds no more. Comments after the last form are
+//dropped. Errors in the source are returned with the same messages ParseList panics with; after one, the rest of
+//the input can't be relied on to parse.
+func (reader *Reader) Read()(Token,error){
+	reader.parser.keepComments = reader.KeepComments
+	tok, err := reader.parser.readForm()
+	if err != nil{
+		return tok, err
+	}
+	if tok.Type == NullToken && reader.parser.end != ""{
+		return tok, parseError(reader.parser.lineNum, "Error: unexpected %v at line %v.\n", reader.parser.end, reader.parser.lineNum)
+	}else if tok.Type == NullToken{
+		reader.parser.comments = nil
+		return tok, io.EOF
+	}
+	return tok, nil
+}
+
+//streamLexer splits the runes of a reader into the same lexemes Lex makes of a string.
+type streamLexer struct{
+	in *bufio.Reader
+	//col is the column of the next rune, lastCol that of the one before it, and startCol that of the first rune of
+	//the last lexeme.
+	col int
+	lastCol int
+	startCol int
+}
+
+func newStreamLexer(input io.Reader)*streamLexer{
+	return &streamLexer{in: bufio.NewReader(input)}
+}
+
+func (lex *streamLexer) column()int{
+	return lex.startCol
+}
+
+//readRune returns the next rune, or -1 at the end of the input.
+func (lex *streamLexer) readRune()(rune,error){
+	char, _, err := lex.in.ReadRune()
+	if err == io.EOF{
+		return -1, nil
+	}
+	lex.lastCol = lex.col
+	if char == '\n' || char == '\r'{
+		lex.col = 0
+	}else{
+		lex.col++
+	}
+	return char, err
+}
+
+//unread puts back the rune just read.
+func (lex *streamLexer) unread(){
+	lex.in.UnreadRune()
+	lex.col = lex.lastCol
+}
+
+//peek returns the next rune without reading it, or -1 at the end of the input.
+func (lex *streamLexer) peek()(rune,error){
+	char, err := lex.readRune()
+	if err == nil && char >= 0{
+		lex.unread()
+	}
+	return char, err
+}
+
+func (lex *streamLexer) next()(string,error){
+	var text strings.Builder
+	for{
+		char, err := lex.readRune()
+		if err != nil{
+			return "", err
+		}
+		if text.Len() == 0{
+			lex.startCol = lex.lastCol
+		}else{
+			//A run of other characters ends at anything that would start a lexeme of its own.
+			if char < 0{
+				return text.String(), nil
+			}
+			if strings.ContainsRune("()[]{}\";\n\r \t\f\v", char){
+				lex.unread()
+				return text.String(), nil
+			}
+			text.WriteRune(char)
+			continue
+		}
+		switch char{
+		case -1:
+			return "", nil
+		case '(', ')', '[', ']', '{', '}', '\'':
+			return string(char), nil
+		case '"':
+			text.WriteRune(char)
+			return lex.stringLit(&text)
+		case ';':
+			text.WriteRune(char)
+			return lex.until(&text, "\r\n")
+		case '\\':
+			return lex.charLit()
+		case '#':
+			text.WriteRune(char)
+			next, err := lex.peek()
+			if err != nil{
+				return "", err
+			}
+			if next == '"' || next == '|' || next == '_'{
+				lex.readRune()
+				text.WriteRune(next)
+			}
+			if next == '"'{
+				return lex.stringLit(&text)
+			}else if next == '|'{
+				return lex.blockComment(&text)
+			}else if next == '_'{
+				return text.String(), nil
+			}
+		case '\n':
+			return NEW_LINE, nil
+		case '\r':
+			//\r\n is one line break, like a lone \r.
+			next, err := lex.peek()
+			if next == '\n'{
+				lex.readRune()
+			}
+			return NEW_LINE, err
+		case ' ', '\t', '\f', '\v':
+		default:
+			text.WriteRune(char)
+		}
+	}
+}
+
+//until reads runes into text up to, but not including, one in stops or the end of the input.
+func (lex *streamLexer) until(text *strings.Builder, stops string)(string,error){
+	for{
+		char, err := lex.readRune()
+		if err != nil{
+			return "", err
+		}
+		if char < 0{
+			return text.String(), nil
+		}
+		if strings.ContainsRune(stops, char){
+			lex.unread()
+			return text.String(), nil
+		}
+		text.WriteRune(char)
+	}
+}
+
+//stringLit reads the rest of a string or regex literal whose opening quote is in text.
+func (lex *streamLexer) stringLit(text *strings.Builder)(string,error){
+	for{
+		char, err := lex.readRune()
+		if err != nil || char < 0{
+			return text.String(), err
+		}
+		text.WriteRune(char)
+		if char == '"'{
+			return text.String(), nil
+		}else if char == '\\'{
+			if char, err = lex.readRune(); err != nil || char < 0{
+				return text.String(), err
+			}
+			text.WriteRune(char)
+		}
+	}
+}
+
+//blockComment reads the rest of a #| |# comment whose opening #| is in text.
+func (lex *streamLexer) blockComment(text *strings.Builder)(string,error){
+	depth := 1
+	last := rune(-1)
+	for depth > 0{
+		char, err := lex.readRune()
+		if err != nil || char < 0{
+			return text.String(), err
+		}
+		text.WriteRune(char)
+		if last == '#' && char == '|'{
+			depth++
+			char = -1
+		}else if last == '|' && char == '#'{
+			depth--
+			char = -1
+		}
+		last = char
+	}
+	return text.String(), nil
+}
+
+//charLit reads a character literal after its backslash.
+func (lex *streamLexer) charLit()(string,error){
+	text := strings.Builder{}
+	text.WriteRune('\\')
+	char, err := lex.readRune()
+	if err != nil || char < 0{
+		return text.String(), err
+	}
+	text.WriteRune(char)
+	if next, err := lex.peek(); char == 'u' && next == '{' && err == nil{
+		lex.readRune()
+		text.WriteRune(next)
+		if _, err := lex.until(&text, charLitDelimiters); err != nil{
+			return "", err
+		}
+		if next, err = lex.peek(); next == '}' && err == nil{
+			lex.readRune()
+			text.WriteRune(next)
+		}
+		return text.String(), err
+	}
+	return lex.until(&text, charLitDelimiters)
+}
@@ -0,0 +1,149 @@
+package Parser
+
+import(
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+//withoutColumns returns a copy of tok, and of the forms its comments discarded, without the columns only Reader
+//and ParseRecovering know, so that their trees can be compared with ParseList's.
+func withoutColumns(tok Token)Token{
+	tok.Col, tok.EndLine, tok.EndCol = 0, 0, 0
+	if tok.ListVals != nil{
+		vals := make([]Token, len(tok.ListVals))
+		for i := range tok.ListVals{
+			vals[i] = withoutColumns(tok.ListVals[i])
+		}
+		tok.ListVals = vals
+	}
+	tok.Comments = commentsWithoutColumns(tok.Comments)
+	tok.EndComments = commentsWithoutColumns(tok.EndComments)
+	return tok
+}
+
+func commentsWithoutColumns(comments []Comment)[]Comment{
+	if comments == nil{
+		return nil
+	}
+	stripped := make([]Comment, len(comments))
+	for i, comment := range comments{
+		if comment.Form != nil{
+			form := withoutColumns(*comment.Form)
+			comment.Form = &form
+		}
+		stripped[i] = comment
+	}
+	return stripped
+}
+
+//readAll reads every form from src with a Reader.
+func readAll(t *testing.T, src string, keepComments bool)[]Token{
+	t.Helper()
+	reader := NewReader(strings.NewReader(src))
+	reader.KeepComments = keepComments
+	var forms []Token
+	for{
+		tok, err := reader.Read()
+		if err == io.EOF{
+			return forms
+		}else if err != nil{
+			t.Fatalf("reading %q: %v", src, err)
+		}
+		forms = append(forms, withoutColumns(tok))
+	}
+}
+
+func TestReaderMatchesParseList(t *testing.T){
+	srcs := []string{
+		"(def (x 1))\n(defn f (a) (+ a x))\n(f 2)",
+		"'(a \"b\\n\" \\c :d #\"e+\") [1 2.5] {:k [3]}",
+		"; comment\n(a #_ (b) c) #| block\n|# (d)\n",
+		"\"multi\nline\" (after)",
+	}
+	for _, src := range srcs{
+		want := ParseList(Lex(&src), 1).ListVals
+		if got := readAll(t, src, false); !reflect.DeepEqual(got, want){
+			t.Errorf("Reader of %q read\n%+v\nwant\n%+v", src, got, want)
+		}
+		want = ParseListComments(Lex(&src), 1).ListVals
+		if got := readAll(t, src, true); !reflect.DeepEqual(got, want){
+			t.Errorf("Reader keeping comments of %q read\n%+v\nwant\n%+v", src, got, want)
+		}
+	}
+}
+
+func TestReaderPositions(t *testing.T){
+	reader := NewReader(strings.NewReader("(a\n  (b c)\n)\n  [d]"))
+	first, err := reader.Read()
+	if err != nil{
+		t.Fatal(err)
+	}
+	inner := first.ListVals[1]
+	if inner.LineNum != 2 || inner.Col != 2 || inner.EndLine != 2 || inner.EndCol != 6{
+		t.Errorf("(b c) is at line %v column %v to line %v column %v, want 2, 2 to 2, 6", inner.LineNum, inner.Col, inner.EndLine, inner.EndCol)
+	}
+	if first.EndLine != 3 || first.EndCol != 0{
+		t.Errorf("the first form ends at line %v column %v, want 3, 0", first.EndLine, first.EndCol)
+	}
+	second, err := reader.Read()
+	if err != nil{
+		t.Fatal(err)
+	}
+	if second.LineNum != 4 || second.Col != 2{
+		t.Errorf("[d] is at line %v column %v, want 4, 2", second.LineNum, second.Col)
+	}
+	if _, err := reader.Read(); err != io.EOF{
+		t.Errorf("Read at the end returned %v, want io.EOF", err)
+	}
+}
+
+//TestReaderReadsOnlyToTheEndOfAForm reads from a pipe that is not written to after the first form, as an
+//interactive input would not be, and checks that Read returns that form rather than waiting for more.
+func TestReaderReadsOnlyToTheEndOfAForm(t *testing.T){
+	in, out := io.Pipe()
+	defer out.Close()
+	go out.Write([]byte("(+ 1\n 2) "))
+	reader := NewReader(in)
+	done := make(chan Token)
+	go func(){
+		tok, _ := reader.Read()
+		done <- tok
+	}()
+	select{
+	case tok := <-done:
+		if tok.Type != ListToken || len(tok.ListVals) != 3{
+			t.Errorf("Read returned %+v", tok)
+		}
+	case <-time.After(5*time.Second):
+		t.Fatal("Read waited for input past the end of the form")
+	}
+}
+
+func TestReaderErrors(t *testing.T){
+	tests := []struct{
+		src string
+		want string
+	}{
+		{"(a) )", "unexpected ) at line 1"},
+		{"(a\n(b", "could not find matching"},
+		{"(a \"open", "unterminated string starting at line 1"},
+		{"\n\n'", "' at line 3 is not followed"},
+		{"#| open", "unterminated comment"},
+	}
+	for _, test := range tests{
+		reader := NewReader(strings.NewReader(test.src))
+		var err error
+		for err == nil{
+			_, err = reader.Read()
+		}
+		if err == io.EOF || !strings.Contains(err.Error(), test.want){
+			t.Errorf("reading %q returned %v, want an error mentioning %q", test.src, err, test.want)
+		}
+		if _, ok := err.(*ParseError); !ok && err != io.EOF{
+			t.Errorf("reading %q returned a %T, want a *ParseError", test.src, err)
+		}
+	}
+}